@@ -2,9 +2,10 @@ module github.com/faideww/mc-iso
 
 go 1.23.1
 
+require github.com/gen2brain/raylib-go/raylib v0.0.0-20240930075631-c66f9e2942fe
+
 require (
 	github.com/ebitengine/purego v0.8.0 // indirect
-	github.com/gen2brain/raylib-go/raylib v0.0.0-20240930075631-c66f9e2942fe // indirect
 	golang.org/x/exp v0.0.0-20241004190924-225e2abe05e6 // indirect
 	golang.org/x/sys v0.26.0 // indirect
 )
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+
+	"github.com/faideww/mc-iso/src/region"
+)
+
+const compactUsage = "compact REGION..."
+
+// compact rewrites region files with their chunks packed together, and
+// prints the space reclaimed from each
+func runCompact(args []string) error {
+	fs := flag.NewFlagSet("compact", flag.ExitOnError)
+	fs.Parse(args)
+	if fs.NArg() == 0 {
+		return errors.New("usage: mcnbt " + compactUsage)
+	}
+
+	var total int64
+	for _, path := range fs.Args() {
+		reclaimed, err := region.Compact(path)
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		fmt.Printf("%s: reclaimed %d bytes\n", path, reclaimed)
+		total += reclaimed
+	}
+	if fs.NArg() > 1 {
+		fmt.Printf("total: reclaimed %d bytes\n", total)
+	}
+	return nil
+}
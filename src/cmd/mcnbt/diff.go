@@ -0,0 +1,35 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+
+	"github.com/faideww/mc-iso/src/nbt"
+)
+
+const diffUsage = "diff [-chunk X,Z] OLD NEW"
+
+// diff prints the structural differences between two NBT files, or the same
+// chunk in two region files
+func runDiff(args []string) error {
+	fs := flag.NewFlagSet("diff", flag.ExitOnError)
+	var chunk chunkFlag
+	fs.Var(&chunk, "chunk", "chunk `X,Z` to compare when reading region files")
+	fs.Parse(args)
+	if fs.NArg() != 2 {
+		return errors.New("usage: mcnbt " + diffUsage)
+	}
+
+	a, err := loadTag(fs.Arg(0), chunk)
+	if err != nil {
+		return err
+	}
+	b, err := loadTag(fs.Arg(1), chunk)
+	if err != nil {
+		return err
+	}
+
+	fmt.Print(nbt.Diff(a, b))
+	return nil
+}
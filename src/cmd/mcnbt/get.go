@@ -0,0 +1,45 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+
+	"github.com/faideww/mc-iso/src/nbt"
+)
+
+const getUsage = "get [-chunk X,Z] [-indent STR] FILE PATH"
+
+// get prints every tag matching an NBT path, as SNBT
+func runGet(args []string) error {
+	fs := flag.NewFlagSet("get", flag.ExitOnError)
+	var chunk chunkFlag
+	fs.Var(&chunk, "chunk", "chunk `X,Z` to read from a region file")
+	indent := fs.String("indent", "  ", "indentation used when printing compounds and lists")
+	fs.Parse(args)
+	if fs.NArg() != 2 {
+		return errors.New("usage: mcnbt " + getUsage)
+	}
+
+	path, err := nbt.ParsePath(fs.Arg(1))
+	if err != nil {
+		return err
+	}
+	root, err := loadTag(fs.Arg(0), chunk)
+	if err != nil {
+		return err
+	}
+
+	tags, err := path.Get(root)
+	if err != nil {
+		return err
+	}
+	for _, tag := range tags {
+		s, err := nbt.FormatSNBT(tag, *indent)
+		if err != nil {
+			return err
+		}
+		fmt.Println(s)
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/faideww/mc-iso/src/nbt"
+	"github.com/faideww/mc-iso/src/region"
+)
+
+// chunkFlag parses a -chunk X,Z flag
+type chunkFlag struct {
+	set  bool
+	x, z int
+}
+
+func (c *chunkFlag) String() string {
+	if !c.set {
+		return ""
+	}
+	return fmt.Sprintf("%d,%d", c.x, c.z)
+}
+
+func (c *chunkFlag) Set(s string) error {
+	xs, zs, ok := strings.Cut(s, ",")
+	if !ok {
+		return fmt.Errorf("expected X,Z but got %q", s)
+	}
+	x, err := strconv.Atoi(strings.TrimSpace(xs))
+	if err != nil {
+		return err
+	}
+	z, err := strconv.Atoi(strings.TrimSpace(zs))
+	if err != nil {
+		return err
+	}
+	c.set, c.x, c.z = true, x, z
+	return nil
+}
+
+func isRegionFile(path string) bool {
+	ext := filepath.Ext(path)
+	return ext == ".mca" || ext == ".mcr"
+}
+
+// loadTag reads a whole NBT file (compressed or not), or a single chunk from
+// a region file, as a generic tree.
+func loadTag(path string, chunk chunkFlag) (nbt.Tag, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var tag nbt.Tag
+	if isRegionFile(path) {
+		if !chunk.set {
+			return nil, fmt.Errorf("%s is a region file - select a chunk with -chunk X,Z", path)
+		}
+		if err := region.ReadChunk(f, chunk.x, chunk.z, &tag); err != nil {
+			return nil, err
+		}
+		return tag, nil
+	}
+
+	decompressed, err := nbt.Decompress(f)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := nbt.NewDecoder(bufio.NewReader(decompressed)).Decode(&tag); err != nil {
+		return nil, err
+	}
+	return tag, nil
+}
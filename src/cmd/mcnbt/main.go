@@ -0,0 +1,48 @@
+// mcnbt is a command-line tool for inspecting NBT files (level.dat,
+// playerdata, structures) and the chunks stored in region files, and for
+// compacting region files.
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+)
+
+type command struct {
+	usage string
+	run   func(args []string) error
+}
+
+var commands = map[string]command{
+	"compact": {compactUsage, runCompact},
+	"diff":    {diffUsage, runDiff},
+	"get":     {getUsage, runGet},
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage:")
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(os.Stderr, "  mcnbt %s\n", commands[name].usage)
+	}
+	os.Exit(2)
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+	}
+	cmd, ok := commands[os.Args[1]]
+	if !ok {
+		usage()
+	}
+	if err := cmd.run(os.Args[2:]); err != nil {
+		fmt.Fprintf(os.Stderr, "mcnbt %s: %v\n", os.Args[1], err)
+		os.Exit(1)
+	}
+}
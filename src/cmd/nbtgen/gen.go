@@ -0,0 +1,194 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/faideww/mc-iso/src/nbt"
+)
+
+// generator turns a schema into go type declarations
+type generator struct {
+	structs []*structDef
+	names   map[string]bool // type names already in use
+	usesNBT bool            // whether the output refers to package nbt
+}
+
+type structDef struct {
+	name   string
+	fields []structField
+}
+
+type structField struct {
+	name, typ, tag string
+}
+
+var tagGoTypes = map[byte]string{
+	nbt.TAG_Byte:       "int8",
+	nbt.TAG_Short:      "int16",
+	nbt.TAG_Int:        "int32",
+	nbt.TAG_Long:       "int64",
+	nbt.TAG_Float:      "float32",
+	nbt.TAG_Double:     "float64",
+	nbt.TAG_String:     "string",
+	nbt.TAG_Byte_Array: "[]byte",
+	nbt.TAG_Int_Array:  "[]int32",
+	nbt.TAG_Long_Array: "[]int64",
+}
+
+// goType returns the go type for values matching s. name is the type name to
+// use if s is a compound, and parent the name of the enclosing struct, used
+// to disambiguate clashing names.
+func (g *generator) goType(s *schema, name, parent string) string {
+	if s.mixed || s.tagType == nbt.TAG_End {
+		g.usesNBT = true
+		return "nbt.Tag"
+	}
+	if t, ok := tagGoTypes[s.tagType]; ok {
+		return t
+	}
+
+	switch s.tagType {
+	case nbt.TAG_List:
+		elem := s.elem
+		if elem == nil {
+			elem = &schema{}
+		}
+		return "[]" + g.goType(elem, singular(name), parent)
+	case nbt.TAG_Compound:
+		if isMap(s) {
+			values := &schema{}
+			for _, f := range s.fields {
+				values.merge(f.schema)
+			}
+			return "map[string]" + g.goType(values, name+"Value", parent)
+		}
+		return g.structType(s, name, parent)
+	}
+	panic("nbtgen: unexpected tag type " + nbt.TagName(s.tagType))
+}
+
+// isMap reports whether a compound should be a map rather than a struct:
+// when it was always empty, or its keys are namespaced ids such as
+// minecraft:overworld rather than field names.
+func isMap(s *schema) bool {
+	if len(s.fields) == 0 {
+		return true
+	}
+	for _, f := range s.fields {
+		if strings.ContainsAny(f.name, ":/") {
+			return true
+		}
+	}
+	return false
+}
+
+func (g *generator) structType(s *schema, name, parent string) string {
+	name = g.typeName(name, parent)
+	def := &structDef{name: name}
+	g.structs = append(g.structs, def)
+
+	fieldNames := map[string]bool{}
+	for _, f := range s.fields {
+		fieldName := uniqueName(goName(f.name), fieldNames)
+		fieldNames[fieldName] = true
+
+		typ := g.goType(f.schema, goName(f.name), name)
+		tag := f.name
+		if f.seen < s.samples {
+			// the field is optional, so keep track of whether it was present
+			tag += ",omitempty"
+			if !strings.HasPrefix(typ, "[]") && !strings.HasPrefix(typ, "map[") && typ != "nbt.Tag" {
+				typ = "*" + typ
+			}
+		}
+		def.fields = append(def.fields, structField{fieldName, typ, fmt.Sprintf("`nbt:%s`", strconv.Quote(tag))})
+	}
+	return name
+}
+
+// typeName picks an unused name for a struct type, prefixing it with the name
+// of its parent if it is already taken.
+func (g *generator) typeName(name, parent string) string {
+	if g.names[name] {
+		name = parent + name
+	}
+	name = uniqueName(name, g.names)
+	g.names[name] = true
+	return name
+}
+
+func uniqueName(name string, used map[string]bool) string {
+	if !used[name] {
+		return name
+	}
+	for i := 2; ; i++ {
+		if n := name + strconv.Itoa(i); !used[n] {
+			return n
+		}
+	}
+}
+
+// goName converts a tag name such as block_states or xPos into an exported go
+// identifier (BlockStates, XPos).
+func goName(tagName string) string {
+	var sb strings.Builder
+	upper := true
+	for _, r := range tagName {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			upper = true
+			continue
+		}
+		if upper {
+			r = unicode.ToUpper(r)
+			upper = false
+		}
+		sb.WriteRune(r)
+	}
+	name := sb.String()
+	if name == "" || !unicode.IsLetter([]rune(name)[0]) {
+		name = "F" + name
+	}
+	return name
+}
+
+// singular guesses the name of an element type from the name of a list, eg.
+// Sections -> Section
+func singular(name string) string {
+	switch {
+	case strings.HasSuffix(name, "ies"):
+		return strings.TrimSuffix(name, "ies") + "y"
+	case strings.HasSuffix(name, "s") && !strings.HasSuffix(name, "ss") && !strings.HasSuffix(name, "us"):
+		return strings.TrimSuffix(name, "s")
+	}
+	return name + "Elem"
+}
+
+// generate returns formatted go source declaring the root type and every
+// struct it refers to.
+func (g *generator) generate(pkg, comment string, root *schema, rootName string) ([]byte, error) {
+	g.names = map[string]bool{}
+	rootType := g.goType(root, rootName, "")
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "%s\n\npackage %s\n\n", comment, pkg)
+	if g.usesNBT {
+		buf.WriteString("import \"github.com/faideww/mc-iso/src/nbt\"\n\n")
+	}
+	if len(g.structs) == 0 || g.structs[0].name != rootType {
+		// the root isn't a struct (eg. a compound of namespaced ids)
+		fmt.Fprintf(&buf, "type %s %s\n\n", rootName, rootType)
+	}
+	for _, def := range g.structs {
+		fmt.Fprintf(&buf, "type %s struct {\n", def.name)
+		for _, f := range def.fields {
+			fmt.Fprintf(&buf, "\t%s %s %s\n", f.name, f.typ, f.tag)
+		}
+		buf.WriteString("}\n\n")
+	}
+	return format.Source(buf.Bytes())
+}
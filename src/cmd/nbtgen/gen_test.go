@@ -0,0 +1,233 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/faideww/mc-iso/src/nbt"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+// schemaOf adds each SNBT sample to a new schema.
+func schemaOf(t *testing.T, samples ...string) *schema {
+	t.Helper()
+	s := &schema{}
+	for _, snbt := range samples {
+		tag, err := nbt.ParseSNBTTag(snbt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		s.add(tag)
+	}
+	return s
+}
+
+// checkGolden compares got with the file at path, or rewrites it with -update.
+func checkGolden(t *testing.T, path string, got []byte) {
+	t.Helper()
+	if *update {
+		if err := os.WriteFile(path, got, 0644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("%s: generated\n%s\nwant\n%s", path, got, want)
+	}
+}
+
+func TestGenerate(t *testing.T) {
+	tests := []struct {
+		name    string
+		samples []string
+	}{
+		// fields missing from some samples are optional, and pointers unless
+		// they're already nilable
+		{"optional", []string{
+			`{a: 1b, b: "x", l: [1], c: {x: 1}, t: [B; 1b]}`,
+			`{a: 2b}`,
+		}},
+		// numeric types are widened to hold every sample, and anything else
+		// that disagrees is an nbt.Tag
+		{"widen", []string{
+			`{n: 1b, f: 1.5f, i: 1, m: 1, l: [1b], e: []}`,
+			`{n: 2L, f: 2.5d, i: 2.5f, m: "x", l: [1s]}`,
+		}},
+		// compounds keyed by namespaced ids, or always empty, are maps
+		{"maps", []string{
+			`{dimensions: {"minecraft:overworld": {type: "a"}, "minecraft:the_nether": {type: "b", seed: 1L}}, empty: {}}`,
+		}},
+		// the same name in two places gets the parent's name as a prefix
+		{"names", []string{
+			`{Pos: {x: 1}, Player: {Pos: {y: 1}, Inventory: [{Slot: 0b}]}, block_entities: [{id: "x"}], Entities: [{Pos: [1.0d]}], status: [{}], "9lives": 1b, "a-b": 1b, "a_b": 2b}`,
+		}},
+	}
+	for _, tt := range tests {
+		var g generator
+		src, err := g.generate("main", "// generated by nbtgen from "+tt.name, schemaOf(t, tt.samples...), "Root")
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		checkGolden(t, filepath.Join("testdata", tt.name+".golden"), src)
+	}
+}
+
+// level_gen_test.go is nbtgen's output for level.dat, so the samples decode
+// into the generated types
+func TestGenerateLevel(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "level.go")
+	if err := run([]string{"../../nbt/testdata/level.dat"}, "generatedLevel", "main", out); err != nil {
+		t.Fatal(err)
+	}
+	src, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkGolden(t, "level_gen_test.go", src)
+
+	f, err := os.Open("../../nbt/testdata/level.dat")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	r, err := nbt.Decompress(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := nbt.NewDecoder(r)
+	d.DisallowUnknownFields()
+	d.DisallowTypeCoercion()
+	var level generatedLevel
+	if _, err := d.Decode(&level); err != nil {
+		t.Fatal(err)
+	}
+	if level.Data.LevelName != "New World" || level.Data.Player.Inventory[1].Id != "minecraft:torch" {
+		t.Errorf("decoded %+v", level.Data)
+	}
+}
+
+func TestWiden(t *testing.T) {
+	tests := []struct {
+		a, b byte
+		want byte
+		ok   bool
+	}{
+		{nbt.TAG_Byte, nbt.TAG_Int, nbt.TAG_Int, true},
+		{nbt.TAG_Long, nbt.TAG_Short, nbt.TAG_Long, true},
+		{nbt.TAG_Float, nbt.TAG_Double, nbt.TAG_Double, true},
+		{nbt.TAG_Int, nbt.TAG_Float, nbt.TAG_Double, true},
+		{nbt.TAG_Double, nbt.TAG_Byte, nbt.TAG_Double, true},
+		{nbt.TAG_Int, nbt.TAG_String, 0, false},
+		{nbt.TAG_Int_Array, nbt.TAG_Long_Array, 0, false},
+		{nbt.TAG_List, nbt.TAG_Compound, 0, false},
+	}
+	for _, tt := range tests {
+		if got, ok := widen(tt.a, tt.b); got != tt.want || ok != tt.ok {
+			t.Errorf("widen(%s, %s) = %s, %t, want %s, %t", nbt.TagName(tt.a), nbt.TagName(tt.b),
+				nbt.TagName(got), ok, nbt.TagName(tt.want), tt.ok)
+		}
+	}
+}
+
+func TestSchemaMerge(t *testing.T) {
+	a := schemaOf(t, `{x: 1b, l: [{p: 1}]}`, `{x: 2s}`)
+	b := schemaOf(t, `{x: 3, y: "s", l: [{p: 2, q: 1b}]}`)
+	a.merge(b)
+	if a.samples != 3 || a.tagType != nbt.TAG_Compound {
+		t.Fatalf("merged %d samples of %s, want 3 of TAG_Compound", a.samples, nbt.TagName(a.tagType))
+	}
+	for name, want := range map[string]struct {
+		seen    int
+		tagType byte
+	}{
+		"x": {3, nbt.TAG_Int},
+		"y": {1, nbt.TAG_String},
+		"l": {2, nbt.TAG_List},
+	} {
+		f := a.byName[name]
+		if f == nil || f.seen != want.seen || f.schema.tagType != want.tagType {
+			t.Errorf("field %s = %+v, want seen %d as %s", name, f, want.seen, nbt.TagName(want.tagType))
+		}
+	}
+	if elem := a.byName["l"].schema.elem; elem.samples != 2 || elem.byName["q"].seen != 1 {
+		t.Errorf("list elements merged as %+v", elem)
+	}
+
+	// mixed schemas stay mixed
+	m := schemaOf(t, `1`, `"s"`)
+	if !m.mixed {
+		t.Fatalf("1 and \"s\" aren't mixed")
+	}
+	a.merge(m)
+	if !a.mixed {
+		t.Errorf("merging a mixed schema isn't mixed")
+	}
+	// and merging nothing changes nothing
+	n := schemaOf(t, `1b`)
+	n.merge(&schema{})
+	if n.samples != 1 || n.tagType != nbt.TAG_Byte {
+		t.Errorf("merging an empty schema gave %+v", n)
+	}
+}
+
+func TestIsMap(t *testing.T) {
+	for snbt, want := range map[string]bool{
+		`{}`:                          true,
+		`{"minecraft:stone": 1}`:      true,
+		`{a: 1, "textures/x": 2}`:     true,
+		`{Name: "x", Properties: {}}`: false,
+	} {
+		if got := isMap(schemaOf(t, snbt)); got != want {
+			t.Errorf("isMap(%s) = %t, want %t", snbt, got, want)
+		}
+	}
+}
+
+func TestNames(t *testing.T) {
+	for in, want := range map[string]string{
+		"block_states": "BlockStates",
+		"xPos":         "XPos",
+		"Name":         "Name",
+		"a-b.c":        "ABC",
+		"9lives":       "F9lives",
+		"":             "F",
+		"_":            "F",
+	} {
+		if got := goName(in); got != want {
+			t.Errorf("goName(%q) = %q, want %q", in, got, want)
+		}
+	}
+	for in, want := range map[string]string{
+		"Sections":   "Section",
+		"Entities":   "Entity",
+		"Status":     "StatusElem",
+		"Access":     "AccessElem",
+		"Motion":     "MotionElem",
+		"BlockTicks": "BlockTick",
+	} {
+		if got := singular(in); got != want {
+			t.Errorf("singular(%q) = %q, want %q", in, got, want)
+		}
+	}
+
+	g := generator{names: map[string]bool{}}
+	for _, tt := range []struct{ name, parent, want string }{
+		{"Pos", "Root", "Pos"},
+		{"Pos", "Player", "PlayerPos"},
+		{"Pos", "Player", "PlayerPos2"},
+		{"PlayerPos", "Root", "RootPlayerPos"},
+	} {
+		if got := g.typeName(tt.name, tt.parent); got != tt.want {
+			t.Errorf("typeName(%q, %q) = %q, want %q", tt.name, tt.parent, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,117 @@
+// generated by nbtgen from level.dat
+
+package main
+
+import "github.com/faideww/mc-iso/src/nbt"
+
+type generatedLevel struct {
+	Data Data `nbt:"Data"`
+}
+
+type Data struct {
+	AllowCommands        int8             `nbt:"allowCommands"`
+	BorderCenterX        float64          `nbt:"BorderCenterX"`
+	BorderCenterZ        float64          `nbt:"BorderCenterZ"`
+	BorderDamagePerBlock float64          `nbt:"BorderDamagePerBlock"`
+	BorderSize           float64          `nbt:"BorderSize"`
+	BorderSizeLerpTarget float64          `nbt:"BorderSizeLerpTarget"`
+	BorderSizeLerpTime   int64            `nbt:"BorderSizeLerpTime"`
+	BorderWarningBlocks  float64          `nbt:"BorderWarningBlocks"`
+	BorderWarningTime    float64          `nbt:"BorderWarningTime"`
+	ClearWeatherTime     int32            `nbt:"clearWeatherTime"`
+	DataVersion          int32            `nbt:"DataVersion"`
+	DayTime              int64            `nbt:"DayTime"`
+	Difficulty           int8             `nbt:"Difficulty"`
+	GameRules            GameRules        `nbt:"GameRules"`
+	GameType             int32            `nbt:"GameType"`
+	Hardcore             int8             `nbt:"hardcore"`
+	Initialized          int8             `nbt:"initialized"`
+	LastPlayed           int64            `nbt:"LastPlayed"`
+	LevelName            string           `nbt:"LevelName"`
+	Raining              int8             `nbt:"raining"`
+	RainTime             int32            `nbt:"rainTime"`
+	SpawnAngle           float32          `nbt:"SpawnAngle"`
+	SpawnX               int32            `nbt:"SpawnX"`
+	SpawnY               int32            `nbt:"SpawnY"`
+	SpawnZ               int32            `nbt:"SpawnZ"`
+	Time                 int64            `nbt:"Time"`
+	Version              int32            `nbt:"version"`
+	Version2             Version          `nbt:"Version"`
+	WasModded            int8             `nbt:"WasModded"`
+	WorldGenSettings     WorldGenSettings `nbt:"WorldGenSettings"`
+	DataPacks            DataPacks        `nbt:"DataPacks"`
+	ServerBrands         []string         `nbt:"ServerBrands"`
+	Player               Player           `nbt:"Player"`
+}
+
+type GameRules struct {
+	DoDaylightCycle string `nbt:"doDaylightCycle"`
+	RandomTickSpeed string `nbt:"randomTickSpeed"`
+}
+
+type Version struct {
+	Id       int32  `nbt:"Id"`
+	Name     string `nbt:"Name"`
+	Series   string `nbt:"Series"`
+	Snapshot int8   `nbt:"Snapshot"`
+}
+
+type WorldGenSettings struct {
+	BonusChest       int8                       `nbt:"bonus_chest"`
+	GenerateFeatures int8                       `nbt:"generate_features"`
+	Seed             int64                      `nbt:"seed"`
+	Dimensions       map[string]DimensionsValue `nbt:"dimensions"`
+}
+
+type DimensionsValue struct {
+	Type      string    `nbt:"type"`
+	Generator Generator `nbt:"generator"`
+}
+
+type Generator struct {
+	Type        string      `nbt:"type"`
+	Settings    string      `nbt:"settings"`
+	BiomeSource BiomeSource `nbt:"biome_source"`
+}
+
+type BiomeSource struct {
+	Type   string `nbt:"type"`
+	Preset string `nbt:"preset"`
+}
+
+type DataPacks struct {
+	Enabled  []string  `nbt:"Enabled"`
+	Disabled []nbt.Tag `nbt:"Disabled"`
+}
+
+type Player struct {
+	Pos        []float64       `nbt:"Pos"`
+	Rotation   []float32       `nbt:"Rotation"`
+	Motion     []float64       `nbt:"Motion"`
+	Health     float32         `nbt:"Health"`
+	Inventory  []InventoryElem `nbt:"Inventory"`
+	UUID       []int32         `nbt:"UUID"`
+	Attributes []Attribute     `nbt:"Attributes"`
+}
+
+type InventoryElem struct {
+	Slot  int8   `nbt:"Slot"`
+	Id    string `nbt:"id"`
+	Count int8   `nbt:"Count"`
+	Tag   *Tag   `nbt:"tag,omitempty"`
+}
+
+type Tag struct {
+	Damage       int32         `nbt:"Damage"`
+	Enchantments []Enchantment `nbt:"Enchantments"`
+}
+
+type Enchantment struct {
+	Id  string `nbt:"id"`
+	Lvl int16  `nbt:"lvl"`
+}
+
+type Attribute struct {
+	Name string  `nbt:"Name"`
+	Base float64 `nbt:"Base"`
+}
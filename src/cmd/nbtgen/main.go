@@ -0,0 +1,129 @@
+// nbtgen generates go struct definitions, with nbt tags, from sample NBT
+// files:
+//
+//	go run ./src/cmd/nbtgen [-type NAME] [-package NAME] [-o FILE] FILE...
+//
+// Field types are inferred from the tag types in the samples. Fields missing
+// from some samples are generated as omitempty (and as pointers, where that
+// is needed to tell them apart from zero values). Every chunk of a region
+// file is used as a separate sample.
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/faideww/mc-iso/src/nbt"
+	"github.com/faideww/mc-iso/src/region"
+)
+
+func main() {
+	typeName := flag.String("type", "", "name of the root type (default derived from the first file name)")
+	pkg := flag.String("package", "main", "package clause of the generated file")
+	out := flag.String("o", "", "write the output to `FILE` rather than stdout")
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: nbtgen [-type NAME] [-package NAME] [-o FILE] FILE...")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	if err := run(flag.Args(), *typeName, *pkg, *out); err != nil {
+		fmt.Fprintf(os.Stderr, "nbtgen: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(files []string, typeName, pkg, out string) error {
+	root := &schema{}
+	for _, path := range files {
+		if err := addSamples(root, path); err != nil {
+			return err
+		}
+	}
+	if root.samples == 0 {
+		return errors.New("no samples found")
+	}
+	if typeName == "" {
+		typeName = defaultTypeName(files[0])
+	}
+
+	comment := "// generated by nbtgen from " + strings.Join(baseNames(files), ", ")
+	var g generator
+	src, err := g.generate(pkg, comment, root, typeName)
+	if err != nil {
+		return err
+	}
+	if out == "" {
+		_, err = os.Stdout.Write(src)
+		return err
+	}
+	return os.WriteFile(out, src, 0644)
+}
+
+func isRegionFile(path string) bool {
+	ext := filepath.Ext(path)
+	return ext == ".mca" || ext == ".mcr"
+}
+
+// addSamples adds the root tag of an NBT file (compressed or not), or every
+// chunk of a region file, to the schema.
+func addSamples(s *schema, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if isRegionFile(path) {
+		r, err := region.NewReader(f)
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		for x, z := range r.Chunks() {
+			var tag nbt.Tag
+			if err := r.DecodeChunk(x, z, &tag); err != nil {
+				return fmt.Errorf("%s: %w", path, err)
+			}
+			s.add(tag)
+		}
+		return nil
+	}
+
+	decompressed, err := nbt.Decompress(f)
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	var tag nbt.Tag
+	if _, err := nbt.NewDecoder(bufio.NewReader(decompressed)).Decode(&tag); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	s.add(tag)
+	return nil
+}
+
+// defaultTypeName derives a type name from a file name, eg. level.dat ->
+// Level. Chunks from region files are called Chunk.
+func defaultTypeName(path string) string {
+	if isRegionFile(path) {
+		return "Chunk"
+	}
+	base := filepath.Base(path)
+	return goName(strings.TrimSuffix(base, filepath.Ext(base)))
+}
+
+func baseNames(paths []string) []string {
+	names := make([]string, len(paths))
+	for i, p := range paths {
+		names[i] = filepath.Base(p)
+	}
+	return names
+}
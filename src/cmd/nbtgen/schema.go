@@ -0,0 +1,116 @@
+package main
+
+import (
+	"github.com/faideww/mc-iso/src/nbt"
+)
+
+// schema is the shape of every tag seen at one position in the samples.
+type schema struct {
+	tagType byte // TAG_End if nothing has been seen yet
+	mixed   bool // samples had incompatible types
+	samples int  // number of tags merged into the schema
+
+	elem   *schema // element schema of a list
+	fields []*fieldSchema
+	byName map[string]*fieldSchema
+}
+
+// fieldSchema is an entry of a compound, and how many of the compounds it
+// appeared in.
+type fieldSchema struct {
+	name   string
+	seen   int
+	schema *schema
+}
+
+func (s *schema) field(name string) *fieldSchema {
+	if f, ok := s.byName[name]; ok {
+		return f
+	}
+	if s.byName == nil {
+		s.byName = make(map[string]*fieldSchema)
+	}
+	f := &fieldSchema{name: name, schema: &schema{}}
+	s.fields = append(s.fields, f)
+	s.byName[name] = f
+	return f
+}
+
+// add merges a sample tag into the schema.
+func (s *schema) add(tag nbt.Tag) {
+	if !s.mergeType(tag.TagType()) {
+		return
+	}
+	s.samples++
+	switch tag := tag.(type) {
+	case *nbt.Compound:
+		for _, e := range tag.Entries {
+			f := s.field(e.Name)
+			f.seen++
+			f.schema.add(e.Value)
+		}
+	case *nbt.List:
+		if s.elem == nil {
+			s.elem = &schema{}
+		}
+		for _, e := range tag.Elems {
+			s.elem.add(e)
+		}
+	}
+}
+
+// merge combines two schemas, eg. the values of every entry of a compound
+// that is generated as a map.
+func (s *schema) merge(o *schema) {
+	if o.mixed {
+		s.mixed = true
+		return
+	}
+	if o.tagType == nbt.TAG_End || !s.mergeType(o.tagType) {
+		return
+	}
+	s.samples += o.samples
+	for _, of := range o.fields {
+		f := s.field(of.name)
+		f.seen += of.seen
+		f.schema.merge(of.schema)
+	}
+	if o.elem != nil {
+		if s.elem == nil {
+			s.elem = &schema{}
+		}
+		s.elem.merge(o.elem)
+	}
+}
+
+// mergeType widens the schema's type to include tagType, and reports whether
+// the two are compatible.
+func (s *schema) mergeType(tagType byte) bool {
+	switch {
+	case s.mixed:
+		return false
+	case s.tagType == nbt.TAG_End || s.tagType == tagType:
+		s.tagType = tagType
+		return true
+	}
+	if w, ok := widen(s.tagType, tagType); ok {
+		s.tagType = w
+		return true
+	}
+	s.mixed = true
+	return false
+}
+
+// widen returns the smallest numeric tag type that can hold values of both a
+// and b, relying on the decoder's numeric conversions.
+func widen(a, b byte) (byte, bool) {
+	isInt := func(t byte) bool { return t >= nbt.TAG_Byte && t <= nbt.TAG_Long }
+	isFloat := func(t byte) bool { return t == nbt.TAG_Float || t == nbt.TAG_Double }
+	switch {
+	case isInt(a) && isInt(b), isFloat(a) && isFloat(b):
+		return max(a, b), true
+	case isInt(a) && isFloat(b), isFloat(a) && isInt(b):
+		return nbt.TAG_Double, true
+	}
+	return 0, false
+}
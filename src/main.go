@@ -24,7 +24,7 @@ type LevelData struct {
 	AllowCommands        bool    `nbt:"allowCommands"`
 	BorderCenterX        float64 `nbt:"BorderCenterX"`
 	BorderCenterY        float64 `nbt:"BorderCenterY"`
-	BorderDamgePerBlock  float64 `nbt:"BorderDamgePerBlock"`
+	BorderDamagePerBlock float64 `nbt:"BorderDamagePerBlock"`
 	BorderSize           float64 `nbt:"BorderSize"`
 	BorderSafeZone       float64 `nbt:"BorderSafeZone"`
 	BorderSizeLerpTarget float64 `nbt:"BorderSizeLerpTarget"`
@@ -83,18 +83,23 @@ func main() {
 	}
 	defer regionFile.Close()
 
-	reg, err := region.NewRegion(regionFile)
+	reg, err := region.NewReader(regionFile)
 	if err != nil {
 		log.Fatal(err)
 	}
 	fmt.Printf("successfully parsed region\n")
-	// fmt.Printf("example chunk 0: %+v\n", region.Chunks[0])
 
-	for i, s := range reg.Chunks[0].Sections {
+	chunk, err := reg.ReadChunk(0, 0)
+	if err != nil {
+		log.Fatal(err)
+	}
+	// fmt.Printf("example chunk 0: %+v\n", chunk)
+
+	for i, s := range chunk.Sections {
 		fmt.Printf("section %d Y: %d\n", i, s.Y)
 	}
 
-	debugPrintChunkSection(reg.Chunks[0].Sections[0])
+	debugPrintChunkSection(chunk.Sections[0])
 }
 
 func debugPrintChunkSection(s region.Section) {
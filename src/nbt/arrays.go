@@ -0,0 +1,126 @@
+package nbt
+
+import (
+	"io"
+	"reflect"
+)
+
+// arrays.go
+// bulk decoding of TAG_Byte_Array, TAG_Int_Array and TAG_Long_Array. Block
+// states, biomes, heightmaps and light data are all stored as arrays, so
+// reading them one element at a time through reflection dominates the cost of
+// decoding a chunk.
+
+// bulkSlice returns the elements of buf (a slice, or an addressable array) as
+// a []E sharing its memory, if its element type is exactly E.
+func bulkSlice[E any](buf reflect.Value) ([]E, bool) {
+	if buf.Kind() == reflect.Array {
+		if !buf.CanAddr() {
+			return nil, false
+		}
+		buf = buf.Slice(0, buf.Len())
+	}
+	t := reflect.TypeFor[[]E]()
+	if buf.Type().Elem() != t.Elem() {
+		return nil, false
+	}
+	return buf.Convert(t).Interface().([]E), true
+}
+
+// readBytes fills dst with the payload of a TAG_Byte_Array.
+func (d *NBTDecoder) readBytes(dst []byte) error {
+	_, err := io.ReadFull(d.r, dst)
+	return err
+}
+
+// readInt32s fills dst with the payload of a TAG_Int_Array.
+func (d *NBTDecoder) readInt32s(dst []int32) error {
+	if d.variant == BedrockNetwork {
+		// elements are varints, so they have to be read one at a time
+		for i := range dst {
+			v, err := d.ReadInt32()
+			if err != nil {
+				return err
+			}
+			dst[i] = v
+		}
+		return nil
+	}
+
+	order := d.variant.byteOrder()
+	for len(dst) > 0 {
+		buf, err := d.r.next(min(len(dst)*4, readBufSize))
+		if err != nil {
+			return err
+		}
+		n := len(buf) / 4
+		for i := range dst[:n] {
+			dst[i] = int32(order.Uint32(buf[i*4:]))
+		}
+		dst = dst[n:]
+	}
+	return nil
+}
+
+// readInt64s fills dst with the payload of a TAG_Long_Array.
+func (d *NBTDecoder) readInt64s(dst []int64) error {
+	if d.variant == BedrockNetwork {
+		for i := range dst {
+			v, err := d.ReadInt64()
+			if err != nil {
+				return err
+			}
+			dst[i] = v
+		}
+		return nil
+	}
+
+	order := d.variant.byteOrder()
+	for len(dst) > 0 {
+		buf, err := d.r.next(min(len(dst)*8, readBufSize))
+		if err != nil {
+			return err
+		}
+		n := len(buf) / 8
+		for i := range dst[:n] {
+			dst[i] = int64(order.Uint64(buf[i*8:]))
+		}
+		dst = dst[n:]
+	}
+	return nil
+}
+
+// maxPrealloc is the most memory, in bytes, allocated for an array or list
+// before any of its elements have been read. Lengths come from the input, so a
+// bogus one fails with io.ErrUnexpectedEOF once the data runs out instead of
+// exhausting memory up front.
+const maxPrealloc = 64 << 10
+
+// fillArray decodes n elements into val, an array of length n or a slice (or
+// interface) of type vt. fill decodes the elements of part, the first of which
+// is element start of the whole array. Slices are grown as elements arrive.
+func (d *NBTDecoder) fillArray(val reflect.Value, vt reflect.Type, n int, fill func(part reflect.Value, start int) error) error {
+	if val.Kind() == reflect.Array {
+		return fill(val, 0)
+	}
+	if err := d.alloc(int64(n) * int64(vt.Elem().Size())); err != nil {
+		return err
+	}
+
+	size := max(int(vt.Elem().Size()), 1)
+	buf := reflect.MakeSlice(vt, 0, min(n, max(maxPrealloc/size, 1)))
+	for buf.Len() < n {
+		if buf.Len() == buf.Cap() {
+			grown := reflect.MakeSlice(vt, buf.Len(), min(n, 2*buf.Cap()))
+			reflect.Copy(grown, buf)
+			buf = grown
+		}
+		start := buf.Len()
+		buf = buf.Slice(0, buf.Cap())
+		if err := fill(buf.Slice(start, buf.Len()), start); err != nil {
+			return err
+		}
+	}
+	val.Set(buf)
+	return nil
+}
@@ -0,0 +1,87 @@
+package nbt_test
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"testing"
+
+	"github.com/faideww/mc-iso/src/nbt"
+	"github.com/faideww/mc-iso/src/region"
+)
+
+// benchChunk returns a chunk saved by the game (1.18.1), uncompressed. It's
+// chunk (0, 0) of the test world in github.com/Tnze/go-mc (MIT license).
+func benchChunk(b *testing.B) []byte {
+	f, err := os.Open("testdata/chunk-1.18.1.nbt.zlib")
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer f.Close()
+	r, err := nbt.Decompress(f)
+	if err != nil {
+		b.Fatal(err)
+	}
+	data, err := io.ReadAll(r)
+	if err != nil {
+		b.Fatal(err)
+	}
+	return data
+}
+
+func BenchmarkDecodeChunk(b *testing.B) {
+	data := benchChunk(b)
+	b.SetBytes(int64(len(data)))
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		var c region.Chunk
+		if _, err := nbt.NewDecoder(bytes.NewReader(data)).Decode(&c); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkDecodeChunkTag(b *testing.B) {
+	data := benchChunk(b)
+	b.SetBytes(int64(len(data)))
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		var tag nbt.Tag
+		if _, err := nbt.NewDecoder(bytes.NewReader(data)).Decode(&tag); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// long is a named element type, which isn't eligible for bulk decoding, so
+// it shows the cost of decoding one element at a time
+type long int64
+
+func BenchmarkDecodeLongArray(b *testing.B) {
+	var buf bytes.Buffer
+	if err := nbt.NewEncoder(&buf).Encode("data", make([]int64, 4096)); err != nil {
+		b.Fatal(err)
+	}
+	data := buf.Bytes()
+
+	b.Run("bulk", func(b *testing.B) {
+		b.SetBytes(int64(len(data)))
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			var v []int64
+			if _, err := nbt.NewDecoder(bytes.NewReader(data)).Decode(&v); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("per-element", func(b *testing.B) {
+		b.SetBytes(int64(len(data)))
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			var v []long
+			if _, err := nbt.NewDecoder(bytes.NewReader(data)).Decode(&v); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
@@ -1,10 +1,13 @@
 package nbt
 
 import (
+	"bytes"
 	"compress/gzip"
 	"compress/zlib"
+	"errors"
 	"fmt"
 	"io"
+	"sync"
 )
 
 const (
@@ -44,25 +47,114 @@ func CheckCompression(r io.ReaderAt) (int, error) {
 	}
 }
 
+// Decompress detects the compression format of r from its first bytes, using
+// the registered Compressors, and returns a reader of the decompressed data.
+// Data that isn't compressed is returned as is.
 func Decompress(r DecompressibleReader) (io.Reader, error) {
-	isCompressed, err := CheckCompression(r)
-	if err != nil {
+	var head [16]byte
+	n, err := r.ReadAt(head[:], 0)
+	if n == 0 {
+		if err == nil || err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
 		return nil, err
 	}
 
-	var decompressed io.Reader
+	c, ok := detectCompressor(head[:n])
+	if !ok {
+		// either not an NBT file, or an unrecognized compression format
+		return nil, fmt.Errorf("unrecognized first byte %#02x - either not an NBT file, or an unsupported compression format", head[0])
+	}
+	return c.NewReader(r)
+}
+
+// Compressor is a compression format that NBT files or region file chunks may
+// be stored in.
+type Compressor struct {
+	// Name identifies the format, eg. "zlib". Region files refer to custom
+	// formats by a namespaced id, such as "mymod:zstd".
+	Name string
+	// Magic is the prefix Decompress uses to detect the format, or nil if it
+	// can't be detected.
+	Magic []byte
+	// NewReader returns a reader of the decompressed contents of r.
+	NewReader func(r io.Reader) (io.Reader, error)
+	// NewWriter returns a writer that compresses data into w, or is nil if
+	// the format can only be read.
+	NewWriter func(w io.Writer) (io.WriteCloser, error)
+}
+
+// ErrUnsupportedCompression is returned when data is stored in a compression
+// format that hasn't been registered.
+var ErrUnsupportedCompression = errors.New("nbt: unsupported compression format")
+
+var (
+	compressorsMu sync.RWMutex
+	compressors   = map[string]Compressor{}
+)
+
+// RegisterCompressor makes a compression format available to Decompress and
+// to region files, replacing any format previously registered with the same
+// name.
+func RegisterCompressor(c Compressor) {
+	compressorsMu.Lock()
+	defer compressorsMu.Unlock()
+	compressors[c.Name] = c
+}
+
+// LookupCompressor returns the compression format registered under name.
+func LookupCompressor(name string) (Compressor, bool) {
+	compressorsMu.RLock()
+	defer compressorsMu.RUnlock()
+	c, ok := compressors[name]
+	return c, ok
+}
 
-	switch isCompressed {
-	case Uncompressed:
-		decompressed = r
-	case Gzip:
-		decompressed, err = gzip.NewReader(r)
-	case Zlib:
-		decompressed, err = zlib.NewReader(r)
+// detectCompressor finds the format whose magic is the longest prefix of head.
+// Data starting with a TAG_Compound is assumed to be uncompressed.
+func detectCompressor(head []byte) (Compressor, bool) {
+	compressorsMu.RLock()
+	defer compressorsMu.RUnlock()
+	var best Compressor
+	found := false
+	for _, c := range compressors {
+		if len(c.Magic) > 0 && bytes.HasPrefix(head, c.Magic) && len(c.Magic) > len(best.Magic) {
+			best, found = c, true
+		}
 	}
-	if err != nil {
-		return nil, err
+	if !found && head[0] == TAG_Compound {
+		return compressors["none"], true
 	}
+	return best, found
+}
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error { return nil }
 
-	return decompressed, nil
+func init() {
+	RegisterCompressor(Compressor{
+		Name:      "gzip",
+		Magic:     []byte{0x1f, 0x8b},
+		NewReader: func(r io.Reader) (io.Reader, error) { return gzip.NewReader(r) },
+		NewWriter: func(w io.Writer) (io.WriteCloser, error) { return gzip.NewWriter(w), nil },
+	})
+	RegisterCompressor(Compressor{
+		Name:      "zlib",
+		Magic:     []byte{0x78},
+		NewReader: func(r io.Reader) (io.Reader, error) { return zlib.NewReader(r) },
+		NewWriter: func(w io.Writer) (io.WriteCloser, error) { return zlib.NewWriter(w), nil },
+	})
+	RegisterCompressor(Compressor{
+		Name:      "none",
+		NewReader: func(r io.Reader) (io.Reader, error) { return r, nil },
+		NewWriter: func(w io.Writer) (io.WriteCloser, error) { return nopWriteCloser{w}, nil },
+	})
+	RegisterCompressor(Compressor{
+		Name:      "lz4",
+		Magic:     []byte(lz4BlockMagic),
+		NewReader: func(r io.Reader) (io.Reader, error) { return newLZ4BlockReader(r), nil },
+	})
 }
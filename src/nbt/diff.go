@@ -0,0 +1,318 @@
+package nbt
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// diff.go
+// structural comparison of generic trees, and patches that turn one tree into
+// another
+
+// ChangeKind is the kind of difference described by a Change.
+type ChangeKind byte
+
+const (
+	ChangeAdded   ChangeKind = iota // the tag only exists in the new tree
+	ChangeRemoved                   // the tag only exists in the old tree
+	ChangeValue                     // the tag has the same type but a different value
+	ChangeType                      // the tag has a different type
+)
+
+// Change is a single difference between two trees. Path is an NBT path (see
+// ParsePath) selecting exactly one tag, or "" for the root itself. Old is nil
+// for ChangeAdded, and New is nil for ChangeRemoved.
+type Change struct {
+	Kind ChangeKind
+	Path string
+	Old  Tag
+	New  Tag
+}
+
+// Patch is a list of changes, in the order they have to be applied.
+type Patch []Change
+
+// Diff compares two trees and returns the changes that turn a into b.
+//
+// Compounds are compared entry by entry, and lists element by element, so
+// appending to a list shows up as added elements at the end. A non-empty list
+// whose element type differs, or an array whose length differs, is reported
+// as a single change of the whole value. Floats are compared by their bits, so
+// NaNs are equal to themselves.
+func Diff(a, b Tag) Patch {
+	return appendDiff(nil, "", a, b)
+}
+
+func appendDiff(p Patch, path string, a, b Tag) Patch {
+	if a.TagType() != b.TagType() {
+		return append(p, Change{Kind: ChangeType, Path: path, Old: a, New: b})
+	}
+
+	switch a := a.(type) {
+	case *Compound:
+		b := b.(*Compound)
+		for _, e := range a.Entries {
+			child := joinPathName(path, e.Name)
+			if v, ok := b.Get(e.Name); ok {
+				p = appendDiff(p, child, e.Value, v)
+			} else {
+				p = append(p, Change{Kind: ChangeRemoved, Path: child, Old: e.Value})
+			}
+		}
+		for _, e := range b.Entries {
+			if _, ok := a.Get(e.Name); !ok {
+				p = append(p, Change{Kind: ChangeAdded, Path: joinPathName(path, e.Name), New: e.Value})
+			}
+		}
+		return p
+	case *List:
+		b := b.(*List)
+		// the element type is part of the list's value, even when it's empty
+		if a.ElemType != b.ElemType {
+			return append(p, Change{Kind: ChangeValue, Path: path, Old: a, New: b})
+		}
+		n := min(len(a.Elems), len(b.Elems))
+		for i := range n {
+			p = appendDiff(p, joinPathIndex(path, i), a.Elems[i], b.Elems[i])
+		}
+		// remove from the end first, so that earlier indexes stay valid
+		for i := len(a.Elems) - 1; i >= n; i-- {
+			p = append(p, Change{Kind: ChangeRemoved, Path: joinPathIndex(path, i), Old: a.Elems[i]})
+		}
+		for i := n; i < len(b.Elems); i++ {
+			p = append(p, Change{Kind: ChangeAdded, Path: joinPathIndex(path, i), New: b.Elems[i]})
+		}
+		return p
+	case ByteArray:
+		return appendArrayDiff(p, path, a, b.(ByteArray), func(v byte) Tag { return Byte(v) })
+	case IntArray:
+		return appendArrayDiff(p, path, a, b.(IntArray), func(v int32) Tag { return Int(v) })
+	case LongArray:
+		return appendArrayDiff(p, path, a, b.(LongArray), func(v int64) Tag { return Long(v) })
+	}
+
+	if !tagsEqual(a, b) {
+		p = append(p, Change{Kind: ChangeValue, Path: path, Old: a, New: b})
+	}
+	return p
+}
+
+func appendArrayDiff[S interface {
+	Tag
+	~[]E
+}, E comparable](p Patch, path string, a, b S, elem func(E) Tag) Patch {
+	if len(a) != len(b) {
+		return append(p, Change{Kind: ChangeValue, Path: path, Old: a, New: b})
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			p = append(p, Change{Kind: ChangeValue, Path: joinPathIndex(path, i), Old: elem(a[i]), New: elem(b[i])})
+		}
+	}
+	return p
+}
+
+// tagsEqual reports whether two tags are identical, down to the bits of their
+// floats and the element types of empty lists.
+func tagsEqual(a, b Tag) bool {
+	switch a := a.(type) {
+	case Float:
+		b, ok := b.(Float)
+		return ok && math.Float32bits(float32(a)) == math.Float32bits(float32(b))
+	case Double:
+		b, ok := b.(Double)
+		return ok && math.Float64bits(float64(a)) == math.Float64bits(float64(b))
+	case ByteArray:
+		b, ok := b.(ByteArray)
+		return ok && slices.Equal(a, b)
+	case IntArray:
+		b, ok := b.(IntArray)
+		return ok && slices.Equal(a, b)
+	case LongArray:
+		b, ok := b.(LongArray)
+		return ok && slices.Equal(a, b)
+	case *List:
+		b, ok := b.(*List)
+		return ok && a.ElemType == b.ElemType && slices.EqualFunc(a.Elems, b.Elems, tagsEqual)
+	case *Compound:
+		b, ok := b.(*Compound)
+		return ok && slices.EqualFunc(a.Entries, b.Entries, func(x, y NamedTag) bool {
+			return x.Name == y.Name && tagsEqual(x.Value, y.Value)
+		})
+	}
+	return a == b
+}
+
+func joinPathName(path, name string) string {
+	if path == "" {
+		return quotePathName(name)
+	}
+	return path + "." + quotePathName(name)
+}
+
+func joinPathIndex(path string, i int) string {
+	return path + "[" + strconv.Itoa(i) + "]"
+}
+
+// Apply applies the patch to root, modifying it in place. Each change is
+// checked against root before it is made: removed and changed tags must still
+// have their old value, and added compound entries must not exist yet.
+// Changes before the first one that fails have already been applied.
+//
+// A change to the root itself replaces the contents of a root compound or
+// list. Other roots, such as a TAG_Int, can't be modified in place, so Diff of
+// two such roots, or of roots of different types, gives a patch that Apply
+// rejects; use the change's New tag as the result instead.
+func (p Patch) Apply(root Tag) error {
+	for _, c := range p {
+		if err := c.apply(root); err != nil {
+			return fmt.Errorf("nbt: failed to apply change to %s: %w", c.pathString(), err)
+		}
+	}
+	return nil
+}
+
+func (c Change) apply(root Tag) error {
+	if c.Path == "" {
+		return c.applyRoot(root)
+	}
+	path, err := ParsePath(c.Path)
+	if err != nil {
+		return err
+	}
+	last := &path.nodes[len(path.nodes)-1]
+	parents := path.walk(root, len(path.nodes)-1, false)
+	if len(parents) != 1 || (last.kind != nodeName && last.kind != nodeIndex) || last.filter != nil {
+		return fmt.Errorf("path doesn't select a single tag")
+	}
+	parent := parents[0]
+
+	if c.Kind == ChangeAdded {
+		if c.New == nil {
+			return errors.New("no value to add")
+		}
+		switch t := parent.tag.(type) {
+		case *Compound:
+			if last.kind != nodeName {
+				break
+			}
+			if _, ok := t.Get(last.name); ok {
+				return errors.New("tag already exists")
+			}
+			t.Set(last.name, CloneTag(c.New))
+			return nil
+		case *List:
+			if last.kind != nodeIndex || last.index < 0 || last.index > len(t.Elems) {
+				break
+			}
+			if len(t.Elems) > 0 && t.ElemType != c.New.TagType() {
+				return fmt.Errorf("can't insert %s into TAG_List of %s", TagName(c.New.TagType()), TagName(t.ElemType))
+			}
+			t.ElemType = c.New.TagType()
+			t.Elems = slices.Insert(t.Elems, last.index, CloneTag(c.New))
+			return nil
+		}
+		return ErrPathNotFound
+	}
+
+	matches := last.appendMatches(nil, parent, false, nil)
+	if len(matches) != 1 {
+		return ErrPathNotFound
+	}
+	if c.Old == nil || !tagsEqual(matches[0].tag, c.Old) {
+		return errors.New("tag doesn't have the expected old value")
+	}
+
+	switch c.Kind {
+	case ChangeRemoved:
+		if last.kind == nodeName {
+			parent.tag.(*Compound).Delete(last.name)
+			return nil
+		}
+		_, err := last.removeElems(parent)
+		return err
+	case ChangeValue, ChangeType:
+		if c.New == nil {
+			return errors.New("no new value")
+		}
+		return matches[0].set(CloneTag(c.New))
+	}
+	return fmt.Errorf("unknown change kind %d", c.Kind)
+}
+
+// applyRoot replaces the contents of root with c.New, if they're the same
+// kind of pointer type.
+func (c Change) applyRoot(root Tag) error {
+	if c.Kind != ChangeValue && c.Kind != ChangeType {
+		return errors.New("can't add or remove the root tag")
+	}
+	if c.Old == nil || !tagsEqual(root, c.Old) {
+		return errors.New("tag doesn't have the expected old value")
+	}
+	switch r := root.(type) {
+	case *Compound:
+		if n, ok := c.New.(*Compound); ok {
+			*r = *CloneTag(n).(*Compound)
+			return nil
+		}
+	case *List:
+		if n, ok := c.New.(*List); ok {
+			*r = *CloneTag(n).(*List)
+			return nil
+		}
+	}
+	if c.New == nil {
+		return errors.New("no new value")
+	}
+	return fmt.Errorf("can't replace a root %s with %s in place", TagName(root.TagType()), TagName(c.New.TagType()))
+}
+
+func (c Change) pathString() string {
+	if c.Path == "" {
+		return "(root)"
+	}
+	return c.Path
+}
+
+// String formats the change as a single line, starting with '+' for an added
+// tag, '-' for a removed tag, '~' for a changed value or '!' for a changed
+// type, followed by the path and the values in SNBT, eg.
+// `~ Data.Time: 1000L -> 2000L`.
+func (c Change) String() string {
+	switch c.Kind {
+	case ChangeAdded:
+		return "+ " + c.pathString() + ": " + formatChangeValue(c.New)
+	case ChangeRemoved:
+		return "- " + c.pathString() + ": " + formatChangeValue(c.Old)
+	case ChangeValue:
+		return "~ " + c.pathString() + ": " + formatChangeValue(c.Old) + " -> " + formatChangeValue(c.New)
+	case ChangeType:
+		return "! " + c.pathString() + ": " + formatChangeValue(c.Old) + " -> " + formatChangeValue(c.New)
+	}
+	return fmt.Sprintf("? %s", c.pathString())
+}
+
+func formatChangeValue(tag Tag) string {
+	if tag == nil {
+		return "(none)"
+	}
+	s, err := FormatSNBT(tag, "")
+	if err != nil {
+		return fmt.Sprintf("%v", tag)
+	}
+	return s
+}
+
+// String formats the patch with one change per line.
+func (p Patch) String() string {
+	var sb strings.Builder
+	for _, c := range p {
+		sb.WriteString(c.String())
+		sb.WriteByte('\n')
+	}
+	return sb.String()
+}
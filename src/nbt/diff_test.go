@@ -0,0 +1,134 @@
+package nbt
+
+import (
+	"testing"
+)
+
+func TestDiffApply(t *testing.T) {
+	parse := func(s string) Tag {
+		t.Helper()
+		tag, err := ParseSNBTTag(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return tag
+	}
+
+	a := parse(`{Data:{
+		Time: 1000L,
+		SpawnY: 64,
+		WanderingTraderId: [I; 1, 2, 3, 4],
+		Heights: [L; 1L, 2L, 3L],
+		"dotted.name": 1b,
+		Player: {Inventory: [{Slot: 0b, id: "minecraft:dirt"}, {Slot: 1b}, {Slot: 2b}]},
+		Empty: []
+	}}`)
+	b := parse(`{Data:{
+		Time: 2000L,
+		SpawnY: 64s,
+		Heights: [L; 1L, 5L, 3L],
+		"dotted.name": 2b,
+		Player: {Inventory: [{Slot: 0b, id: "minecraft:stone"}], XpLevel: 3},
+		Empty: ["x"],
+		Raining: 1b
+	}}`)
+
+	patch := Diff(a, b)
+	want := `~ Data.Time: 1000L -> 2000L
+! Data.SpawnY: 64 -> 64s
+- Data.WanderingTraderId: [I; 1, 2, 3, 4]
+~ Data.Heights[1]: 2L -> 5L
+~ Data."dotted.name": 1b -> 2b
+~ Data.Player.Inventory[0].id: "minecraft:dirt" -> "minecraft:stone"
+- Data.Player.Inventory[2]: {Slot: 2b}
+- Data.Player.Inventory[1]: {Slot: 1b}
++ Data.Player.XpLevel: 3
+~ Data.Empty: [] -> ["x"]
++ Data.Raining: 1b
+`
+	if got := patch.String(); got != want {
+		t.Errorf("Diff:\n%s\nwant:\n%s", got, want)
+	}
+
+	if err := patch.Apply(a); err != nil {
+		t.Fatal(err)
+	}
+	if !tagsEqual(a, b) {
+		t.Errorf("applying the patch didn't reproduce b:\n%s", Diff(a, b))
+	}
+	if len(Diff(a, b)) != 0 {
+		t.Errorf("trees differ after applying the patch")
+	}
+
+	// the tree has changed, so the patch no longer applies
+	if err := patch.Apply(a); err == nil {
+		t.Errorf("applying the patch twice succeeded")
+	}
+}
+
+func TestDiffListElemType(t *testing.T) {
+	// empty lists are only equal if their element types are
+	a, b := &Compound{}, &Compound{}
+	a.Set("Pos", &List{ElemType: TAG_End})
+	b.Set("Pos", &List{ElemType: TAG_Double})
+
+	patch := Diff(a, b)
+	if len(patch) != 1 || patch[0].Kind != ChangeValue || patch[0].Path != "Pos" {
+		t.Fatalf("Diff = %v, want a single value change of Pos", patch)
+	}
+	if err := patch.Apply(a); err != nil {
+		t.Fatal(err)
+	}
+	if pos, _ := a.Get("Pos"); pos.(*List).ElemType != TAG_Double {
+		t.Errorf("applying the patch gave a list of %s", TagName(pos.(*List).ElemType))
+	}
+	if len(Diff(a, b)) != 0 || !tagsEqual(a, b) {
+		t.Errorf("trees differ after applying the patch")
+	}
+}
+
+func TestDiffRoot(t *testing.T) {
+	parse := func(s string) Tag {
+		t.Helper()
+		tag, err := ParseSNBTTag(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return tag
+	}
+
+	// a root list's contents are replaced when its element type changes
+	a, b := parse(`[1b, 2b]`), parse(`["x"]`)
+	patch := Diff(a, b)
+	if len(patch) != 1 || patch[0].Path != "" {
+		t.Fatalf("Diff = %v, want a single change of the root", patch)
+	}
+	if err := patch.Apply(a); err != nil {
+		t.Fatal(err)
+	}
+	if !tagsEqual(a, b) {
+		t.Errorf("applying the patch gave %v, want %v", a, b)
+	}
+	// and the new value is copied, not shared
+	b.(*List).Elems[0] = String("y")
+	if tagsEqual(a, b) {
+		t.Errorf("changing the new tree changed the patched one")
+	}
+
+	// other roots can't be changed in place
+	for _, tt := range []struct{ a, b string }{
+		{`1`, `2`},
+		{`1`, `1b`},
+		{`{}`, `[]`},
+		{`[I; 1]`, `[I; 1, 2]`},
+	} {
+		a, b := parse(tt.a), parse(tt.b)
+		patch := Diff(a, b)
+		if len(patch) != 1 || patch[0].Path != "" {
+			t.Fatalf("Diff(%s, %s) = %v, want a single change of the root", tt.a, tt.b, patch)
+		}
+		if err := patch.Apply(a); err == nil {
+			t.Errorf("Diff(%s, %s).Apply succeeded", tt.a, tt.b)
+		}
+	}
+}
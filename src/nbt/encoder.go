@@ -0,0 +1,574 @@
+package nbt
+
+import (
+	"encoding"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"slices"
+)
+
+// NBT encoder and marshaler.
+// Mirrors the reflection rules used by NBTDecoder, so that anything Decode can
+// read can be written back out again.
+
+// NBTMarshaler allows callers to implement custom marshaling logic. TagType
+// reports the type of the tag whose payload MarshalNBT writes.
+type NBTMarshaler interface {
+	TagType() byte
+	MarshalNBT(w io.Writer) error
+}
+
+type NBTEncoder struct {
+	w      io.Writer
+	buf    [10]byte
+	strBuf []byte // scratch space for encoding strings
+
+	variant      Variant
+	namelessRoot bool
+}
+
+func NewEncoder(w io.Writer) *NBTEncoder {
+	return &NBTEncoder{w: w}
+}
+
+// SetVariant selects the binary layout of the NBT being written (JavaEdition
+// by default).
+func (e *NBTEncoder) SetVariant(v Variant) {
+	e.variant = v
+}
+
+// SetNamelessRoot controls whether the root tag is written without a name, as
+// expected by Java protocol 764+ (1.20.2) network NBT. Bedrock network NBT
+// still has a root name, usually empty.
+func (e *NBTEncoder) SetNamelessRoot(nameless bool) {
+	e.namelessRoot = nameless
+}
+
+// Encodes v as a named NBT tag into the encoder's writer.
+//
+// Go values map onto tag types as follows:
+//   - bool, int8, uint8: TAG_Byte
+//   - int16, uint16: TAG_Short
+//   - int, uint, int32, uint32: TAG_Int
+//   - int64, uint64: TAG_Long
+//   - float32: TAG_Float
+//   - float64: TAG_Double
+//   - string (or encoding.TextMarshaler): TAG_String
+//   - []byte, []int8: TAG_Byte_Array
+//   - []int, []int32: TAG_Int_Array
+//   - []int64: TAG_Long_Array
+//   - any other slice or array: TAG_List
+//   - structs and maps with string keys: TAG_Compound
+//
+// Nil pointers, nil interfaces, empty RawMessages and fields tagged with
+// omitempty that hold their zero value are left out of the enclosing compound.
+func (e *NBTEncoder) Encode(name string, v any) error {
+	val := reflect.ValueOf(v)
+	tagType, err := tagTypeOf(val)
+	if err != nil {
+		return fmt.Errorf("nbt: failed to encode tag %q: %w", name, err)
+	}
+
+	if e.namelessRoot {
+		err = e.WriteInt8(int8(tagType))
+	} else {
+		err = e.WriteTagHeader(tagType, name)
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := e.marshal(val, tagType); err != nil {
+		return fmt.Errorf("nbt: failed to encode tag %q: %w", name, err)
+	}
+	return nil
+}
+
+var (
+	marshalerType     = reflect.TypeFor[NBTMarshaler]()
+	textMarshalerType = reflect.TypeFor[encoding.TextMarshaler]()
+	rawMessageType    = reflect.TypeFor[RawMessage]()
+)
+
+// marshalerOf returns the NBTMarshaler or encoding.TextMarshaler implemented
+// by v (or by its address, if v is addressable), if any.
+func marshalerOf(v reflect.Value) (NBTMarshaler, encoding.TextMarshaler) {
+	if (v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface) && v.IsNil() {
+		return nil, nil
+	}
+	if v.Kind() != reflect.Pointer && v.CanAddr() && reflect.PointerTo(v.Type()).Implements(marshalerType) {
+		v = v.Addr()
+	}
+	if v.Type().Implements(marshalerType) && v.CanInterface() {
+		return v.Interface().(NBTMarshaler), nil
+	}
+	if v.Kind() != reflect.Pointer && v.CanAddr() && reflect.PointerTo(v.Type()).Implements(textMarshalerType) {
+		v = v.Addr()
+	}
+	if v.Type().Implements(textMarshalerType) && v.CanInterface() {
+		return nil, v.Interface().(encoding.TextMarshaler)
+	}
+	return nil, nil
+}
+
+// tagTypeOf determines which tag type v will be written as.
+func tagTypeOf(v reflect.Value) (byte, error) {
+	if !v.IsValid() {
+		return TAG_End, errors.New("can't marshal nil value")
+	}
+	if tag, ok := treeTagOf(v); ok {
+		return tag.TagType(), nil
+	}
+
+	m, t := marshalerOf(v)
+	if m != nil {
+		return m.TagType(), nil
+	}
+	if t != nil {
+		return TAG_String, nil
+	}
+
+	switch vk := v.Kind(); vk {
+	case reflect.Pointer, reflect.Interface:
+		if v.IsNil() {
+			return TAG_End, fmt.Errorf("can't marshal nil %s", v.Type().String())
+		}
+		return tagTypeOf(v.Elem())
+	case reflect.Bool, reflect.Int8, reflect.Uint8:
+		return TAG_Byte, nil
+	case reflect.Int16, reflect.Uint16:
+		return TAG_Short, nil
+	case reflect.Int, reflect.Uint, reflect.Int32, reflect.Uint32:
+		return TAG_Int, nil
+	case reflect.Int64, reflect.Uint64:
+		return TAG_Long, nil
+	case reflect.Float32:
+		return TAG_Float, nil
+	case reflect.Float64:
+		return TAG_Double, nil
+	case reflect.String:
+		return TAG_String, nil
+	case reflect.Struct:
+		return TAG_Compound, nil
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			return TAG_End, fmt.Errorf("can't marshal %q as TAG_Compound", v.Type().String())
+		}
+		return TAG_Compound, nil
+	case reflect.Slice, reflect.Array:
+		switch v.Type().Elem().Kind() {
+		case reflect.Uint8, reflect.Int8:
+			return TAG_Byte_Array, nil
+		case reflect.Int, reflect.Int32:
+			return TAG_Int_Array, nil
+		case reflect.Int64:
+			return TAG_Long_Array, nil
+		}
+		return TAG_List, nil
+	default:
+		return TAG_End, fmt.Errorf("can't marshal go type %q", v.Type().String())
+	}
+}
+
+// Writes the tag body for v (determined by tagType) to the encoder's writer.
+func (e *NBTEncoder) marshal(val reflect.Value, tagType byte) error {
+	// walk down pointers and interfaces until we reach a concrete value (or a
+	// marshaler)
+	for {
+		if tag, ok := treeTagOf(val); ok {
+			return e.writeTag(tag)
+		}
+		m, t := marshalerOf(val)
+		if m != nil {
+			return m.MarshalNBT(e.w)
+		}
+		if t != nil {
+			text, err := t.MarshalText()
+			if err != nil {
+				return err
+			}
+			return e.WriteString(string(text))
+		}
+
+		if val.Kind() != reflect.Pointer && val.Kind() != reflect.Interface {
+			break
+		}
+		if val.IsNil() {
+			return fmt.Errorf("can't marshal nil %s", val.Type().String())
+		}
+		val = val.Elem()
+	}
+
+	switch tagType {
+	case TAG_Byte:
+		switch vk := val.Kind(); vk {
+		case reflect.Bool:
+			var b int8
+			if val.Bool() {
+				b = 1
+			}
+			return e.WriteInt8(b)
+		case reflect.Int8:
+			return e.WriteInt8(int8(val.Int()))
+		case reflect.Uint8:
+			return e.WriteInt8(int8(val.Uint()))
+		default:
+			return fmt.Errorf("can't marshal go type %q as TAG_Byte", vk.String())
+		}
+	case TAG_Short:
+		switch vk := val.Kind(); vk {
+		case reflect.Int16:
+			return e.WriteInt16(int16(val.Int()))
+		case reflect.Uint16:
+			return e.WriteInt16(int16(val.Uint()))
+		default:
+			return fmt.Errorf("can't marshal go type %q as TAG_Short", vk.String())
+		}
+	case TAG_Int:
+		switch vk := val.Kind(); vk {
+		case reflect.Int, reflect.Int32:
+			value := val.Int()
+			if value < math.MinInt32 || value > math.MaxInt32 {
+				return fmt.Errorf("value %d overflows TAG_Int", value)
+			}
+			return e.WriteInt32(int32(value))
+		case reflect.Uint, reflect.Uint32:
+			value := val.Uint()
+			if value > math.MaxUint32 {
+				return fmt.Errorf("value %d overflows TAG_Int", value)
+			}
+			return e.WriteInt32(int32(value))
+		default:
+			return fmt.Errorf("can't marshal go type %q as TAG_Int", vk.String())
+		}
+	case TAG_Long:
+		switch vk := val.Kind(); vk {
+		case reflect.Int64:
+			return e.WriteInt64(val.Int())
+		case reflect.Uint64:
+			return e.WriteInt64(int64(val.Uint()))
+		default:
+			return fmt.Errorf("can't marshal go type %q as TAG_Long", vk.String())
+		}
+	case TAG_Float:
+		if vk := val.Kind(); vk != reflect.Float32 {
+			return fmt.Errorf("can't marshal go type %q as TAG_Float", vk.String())
+		}
+		return e.WriteFloat32(float32(val.Float()))
+	case TAG_Double:
+		if vk := val.Kind(); vk != reflect.Float64 {
+			return fmt.Errorf("can't marshal go type %q as TAG_Double", vk.String())
+		}
+		return e.WriteFloat64(val.Float())
+	case TAG_String:
+		if vk := val.Kind(); vk != reflect.String {
+			return fmt.Errorf("can't marshal go type %q as TAG_String", vk.String())
+		}
+		return e.WriteString(val.String())
+	case TAG_Byte_Array:
+		if err := e.writeArrayLen(val); err != nil {
+			return err
+		}
+		if val.Kind() == reflect.Slice && val.Type().Elem().Kind() == reflect.Uint8 {
+			_, err := e.w.Write(val.Bytes())
+			return err
+		}
+		for i := 0; i < val.Len(); i++ {
+			elem := val.Index(i)
+			var b int8
+			if elem.Kind() == reflect.Uint8 {
+				b = int8(elem.Uint())
+			} else {
+				b = int8(elem.Int())
+			}
+			if err := e.WriteInt8(b); err != nil {
+				return err
+			}
+		}
+	case TAG_Int_Array:
+		if err := e.writeArrayLen(val); err != nil {
+			return err
+		}
+		for i := 0; i < val.Len(); i++ {
+			value := val.Index(i).Int()
+			if value < math.MinInt32 || value > math.MaxInt32 {
+				return fmt.Errorf("value %d at index %d overflows TAG_Int_Array", value, i)
+			}
+			if err := e.WriteInt32(int32(value)); err != nil {
+				return err
+			}
+		}
+	case TAG_Long_Array:
+		if err := e.writeArrayLen(val); err != nil {
+			return err
+		}
+		for i := 0; i < val.Len(); i++ {
+			if err := e.WriteInt64(val.Index(i).Int()); err != nil {
+				return err
+			}
+		}
+	case TAG_List:
+		if vk := val.Kind(); vk != reflect.Slice && vk != reflect.Array {
+			return fmt.Errorf("can't marshal go type %q as TAG_List", vk.String())
+		}
+
+		// the element type of the list comes from the static element type of the
+		// slice if possible. for interface slices (eg. []any), all elements must
+		// agree with the type of the first one, and empty lists are written as
+		// lists of TAG_End.
+		listType := byte(TAG_End)
+		if ek := val.Type().Elem().Kind(); val.Len() > 0 || (ek != reflect.Interface && ek != reflect.Pointer) {
+			elem := reflect.New(val.Type().Elem()).Elem()
+			if val.Len() > 0 {
+				elem = val.Index(0)
+			}
+			var err error
+			listType, err = tagTypeOf(elem)
+			if err != nil {
+				return fmt.Errorf("failed to encode index 0 in TAG_List: %w", err)
+			}
+		}
+
+		if err := e.WriteInt8(int8(listType)); err != nil {
+			return err
+		}
+		if err := e.writeArrayLen(val); err != nil {
+			return err
+		}
+
+		for i := 0; i < val.Len(); i++ {
+			elem := val.Index(i)
+			if elem.Kind() == reflect.Interface {
+				elemType, err := tagTypeOf(elem)
+				if err != nil {
+					return fmt.Errorf("failed to encode index %d in TAG_List: %w", i, err)
+				}
+				if elemType != listType {
+					return fmt.Errorf("can't marshal tag type %#02x at index %d into TAG_List of type %#02x", elemType, i, listType)
+				}
+			}
+			if err := e.marshal(elem, listType); err != nil {
+				return fmt.Errorf("failed to encode index %d in TAG_List: %w", i, err)
+			}
+		}
+	case TAG_Compound:
+		switch vk := val.Kind(); vk {
+		case reflect.Struct:
+			fields := cachedTypeFields(val.Type())
+			for _, f := range fields.list {
+				fv, ok := fieldByIndex(val, f.index)
+				if !ok {
+					// field lives behind a nil embedded pointer
+					continue
+				}
+				if f.omitEmpty && isEmptyValue(fv) {
+					continue
+				}
+				if f.asString && !((fv.Kind() == reflect.Pointer || fv.Kind() == reflect.Interface) && fv.IsNil()) {
+					str, err := formatAsString(fv)
+					if err != nil {
+						return fmt.Errorf("failed to encode field %q in TAG_Compound: %w", f.name, err)
+					}
+					if err := e.WriteTagHeader(TAG_String, f.name); err != nil {
+						return err
+					}
+					if err := e.WriteString(str); err != nil {
+						return err
+					}
+					continue
+				}
+				if err := e.writeField(f.name, fv); err != nil {
+					return err
+				}
+			}
+		case reflect.Map:
+			if val.Type().Key().Kind() != reflect.String {
+				return fmt.Errorf("can't marshal %q as TAG_Compound", val.Type().String())
+			}
+			// sort keys so that the output is deterministic
+			keys := val.MapKeys()
+			slices.SortFunc(keys, func(a, b reflect.Value) int {
+				if a.String() < b.String() {
+					return -1
+				}
+				if a.String() > b.String() {
+					return 1
+				}
+				return 0
+			})
+			for _, k := range keys {
+				if err := e.writeField(k.String(), val.MapIndex(k)); err != nil {
+					return err
+				}
+			}
+		default:
+			return fmt.Errorf("can't marshal go type %q as TAG_Compound", vk.String())
+		}
+		return e.WriteInt8(TAG_End)
+	default:
+		return fmt.Errorf("can't marshal unknown tag type %#02x", tagType)
+	}
+	return nil
+}
+
+// writeField writes a single named tag inside a TAG_Compound. Nil pointers and
+// interfaces have no NBT representation, and an empty RawMessage holds no
+// tag, so they are skipped.
+func (e *NBTEncoder) writeField(name string, fv reflect.Value) error {
+	if isAbsent(fv) {
+		return nil
+	}
+
+	fieldTagType, err := tagTypeOf(fv)
+	if err != nil {
+		return fmt.Errorf("failed to encode field %q in TAG_Compound: %w", name, err)
+	}
+	if err := e.WriteTagHeader(fieldTagType, name); err != nil {
+		return err
+	}
+	if err := e.marshal(fv, fieldTagType); err != nil {
+		// wrap error so we know where it's coming from
+		return fmt.Errorf("failed to encode field %q in TAG_Compound: %w", name, err)
+	}
+	return nil
+}
+
+func (e *NBTEncoder) writeArrayLen(val reflect.Value) error {
+	if val.Len() > math.MaxInt32 {
+		return fmt.Errorf("length %d is too long for an NBT array", val.Len())
+	}
+	return e.WriteInt32(int32(val.Len()))
+}
+
+// fieldByIndex walks down an index sequence produced by typeFields, following
+// embedded pointers. Returns false if a nil embedded pointer is encountered.
+func fieldByIndex(val reflect.Value, index []int) (reflect.Value, bool) {
+	for _, i := range index {
+		if val.Kind() == reflect.Pointer {
+			if val.IsNil() {
+				return reflect.Value{}, false
+			}
+			val = val.Elem()
+		}
+		val = val.Field(i)
+	}
+	return val, true
+}
+
+// isAbsent reports whether v has no NBT representation as a compound entry.
+func isAbsent(v reflect.Value) bool {
+	if v.Kind() == reflect.Interface && !v.IsNil() {
+		v = v.Elem()
+	}
+	if (v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface) && v.IsNil() {
+		return true
+	}
+	return v.Type() == rawMessageType && v.Len() == 0
+}
+
+// copied from encoding/json
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64,
+		reflect.Interface, reflect.Pointer:
+		return v.IsZero()
+	}
+	return false
+}
+
+// Write primitives
+
+func (e *NBTEncoder) WriteTagHeader(tagType byte, tagName string) error {
+	if err := e.WriteInt8(int8(tagType)); err != nil {
+		return err
+	}
+	if tagType == TAG_End {
+		// TAG_End does not have a tagname
+		return nil
+	}
+	return e.WriteString(tagName)
+}
+
+func (e *NBTEncoder) WriteInt8(v int8) error {
+	e.buf[0] = byte(v)
+	_, err := e.w.Write(e.buf[:1])
+	return err
+}
+func (e *NBTEncoder) WriteInt16(v int16) error {
+	e.variant.byteOrder().PutUint16(e.buf[:2], uint16(v))
+	_, err := e.w.Write(e.buf[:2])
+	return err
+}
+func (e *NBTEncoder) WriteInt32(v int32) error {
+	if e.variant == BedrockNetwork {
+		_, err := e.w.Write(appendUvarint(e.buf[:0], uint64(zigzag32(v))))
+		return err
+	}
+	e.variant.byteOrder().PutUint32(e.buf[:4], uint32(v))
+	_, err := e.w.Write(e.buf[:4])
+	return err
+}
+func (e *NBTEncoder) WriteInt64(v int64) error {
+	if e.variant == BedrockNetwork {
+		_, err := e.w.Write(appendUvarint(e.buf[:0], zigzag64(v)))
+		return err
+	}
+	e.variant.byteOrder().PutUint64(e.buf[:8], uint64(v))
+	_, err := e.w.Write(e.buf[:8])
+	return err
+}
+func (e *NBTEncoder) WriteFloat32(v float32) error {
+	e.variant.byteOrder().PutUint32(e.buf[:4], math.Float32bits(v))
+	_, err := e.w.Write(e.buf[:4])
+	return err
+}
+func (e *NBTEncoder) WriteFloat64(v float64) error {
+	e.variant.byteOrder().PutUint64(e.buf[:8], math.Float64bits(v))
+	_, err := e.w.Write(e.buf[:8])
+	return err
+}
+
+// writeStringLen writes the length prefix of a string (see readStringLen)
+func (e *NBTEncoder) writeStringLen(n int) error {
+	switch e.variant {
+	case BedrockNetwork:
+		if n > math.MaxInt32 {
+			return fmt.Errorf("string of length %d is too long for TAG_String", n)
+		}
+		_, err := e.w.Write(appendUvarint(e.buf[:0], uint64(n)))
+		return err
+	default:
+		if n > math.MaxUint16 {
+			return fmt.Errorf("string of length %d is too long for TAG_String", n)
+		}
+		return e.WriteInt16(int16(uint16(n)))
+	}
+}
+
+// Writes a string. Java Edition strings are encoded as Modified UTF-8 (see
+// mutf8.go), while Bedrock strings are written as plain UTF-8.
+func (e *NBTEncoder) WriteString(s string) error {
+	if e.variant != JavaEdition {
+		if err := e.writeStringLen(len(s)); err != nil {
+			return err
+		}
+		_, err := io.WriteString(e.w, s)
+		return err
+	}
+
+	encoded := appendMUTF8(e.strBuf[:0], s)
+	e.strBuf = encoded[:0]
+	if err := e.writeStringLen(len(encoded)); err != nil {
+		return err
+	}
+	_, err := e.w.Write(encoded)
+	return err
+}
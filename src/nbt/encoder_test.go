@@ -0,0 +1,244 @@
+package nbt
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+func TestEncodeBytes(t *testing.T) {
+	tests := []struct {
+		name string
+		v    any
+		want []byte
+	}{
+		{"b", int8(-1), []byte{TAG_Byte, 0, 1, 'b', 0xff}},
+		{"b", true, []byte{TAG_Byte, 0, 1, 'b', 1}},
+		{"s", uint16(0x1234), []byte{TAG_Short, 0, 1, 's', 0x12, 0x34}},
+		{"i", 1, []byte{TAG_Int, 0, 1, 'i', 0, 0, 0, 1}},
+		{"l", int64(-2), []byte{TAG_Long, 0, 1, 'l', 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xfe}},
+		{"f", float32(1), []byte{TAG_Float, 0, 1, 'f', 0x3f, 0x80, 0, 0}},
+		{"d", -2.0, []byte{TAG_Double, 0, 1, 'd', 0xc0, 0, 0, 0, 0, 0, 0, 0}},
+		{"", "hi", []byte{TAG_String, 0, 0, 0, 2, 'h', 'i'}},
+		{"", []byte{1, 2}, []byte{TAG_Byte_Array, 0, 0, 0, 0, 0, 2, 1, 2}},
+		{"", []int32{-1}, []byte{TAG_Int_Array, 0, 0, 0, 0, 0, 1, 0xff, 0xff, 0xff, 0xff}},
+		{"", [1]int64{1}, []byte{TAG_Long_Array, 0, 0, 0, 0, 0, 1, 0, 0, 0, 0, 0, 0, 0, 1}},
+		{"", []int16{1, 2}, []byte{TAG_List, 0, 0, TAG_Short, 0, 0, 0, 2, 0, 1, 0, 2}},
+		{"", []string{}, []byte{TAG_List, 0, 0, TAG_String, 0, 0, 0, 0}},
+		{"", []any{}, []byte{TAG_List, 0, 0, TAG_End, 0, 0, 0, 0}},
+		{"", map[string]int8{"b": 2, "a": 1}, []byte{
+			TAG_Compound, 0, 0,
+			TAG_Byte, 0, 1, 'a', 1,
+			TAG_Byte, 0, 1, 'b', 2,
+			TAG_End,
+		}},
+		{"", struct {
+			A *int32 `nbt:"a"`
+			B string `nbt:"b,omitempty"`
+			C int16  `nbt:"-"`
+			D int16
+		}{C: 1, D: 2}, []byte{
+			TAG_Compound, 0, 0,
+			TAG_Short, 0, 1, 'D', 0, 2,
+			TAG_End,
+		}},
+	}
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		if err := NewEncoder(&buf).Encode(tt.name, tt.v); err != nil {
+			t.Errorf("Encode(%#v): %v", tt.v, err)
+		} else if !bytes.Equal(buf.Bytes(), tt.want) {
+			t.Errorf("Encode(%#v) = %x, want %x", tt.v, buf.Bytes(), tt.want)
+		}
+	}
+}
+
+type encodeLevel struct {
+	LevelName   string            `nbt:"LevelName"`
+	Hardcore    bool              `nbt:"hardcore"`
+	Difficulty  int8              `nbt:"Difficulty"`
+	SpawnY      int               `nbt:"SpawnY"`
+	Time        int64             `nbt:"Time"`
+	BorderSize  float64           `nbt:"BorderSize"`
+	Temperature float32           `nbt:"Temperature"`
+	Icon        []byte            `nbt:"Icon"`
+	UUID        []int32           `nbt:"UUID"`
+	Heights     []int64           `nbt:"Heights"`
+	Pos         [3]float64        `nbt:"Pos"`
+	Tags        []string          `nbt:"Tags"`
+	Player      *encodePlayer     `nbt:"Player"`
+	Inventory   []encodeItem      `nbt:"Inventory"`
+	GameRules   map[string]string `nbt:"GameRules"`
+	Unset       *encodePlayer     `nbt:"Unset"`
+	Extra       any               `nbt:"Extra"`
+}
+
+type encodePlayer struct {
+	Name  string `nbt:"Name"`
+	Score int32  `nbt:"Score"`
+}
+
+type encodeItem struct {
+	Slot  int8   `nbt:"Slot"`
+	ID    string `nbt:"id"`
+	Count int8   `nbt:"Count,omitempty"`
+}
+
+func TestEncodeRoundTrip(t *testing.T) {
+	in := encodeLevel{
+		LevelName:   "New World é\U0001F600\x00",
+		Hardcore:    true,
+		Difficulty:  2,
+		SpawnY:      -64,
+		Time:        math.MaxInt64,
+		BorderSize:  5.9999968e7,
+		Temperature: 0.8,
+		Icon:        []byte{0, 1, 0xff},
+		UUID:        []int32{1, -2, 3, math.MinInt32},
+		Heights:     []int64{math.MinInt64, 0},
+		Pos:         [3]float64{0.5, 64, -0.5},
+		Tags:        []string{"a", ""},
+		Player:      &encodePlayer{Name: "Steve", Score: 10},
+		Inventory:   []encodeItem{{Slot: 0, ID: "minecraft:dirt", Count: 64}, {Slot: 1, ID: "minecraft:stone"}},
+		GameRules:   map[string]string{"doDaylightCycle": "true", "keepInventory": "false"},
+		Extra:       map[string]any{"n": int16(3)},
+	}
+	var buf bytes.Buffer
+	if err := NewEncoder(&buf).Encode("Data", in); err != nil {
+		t.Fatal(err)
+	}
+	var out encodeLevel
+	name, err := NewDecoder(bytes.NewReader(buf.Bytes())).Decode(&out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if name != "Data" {
+		t.Errorf("root name %q, want %q", name, "Data")
+	}
+	if !reflect.DeepEqual(out, in) {
+		t.Errorf("round trip changed the value:\n got %+v\nwant %+v", out, in)
+	}
+
+	// the tags written are the ones the decoder's rules map the fields to
+	c := encodeTag(t, in)
+	for name, want := range map[string]byte{
+		"hardcore":  TAG_Byte,
+		"SpawnY":    TAG_Int,
+		"Icon":      TAG_Byte_Array,
+		"UUID":      TAG_Int_Array,
+		"Heights":   TAG_Long_Array,
+		"Pos":       TAG_List,
+		"Player":    TAG_Compound,
+		"GameRules": TAG_Compound,
+	} {
+		if tag, ok := c.Get(name); !ok || tag.TagType() != want {
+			t.Errorf("field %s written as %v, want %s", name, tag, TagName(want))
+		}
+	}
+	if _, ok := c.Get("Unset"); ok {
+		t.Errorf("nil pointer field was written")
+	}
+	inv, _ := c.Get("Inventory")
+	if _, ok := inv.(*List).Elems[1].(*Compound).Get("Count"); ok {
+		t.Errorf("empty omitempty field was written")
+	}
+}
+
+// color is written as a TAG_Int holding 0xRRGGBB, and read back from it.
+type color struct{ R, G, B uint8 }
+
+func (c color) TagType() byte { return TAG_Int }
+
+func (c color) MarshalNBT(w io.Writer) error {
+	return binary.Write(w, binary.BigEndian, int32(c.R)<<16|int32(c.G)<<8|int32(c.B))
+}
+
+func (c *color) UnmarshalNBT(tagType byte, r NBTReader) error {
+	var v int32
+	if err := binary.Read(r, binary.BigEndian, &v); err != nil {
+		return err
+	}
+	*c = color{uint8(v >> 16), uint8(v >> 8), uint8(v)}
+	return nil
+}
+
+// version is written as a string by encoding.TextMarshaler.
+type version struct{ Major, Minor int }
+
+func (v version) MarshalText() ([]byte, error) {
+	return []byte(strconv.Itoa(v.Major) + "." + strconv.Itoa(v.Minor)), nil
+}
+
+func (v *version) UnmarshalText(b []byte) error {
+	_, err := fmt.Sscanf(string(b), "%d.%d", &v.Major, &v.Minor)
+	return err
+}
+
+func TestEncodeMarshalers(t *testing.T) {
+	type display struct {
+		Color   color     `nbt:"color"`
+		Colors  []color   `nbt:"colors"`
+		Ptr     *color    `nbt:"ptr"`
+		Version version   `nbt:"version"`
+		Tag     Tag       `nbt:"tag"`
+		Raw     *Compound `nbt:"raw"`
+	}
+	raw := &Compound{}
+	raw.Set("x", Long(1))
+	in := display{
+		Color:   color{1, 2, 3},
+		Colors:  []color{{4, 5, 6}},
+		Ptr:     &color{7, 8, 9},
+		Version: version{1, 20},
+		Tag:     String("tree"),
+		Raw:     raw,
+	}
+
+	c := encodeTag(t, in)
+	if got, _ := c.Get("color"); got != Int(0x010203) {
+		t.Errorf("NBTMarshaler wrote %v, want %v", got, Int(0x010203))
+	}
+	if got, _ := c.Get("version"); got != String("1.20") {
+		t.Errorf("TextMarshaler wrote %v, want %q", got, "1.20")
+	}
+	if got, _ := c.Get("colors"); got.(*List).ElemType != TAG_Int {
+		t.Errorf("list of marshalers has element type %s", TagName(got.(*List).ElemType))
+	}
+
+	var buf bytes.Buffer
+	if err := NewEncoder(&buf).Encode("", in); err != nil {
+		t.Fatal(err)
+	}
+	var out display
+	if _, err := NewDecoder(&buf).Decode(&out); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(out, in) {
+		t.Errorf("round trip changed the value:\n got %+v\nwant %+v", out, in)
+	}
+}
+
+func TestEncodeErrors(t *testing.T) {
+	tests := map[string]any{
+		"nil":                 nil,
+		"nil map pointer":     (*map[string]int8)(nil),
+		"channel":             make(chan int),
+		"int map keys":        map[int]string{1: "a"},
+		"mixed list":          []any{int8(1), "a"},
+		"TAG_Int overflow":    math.MaxInt32 + 1,
+		"uint overflow":       uint(math.MaxUint32 + 1),
+		"int array overflow":  []int{math.MaxInt32 + 1},
+		"nested unsupported":  struct{ F func() }{func() {}},
+		"unsupported in list": []any{complex(1, 2)},
+	}
+	for name, v := range tests {
+		if err := NewEncoder(io.Discard).Encode("", v); err == nil {
+			t.Errorf("%s: encoding %#v succeeded", name, v)
+		}
+	}
+}
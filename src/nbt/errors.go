@@ -0,0 +1,113 @@
+package nbt
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// DecodeError describes a failure to decode a tag, and where in the input it
+// happened.
+type DecodeError struct {
+	// path to the tag that failed, eg. sections[3].block_states.palette[12].Name
+	// (empty for the root tag)
+	Path string
+	// type of the tag being decoded
+	TagType byte
+	// go type the tag was being decoded into. nil when decoding into a generic
+	// tree (see Tag) or when discarding a tag
+	GoType reflect.Type
+	// position in the input at which the error was detected
+	Offset int64
+	Err    error
+}
+
+func (e *DecodeError) Error() string {
+	path := e.Path
+	if path == "" {
+		path = "(root)"
+	}
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "nbt: failed to decode %s at %s", TagName(e.TagType), path)
+	if e.GoType != nil {
+		fmt.Fprintf(&sb, " into go type %q", e.GoType.String())
+	}
+	fmt.Fprintf(&sb, " (offset %d): %v", e.Offset, e.Err)
+	return sb.String()
+}
+
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+// pathSegment is a single step in the path to the tag currently being decoded:
+// either a compound entry name, or a list index
+type pathSegment struct {
+	name  string
+	index int // -1 for compound entries
+}
+
+// pushName and pushIndex record that the decoder is descending into a
+// compound entry or list element. The matching pop is skipped if decoding the
+// child fails, so that the full path is still available when the error is
+// reported further up.
+func (d *NBTDecoder) pushName(name string) {
+	d.path = append(d.path, pathSegment{name: name, index: -1})
+}
+
+func (d *NBTDecoder) pushIndex(i int) {
+	d.path = append(d.path, pathSegment{index: i})
+}
+
+func (d *NBTDecoder) pop() {
+	d.path = d.path[:len(d.path)-1]
+}
+
+// pathString formats the decoder's current path, in the same syntax used by
+// Minecraft's NBT paths.
+func (d *NBTDecoder) pathString() string {
+	var sb strings.Builder
+	for i, seg := range d.path {
+		if seg.index >= 0 {
+			sb.WriteByte('[')
+			sb.WriteString(strconv.Itoa(seg.index))
+			sb.WriteByte(']')
+			continue
+		}
+		if i > 0 {
+			sb.WriteByte('.')
+		}
+		sb.WriteString(quotePathName(seg.name))
+	}
+	return sb.String()
+}
+
+// quotePathName quotes a compound entry name if it contains characters that
+// have a special meaning in NBT paths.
+func quotePathName(name string) string {
+	if name == "" || strings.ContainsAny(name, " \t\n\"'[]{}.") {
+		return quoteSNBT(name)
+	}
+	return name
+}
+
+// wrapError attaches the decoder's current path and position to err, unless
+// it has already been attached by a more deeply nested tag. The root header
+// has been read by then, so running out of input is always unexpected.
+func (d *NBTDecoder) wrapError(err error, tagType byte, goType reflect.Type) error {
+	if _, ok := err.(*DecodeError); ok {
+		return err
+	}
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return &DecodeError{
+		Path:    d.pathString(),
+		TagType: tagType,
+		GoType:  goType,
+		Offset:  d.r.n,
+		Err:     err,
+	}
+}
@@ -0,0 +1,134 @@
+package nbt
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type errorsChunk struct {
+	Sections []struct {
+		Y           int8 `nbt:"Y"`
+		BlockStates struct {
+			Palette []struct {
+				Name string `nbt:"Name"`
+			} `nbt:"palette"`
+		} `nbt:"block_states"`
+	} `nbt:"sections"`
+}
+
+func TestDecodeErrorPath(t *testing.T) {
+	encode := func(snbt string) []byte {
+		tag, err := ParseSNBTTag(snbt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var buf bytes.Buffer
+		if err := NewEncoder(&buf).Encode("", tag); err != nil {
+			t.Fatal(err)
+		}
+		return buf.Bytes()
+	}
+	nested := encode(`{a: {b: [1, 2]}}`)
+
+	tests := []struct {
+		name    string
+		in      []byte
+		v       any
+		path    string
+		tagType byte
+		goType  reflect.Type
+		offset  int64
+		err     error
+		limits  Limits
+	}{
+		{
+			"mismatched type deep in a chunk",
+			encode(`{sections: [{Y: 0b}, {Y: 1b}, {Y: 2b}, {Y: 3b, block_states: {palette: [{Name: "a"}, {Name: 5}]}}]}`),
+			&errorsChunk{},
+			"sections[3].block_states.palette[1].Name", TAG_Int, reflect.TypeFor[string](), 94, nil, DefaultLimits,
+		},
+		{
+			"truncated input",
+			nested[:len(nested)-6], new(any),
+			"a.b[1]", TAG_Int, reflect.TypeFor[any](), 20, io.ErrUnexpectedEOF, DefaultLimits,
+		},
+		{
+			"truncated input into a tree",
+			nested[:len(nested)-6], new(Tag),
+			"a.b[1]", TAG_Int, nil, 20, io.ErrUnexpectedEOF, DefaultLimits,
+		},
+		{
+			"names that need quoting",
+			encode(`{"a b": {"x.y": 1}}`), new(map[string]map[string]string),
+			`"a b"."x.y"`, TAG_Int, reflect.TypeFor[string](), 19, nil, DefaultLimits,
+		},
+		{
+			"empty names",
+			encode(`{"": {"": 1}}`), new(map[string]map[string]string),
+			`"".""`, TAG_Int, reflect.TypeFor[string](), 13, nil, DefaultLimits,
+		},
+		{
+			"root",
+			encode(`{}`)[:3], new(string),
+			"", TAG_Compound, reflect.TypeFor[*string](), 3, nil, DefaultLimits,
+		},
+		{
+			"limit",
+			encode(`{a: [[[[]]]]}`), new(any),
+			"a[0][0]", TAG_List, reflect.TypeFor[any](), 17, ErrMaxDepth, Limits{MaxDepth: 3},
+		},
+	}
+	for _, tt := range tests {
+		d := NewDecoder(bytes.NewReader(tt.in))
+		d.SetLimits(tt.limits)
+		_, err := d.Decode(tt.v)
+
+		var derr *DecodeError
+		if !errors.As(err, &derr) {
+			t.Errorf("%s: got %v, want a *DecodeError", tt.name, err)
+			continue
+		}
+		if derr.Path != tt.path || derr.TagType != tt.tagType || derr.GoType != tt.goType || derr.Offset != tt.offset {
+			t.Errorf("%s: got %s, %s, %v at offset %d\nwant %s, %s, %v at offset %d", tt.name,
+				derr.Path, TagName(derr.TagType), derr.GoType, derr.Offset,
+				tt.path, TagName(tt.tagType), tt.goType, tt.offset)
+		}
+		if tt.err != nil && !errors.Is(err, tt.err) {
+			t.Errorf("%s: %v doesn't wrap %v", tt.name, err, tt.err)
+		}
+		if tt.path == "" && !strings.Contains(err.Error(), "(root)") {
+			t.Errorf("%s: %q doesn't name the root", tt.name, err)
+		}
+	}
+}
+
+// skipped tags still report where they failed
+func TestDecodeErrorDiscard(t *testing.T) {
+	in := []byte{
+		TAG_Compound, 0, 0,
+		TAG_List, 0, 7, 'u', 'n', 'k', 'n', 'o', 'w', 'n', TAG_String, 0, 0, 0, 2,
+		0, 1, 'a',
+		0, 2, 0xc1, 0xbf,
+		TAG_End,
+	}
+	d := NewDecoder(bytes.NewReader(in))
+	d.SetStrictStrings(true)
+	var v struct{}
+	_, err := d.Decode(&v)
+
+	var derr *DecodeError
+	var merr *MUTF8Error
+	if !errors.As(err, &derr) || !errors.As(err, &merr) {
+		t.Fatalf("got %v, want a *DecodeError wrapping a *MUTF8Error", err)
+	}
+	if derr.Path != "unknown[1]" || derr.TagType != TAG_String || derr.GoType != nil {
+		t.Errorf("got %s, %s, %v, want unknown[1], TAG_String, <nil>", derr.Path, TagName(derr.TagType), derr.GoType)
+	}
+	if merr.Offset != 23 {
+		t.Errorf("MUTF8Error offset %d, want 23", merr.Offset)
+	}
+}
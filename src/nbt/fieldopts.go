@@ -0,0 +1,254 @@
+package nbt
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// fieldopts.go
+// handling of the struct tag options that change how a field is encoded or
+// decoded:
+//
+//	nbt:",omitempty"     the field is not written if it has its zero value
+//	nbt:",inline"        the fields of a struct field are read and written as
+//	                     if they belonged to the parent, like an embedded struct
+//	nbt:",string"        a number or bool is stored as a TAG_String, as in the
+//	                     GameRules compound of level.dat
+//	nbt:",bool"          a bool is decoded from any integer tag, not just a
+//	                     TAG_Byte
+//	nbt:",alias=Name"    a tag called Name is also decoded into the field, if
+//	                     no other field is called Name. May be repeated
+//	nbt:",default=SNBT"  the field is set to the given value when the tag is
+//	                     missing. Bare strings don't need quoting, and since the
+//	                     value may contain commas this must be the last option
+
+// cutDefault splits off a "default=" option, which must come last since its
+// value may itself contain commas
+func (o tagOptions) cutDefault() (tagOptions, string, bool) {
+	s := string(o)
+	if v, ok := strings.CutPrefix(s, "default="); ok {
+		return "", v, true
+	}
+	if i := strings.Index(s, ",default="); i >= 0 {
+		return tagOptions(s[:i]), s[i+len(",default="):], true
+	}
+	return o, "", false
+}
+
+// parseDefault converts the value of a "default=" option into the encoded
+// form of a tag that can be decoded into a field of type t. Numbers are
+// converted to the tag type the encoder would use for t, so that eg.
+// `default=5` works for an int8 field.
+func parseDefault(s string, t reflect.Type) (RawMessage, error) {
+	tag, err := ParseSNBTTag(s)
+	if err != nil {
+		// bare strings such as minecraft:stone aren't valid SNBT
+		tag = String(s)
+	}
+	if want, err := tagTypeOf(reflect.New(t).Elem()); err == nil {
+		tag = convertNumber(tag, want)
+	}
+
+	var buf bytes.Buffer
+	e := NewEncoder(&buf)
+	e.SetNamelessRoot(true)
+	if err := e.Encode("", tag); err != nil {
+		return nil, err
+	}
+	return RawMessage(buf.Bytes()), nil
+}
+
+// convertNumber converts a numeric tag to another numeric tag type. Other tags
+// are returned unchanged.
+func convertNumber(tag Tag, want byte) Tag {
+	var i int64
+	var f float64
+	switch t := tag.(type) {
+	case Byte:
+		i = int64(t)
+	case Short:
+		i = int64(t)
+	case Int:
+		i = int64(t)
+	case Long:
+		i = int64(t)
+	case Float:
+		f = float64(t)
+		i = int64(f)
+	case Double:
+		f = float64(t)
+		i = int64(f)
+	default:
+		return tag
+	}
+	if t := tag.TagType(); t != TAG_Float && t != TAG_Double {
+		f = float64(i)
+	}
+
+	switch want {
+	case TAG_Byte:
+		return Byte(i)
+	case TAG_Short:
+		return Short(i)
+	case TAG_Int:
+		return Int(i)
+	case TAG_Long:
+		return Long(i)
+	case TAG_Float:
+		return Float(f)
+	case TAG_Double:
+		return Double(f)
+	}
+	return tag
+}
+
+// fieldForDecode walks down an index sequence produced by typeFields,
+// allocating any nil embedded (or inlined) pointers along the way.
+func fieldForDecode(val reflect.Value, index []int) (reflect.Value, error) {
+	for _, i := range index {
+		if val.Kind() == reflect.Pointer {
+			if val.IsNil() {
+				// check if the field is an exported value
+				if !val.CanSet() {
+					return reflect.Value{}, fmt.Errorf("cannot set embedded pointer to unexported struct: %v", val.Type().Elem())
+				}
+				val.Set(reflect.New(val.Type().Elem()))
+			}
+			val = val.Elem()
+		}
+		val = val.Field(i)
+	}
+	return val, nil
+}
+
+// unmarshalField decodes a tag into a struct field, applying the field's
+// ",string" and ",bool" options.
+func (d *NBTDecoder) unmarshalField(val reflect.Value, f *field, tagType byte) error {
+	switch {
+	case f.asString && tagType == TAG_String:
+		s, err := d.ReadString()
+		if err == nil {
+			err = setFromString(val, s)
+		}
+		if err != nil {
+			return d.wrapError(err, tagType, val.Type())
+		}
+		return nil
+	case f.asBool && tagType >= TAG_Byte && tagType <= TAG_Long:
+		_, _, v := indirect(val, false)
+		if v.Kind() != reflect.Bool {
+			break
+		}
+		tag, err := d.readTag(tagType)
+		if err != nil {
+			return err
+		}
+		v.SetBool(convertNumber(tag, TAG_Long) != Long(0))
+		return nil
+	}
+	return d.unmarshal(val, tagType)
+}
+
+// applyDefaults sets every field that has a default value and wasn't present
+// in the compound just decoded into val.
+func (d *NBTDecoder) applyDefaults(val reflect.Value, fields *structFields, seen map[string]bool) error {
+	for i := range fields.list {
+		f := &fields.list[i]
+		if seen[f.name] || (f.defaultValue == nil && f.defaultErr == nil) {
+			continue
+		}
+		if f.defaultErr != nil {
+			return fmt.Errorf("invalid default for field %q: %w", f.name, f.defaultErr)
+		}
+		fv, err := fieldForDecode(val, f.index)
+		if err != nil {
+			return err
+		}
+		if !fv.CanAddr() {
+			return fmt.Errorf("can't set default for unaddressable field %q", f.name)
+		}
+		if err := d.unmarshalDefault(fv, f.defaultValue); err != nil {
+			return fmt.Errorf("invalid default for field %q: %w", f.name, err)
+		}
+	}
+	return nil
+}
+
+// unmarshalDefault decodes a field's default value into val. It's read with
+// d's own settings, but from the default's bytes rather than d's input.
+func (d *NBTDecoder) unmarshalDefault(val reflect.Value, m RawMessage) error {
+	r, variant := d.r, d.variant
+	d.defaultReader.reset(m[1:])
+	d.r, d.variant = &d.defaultReader, JavaEdition
+	err := d.unmarshal(val, m[0])
+	d.r, d.variant = r, variant
+	return err
+}
+
+// setFromString parses s into a number, bool or string field.
+func setFromString(val reflect.Value, s string) error {
+	_, _, val = indirect(val, false)
+	switch val.Kind() {
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		val.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, val.Type().Bits())
+		if err != nil {
+			return err
+		}
+		val.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(s, 10, val.Type().Bits())
+		if err != nil {
+			return err
+		}
+		val.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		n, err := strconv.ParseFloat(s, val.Type().Bits())
+		if err != nil {
+			return err
+		}
+		val.SetFloat(n)
+	case reflect.String:
+		val.SetString(s)
+	case reflect.Interface:
+		if val.NumMethod() != 0 {
+			return fmt.Errorf("can't unmarshal string into go type %q", val.Type().String())
+		}
+		val.Set(reflect.ValueOf(s))
+	default:
+		return fmt.Errorf("the ,string option can't be used with go type %q", val.Type().String())
+	}
+	return nil
+}
+
+// formatAsString formats a number or bool field for the ",string" option.
+func formatAsString(val reflect.Value) (string, error) {
+	for val.Kind() == reflect.Pointer || val.Kind() == reflect.Interface {
+		if val.IsNil() {
+			return "", errors.New("can't format nil value as a string")
+		}
+		val = val.Elem()
+	}
+	switch val.Kind() {
+	case reflect.Bool:
+		return strconv.FormatBool(val.Bool()), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(val.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(val.Uint(), 10), nil
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(val.Float(), 'g', -1, val.Type().Bits()), nil
+	case reflect.String:
+		return val.String(), nil
+	}
+	return "", fmt.Errorf("the ,string option can't be used with go type %q", val.Type().String())
+}
@@ -0,0 +1,175 @@
+package nbt_test
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/faideww/mc-iso/src/nbt"
+	"github.com/faideww/mc-iso/src/region"
+)
+
+// The seed corpus is every file in testdata, plus anything go test has saved
+// under testdata/fuzz. Most decoders are run with fuzzLimits to keep each run
+// fast, but FuzzDecode also decodes with DefaultLimits, where a bogus length
+// must fail when the data runs out rather than exhaust memory.
+var fuzzLimits = nbt.Limits{
+	MaxDepth:     512,
+	MaxBytes:     1 << 20,
+	MaxArrayLen:  1 << 16,
+	MaxStringLen: 1 << 16,
+}
+
+// addSeeds adds every file in testdata to the corpus, decompressed unless raw
+// is set.
+func addSeeds(f *testing.F, raw bool) {
+	paths, err := filepath.Glob("testdata/*")
+	if err != nil {
+		f.Fatal(err)
+	}
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			continue // eg. the fuzz directory
+		}
+		if !raw {
+			r, err := nbt.Decompress(bytes.NewReader(data))
+			if err != nil {
+				f.Fatalf("%s: %v", path, err)
+			}
+			if data, err = io.ReadAll(r); err != nil {
+				f.Fatalf("%s: %v", path, err)
+			}
+		}
+		f.Add(data)
+	}
+}
+
+func newFuzzDecoder(data []byte, v nbt.Variant) *nbt.NBTDecoder {
+	d := nbt.NewDecoder(bytes.NewReader(data))
+	d.SetVariant(v)
+	d.SetLimits(fuzzLimits)
+	return d
+}
+
+func FuzzDecode(f *testing.F) {
+	addSeeds(f, false)
+	// huge lengths for a TAG_Long_Array, TAG_List and TAG_Byte_Array
+	f.Add([]byte{nbt.TAG_Long_Array, 0, 0, 0x7f, 0xff, 0xff, 0xff})
+	f.Add([]byte{nbt.TAG_List, 0, 0, nbt.TAG_Long, 0x7f, 0xff, 0xff, 0xff})
+	f.Add([]byte{nbt.TAG_Compound, 0, 0, nbt.TAG_Byte_Array, 0, 1, 'a', 0x7f, 0xff, 0xff, 0xff})
+	f.Fuzz(func(t *testing.T, data []byte) {
+		for _, v := range []nbt.Variant{nbt.JavaEdition, nbt.BedrockEdition, nbt.BedrockNetwork} {
+			var a any
+			newFuzzDecoder(data, v).Decode(&a)
+			var chunk region.Chunk
+			newFuzzDecoder(data, v).Decode(&chunk)
+
+			d := nbt.NewDecoder(bytes.NewReader(data))
+			d.SetVariant(v)
+			d.Decode(&a)
+			d = nbt.NewDecoder(bytes.NewReader(data))
+			d.SetVariant(v)
+			var tag nbt.Tag
+			d.Decode(&tag)
+		}
+
+		// anything that decodes into a tree must encode back to the same bytes.
+		// Strings are only re-encoded identically if they were valid MUTF-8.
+		d := newFuzzDecoder(data, nbt.JavaEdition)
+		d.SetStrictStrings(true)
+		var tag nbt.Tag
+		name, err := d.Decode(&tag)
+		if err != nil {
+			return
+		}
+		var buf bytes.Buffer
+		if err := nbt.NewEncoder(&buf).Encode(name, tag); err != nil {
+			t.Fatalf("re-encoding decoded tree: %v", err)
+		}
+		if n := d.InputOffset(); !bytes.Equal(buf.Bytes(), data[:n]) {
+			t.Fatalf("re-encoded tree differs from input\ninput:  %x\noutput: %x", data[:n], buf.Bytes())
+		}
+	})
+}
+
+func FuzzReadAndDiscardTag(f *testing.F) {
+	addSeeds(f, false)
+	f.Fuzz(func(t *testing.T, data []byte) {
+		if len(data) == 0 {
+			return
+		}
+		// the first byte is the tag type, like the header of a nameless root
+		d := newFuzzDecoder(data[1:], nbt.JavaEdition)
+		d.ReadAndDiscardTag(data[0])
+	})
+}
+
+func FuzzDecompress(f *testing.F) {
+	addSeeds(f, true)
+	f.Fuzz(func(t *testing.T, data []byte) {
+		r, err := nbt.Decompress(bytes.NewReader(data))
+		if err != nil {
+			return
+		}
+		d := nbt.NewDecoder(io.LimitReader(r, 1<<20))
+		d.SetLimits(fuzzLimits)
+		var tag nbt.Tag
+		d.Decode(&tag)
+	})
+}
+
+func FuzzSNBT(f *testing.F) {
+	for _, s := range []string{
+		`{Data:{LevelName:"New World",SpawnY:64,Time:24000L,Pos:[0.5d,64.0d,0.5d]}}`,
+		`[I;1,-2,3]`, `[B;1b]`, `[L;]`, `{"quoted key":'single "quotes"'}`, `[[],[1s]]`, `1.5e10f`,
+	} {
+		f.Add(s)
+	}
+	f.Fuzz(func(t *testing.T, s string) {
+		tag, err := nbt.ParseSNBTTag(s)
+		if err != nil {
+			return
+		}
+		// formatting and parsing again must give back the same tree
+		out, err := nbt.FormatSNBT(tag, "")
+		if err != nil {
+			t.Fatalf("FormatSNBT: %v", err)
+		}
+		again, err := nbt.ParseSNBTTag(out)
+		if err != nil {
+			t.Fatalf("parsing %q (formatted from %q): %v", out, s, err)
+		}
+		if d := nbt.Diff(tag, again); len(d) != 0 {
+			t.Fatalf("%q formatted as %q, which parses differently:\n%s", s, out, d)
+		}
+	})
+}
+
+// destinations that used to make Decode panic rather than return an error
+func TestDecodeInvalidDestination(t *testing.T) {
+	var buf bytes.Buffer
+	if err := nbt.NewEncoder(&buf).Encode("", map[string]any{"a": int32(1)}); err != nil {
+		t.Fatal(err)
+	}
+	var nilMap *map[string]any
+	for name, v := range map[string]any{
+		"nil pointer": nilMap,
+		"non-empty interface": &struct {
+			A fmt.Stringer `nbt:"a"`
+		}{},
+	} {
+		if _, err := nbt.NewDecoder(bytes.NewReader(buf.Bytes())).Decode(v); err == nil {
+			t.Errorf("decoding into %s succeeded", name)
+		}
+	}
+
+	for _, tagType := range []byte{nbt.TAG_End, 13, 0xff} {
+		if err := nbt.NewDecoder(bytes.NewReader([]byte{0, 0, 0, 0})).ReadAndDiscardTag(tagType); err == nil {
+			t.Errorf("discarding tag type %#02x succeeded", tagType)
+		}
+	}
+}
@@ -0,0 +1,394 @@
+package nbt
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// json.go
+// conversion between binary NBT and a JSON representation that keeps every
+// tag's type, so that a tree survives the trip through JSON unchanged. Each
+// tag is written as an envelope holding its type and value:
+//
+//	{"name":"","type":"compound","value":{
+//		"DataVersion":{"type":"int","value":3465},
+//		"Heightmaps":{"type":"compound","value":{...}},
+//		"sections":{"type":"list","elementType":"compound","value":[...]}}}
+//
+// Only the root envelope has a name. Compound entries are written in their
+// original order, list elements are envelopes of their own, and a list always
+// records its element type so that empty lists keep it.
+//
+// Values that JSON (or the tools reading it) can't represent exactly are
+// written as strings: longs and the elements of long arrays as decimal
+// strings, since many JSON parsers only have 53 bits of integer precision,
+// and non-finite floats as "Infinity", "-Infinity" or "NaN(0x...)" with the
+// NaN's bit pattern.
+
+// jsonTypeNames are the names used for the "type" of an envelope.
+var jsonTypeNames = [...]string{
+	TAG_Byte:       "byte",
+	TAG_Short:      "short",
+	TAG_Int:        "int",
+	TAG_Long:       "long",
+	TAG_Float:      "float",
+	TAG_Double:     "double",
+	TAG_Byte_Array: "byte_array",
+	TAG_String:     "string",
+	TAG_List:       "list",
+	TAG_Compound:   "compound",
+	TAG_Int_Array:  "int_array",
+	TAG_Long_Array: "long_array",
+}
+
+func jsonTypeName(tagType byte) string {
+	if int(tagType) < len(jsonTypeNames) && jsonTypeNames[tagType] != "" {
+		return jsonTypeNames[tagType]
+	}
+	// only possible for the element type of an empty list
+	return "end"
+}
+
+func jsonTagType(name string) (byte, bool) {
+	if name == "end" {
+		return TAG_End, true
+	}
+	for t, n := range jsonTypeNames {
+		if n != "" && n == name {
+			return byte(t), true
+		}
+	}
+	return 0, false
+}
+
+// ToJSON decodes the next tag from d and returns its JSON representation. The
+// decoder's variant, limits and other settings apply as usual.
+func ToJSON(d *NBTDecoder) ([]byte, error) {
+	var tag Tag
+	name, err := d.Decode(&tag)
+	if err != nil {
+		return nil, err
+	}
+	b := []byte(`{"name":`)
+	b = appendJSONString(b, name)
+	b = append(b, ',')
+	if b, err = appendJSONTag(b, tag); err != nil {
+		return nil, fmt.Errorf("nbt: failed to convert tag %q to JSON: %w", name, err)
+	}
+	return append(b, '}'), nil
+}
+
+// FromJSON converts JSON produced by ToJSON back into a tag, and writes it
+// with e. Converting NBT to JSON and back with the same variant reproduces
+// the original bytes.
+func FromJSON(data []byte, e *NBTEncoder) error {
+	var root jsonTag
+	if err := json.Unmarshal(data, &root); err != nil {
+		return fmt.Errorf("nbt: invalid JSON: %w", err)
+	}
+	tag, err := root.tag()
+	if err != nil {
+		return fmt.Errorf("nbt: invalid JSON: %w", err)
+	}
+	return e.Encode(root.Name, tag)
+}
+
+// appendJSONTag appends the envelope of tag, without the enclosing braces.
+func appendJSONTag(b []byte, tag Tag) ([]byte, error) {
+	b = append(b, `"type":"`...)
+	b = append(b, jsonTypeName(tag.TagType())...)
+	b = append(b, '"')
+	if l, ok := tag.(*List); ok {
+		b = append(b, `,"elementType":"`...)
+		b = append(b, jsonTypeName(l.ElemType)...)
+		b = append(b, '"')
+	}
+	b = append(b, `,"value":`...)
+
+	var err error
+	switch t := tag.(type) {
+	case Byte:
+		b = strconv.AppendInt(b, int64(t), 10)
+	case Short:
+		b = strconv.AppendInt(b, int64(t), 10)
+	case Int:
+		b = strconv.AppendInt(b, int64(t), 10)
+	case Long:
+		b = append(b, '"')
+		b = strconv.AppendInt(b, int64(t), 10)
+		b = append(b, '"')
+	case Float:
+		b = appendJSONFloat(b, float64(t), uint64(math.Float32bits(float32(t))), 32)
+	case Double:
+		b = appendJSONFloat(b, float64(t), math.Float64bits(float64(t)), 64)
+	case String:
+		if !utf8.ValidString(string(t)) {
+			return nil, fmt.Errorf("string %q is not valid UTF-8", string(t))
+		}
+		b = appendJSONString(b, string(t))
+	case ByteArray:
+		b = appendJSONInts(b, t, false)
+	case IntArray:
+		b = appendJSONInts(b, t, false)
+	case LongArray:
+		b = appendJSONInts(b, t, true)
+	case *List:
+		b = append(b, '[')
+		for i, elem := range t.Elems {
+			if i > 0 {
+				b = append(b, ',')
+			}
+			b = append(b, '{')
+			if b, err = appendJSONTag(b, elem); err != nil {
+				return nil, fmt.Errorf("[%d]: %w", i, err)
+			}
+			b = append(b, '}')
+		}
+		b = append(b, ']')
+	case *Compound:
+		b = append(b, '{')
+		for i, e := range t.Entries {
+			if i > 0 {
+				b = append(b, ',')
+			}
+			if !utf8.ValidString(e.Name) {
+				return nil, fmt.Errorf("name %q is not valid UTF-8", e.Name)
+			}
+			b = appendJSONString(b, e.Name)
+			b = append(b, ":{"...)
+			if b, err = appendJSONTag(b, e.Value); err != nil {
+				return nil, fmt.Errorf("%s: %w", e.Name, err)
+			}
+			b = append(b, '}')
+		}
+		b = append(b, '}')
+	default:
+		return nil, fmt.Errorf("unsupported tag type %T", tag)
+	}
+	return b, nil
+}
+
+func appendJSONString(b []byte, s string) []byte {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.Encode(s) // can't fail for a string
+	return append(b, bytes.TrimSuffix(buf.Bytes(), []byte("\n"))...)
+}
+
+func appendJSONInts[E byte | int32 | int64](b []byte, elems []E, quote bool) []byte {
+	b = append(b, '[')
+	for i, v := range elems {
+		if i > 0 {
+			b = append(b, ',')
+		}
+		if quote {
+			b = append(b, '"')
+		}
+		if _, ok := any(v).(byte); ok {
+			// byte arrays are signed, as in SNBT
+			b = strconv.AppendInt(b, int64(int8(v)), 10)
+		} else {
+			b = strconv.AppendInt(b, int64(v), 10)
+		}
+		if quote {
+			b = append(b, '"')
+		}
+	}
+	return append(b, ']')
+}
+
+func appendJSONFloat(b []byte, f float64, bits uint64, bitSize int) []byte {
+	switch {
+	case math.IsInf(f, 1):
+		return append(b, `"Infinity"`...)
+	case math.IsInf(f, -1):
+		return append(b, `"-Infinity"`...)
+	case math.IsNaN(f):
+		return fmt.Appendf(b, `"NaN(%#x)"`, bits)
+	}
+	return strconv.AppendFloat(b, f, 'g', -1, bitSize)
+}
+
+// jsonTag is the envelope of a single tag.
+type jsonTag struct {
+	Name     string          `json:"name"`
+	Type     string          `json:"type"`
+	ElemType string          `json:"elementType"`
+	Value    json.RawMessage `json:"value"`
+}
+
+// tag converts the envelope back into a Tag.
+func (jt *jsonTag) tag() (Tag, error) {
+	tagType, ok := jsonTagType(jt.Type)
+	if !ok || tagType == TAG_End {
+		return nil, fmt.Errorf("unknown tag type %q", jt.Type)
+	}
+	if jt.Value == nil || string(jt.Value) == "null" {
+		return nil, fmt.Errorf("%s has no value", jt.Type)
+	}
+
+	switch tagType {
+	case TAG_Byte:
+		var v int8
+		err := json.Unmarshal(jt.Value, &v)
+		return Byte(v), err
+	case TAG_Short:
+		var v int16
+		err := json.Unmarshal(jt.Value, &v)
+		return Short(v), err
+	case TAG_Int:
+		var v int32
+		err := json.Unmarshal(jt.Value, &v)
+		return Int(v), err
+	case TAG_Long:
+		v, err := parseJSONLong(jt.Value)
+		return Long(v), err
+	case TAG_Float:
+		v, err := parseJSONFloat(jt.Value, 32)
+		return Float(math.Float32frombits(uint32(v))), err
+	case TAG_Double:
+		v, err := parseJSONFloat(jt.Value, 64)
+		return Double(math.Float64frombits(v)), err
+	case TAG_String:
+		var v string
+		err := json.Unmarshal(jt.Value, &v)
+		return String(v), err
+	case TAG_Byte_Array:
+		var v []int8
+		if err := json.Unmarshal(jt.Value, &v); err != nil {
+			return nil, err
+		}
+		arr := make(ByteArray, len(v))
+		for i, b := range v {
+			arr[i] = byte(b)
+		}
+		return arr, nil
+	case TAG_Int_Array:
+		var v []int32
+		err := json.Unmarshal(jt.Value, &v)
+		return IntArray(v), err
+	case TAG_Long_Array:
+		var elems []json.RawMessage
+		if err := json.Unmarshal(jt.Value, &elems); err != nil {
+			return nil, err
+		}
+		arr := make(LongArray, len(elems))
+		for i, elem := range elems {
+			v, err := parseJSONLong(elem)
+			if err != nil {
+				return nil, fmt.Errorf("[%d]: %w", i, err)
+			}
+			arr[i] = v
+		}
+		return arr, nil
+	case TAG_List:
+		elemType, ok := jsonTagType(jt.ElemType)
+		if !ok {
+			return nil, fmt.Errorf("unknown list element type %q", jt.ElemType)
+		}
+		var elems []jsonTag
+		if err := json.Unmarshal(jt.Value, &elems); err != nil {
+			return nil, err
+		}
+		list := &List{ElemType: elemType, Elems: make([]Tag, len(elems))}
+		for i := range elems {
+			elem, err := elems[i].tag()
+			if err != nil {
+				return nil, fmt.Errorf("[%d]: %w", i, err)
+			}
+			if elem.TagType() != elemType {
+				return nil, fmt.Errorf("[%d]: %s in list of %s", i, elems[i].Type, jt.ElemType)
+			}
+			list.Elems[i] = elem
+		}
+		return list, nil
+	case TAG_Compound:
+		return parseJSONCompound(jt.Value)
+	}
+	return nil, fmt.Errorf("unknown tag type %q", jt.Type)
+}
+
+// parseJSONCompound reads the entries of a compound in the order they appear,
+// which json.Unmarshal into a map would lose.
+func parseJSONCompound(data []byte) (*Compound, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	if tok, err := dec.Token(); err != nil {
+		return nil, err
+	} else if tok != json.Delim('{') {
+		return nil, fmt.Errorf("compound value is %v, not an object", tok)
+	}
+
+	c := &Compound{}
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		name := tok.(string) // object keys are always strings
+		var entry jsonTag
+		if err := dec.Decode(&entry); err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		value, err := entry.tag()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		c.Entries = append(c.Entries, NamedTag{Name: name, Value: value})
+	}
+	return c, nil
+}
+
+// parseJSONLong accepts a long written either as a string or a number.
+func parseJSONLong(data []byte) (int64, error) {
+	var n json.Number
+	if err := json.Unmarshal(data, &n); err != nil {
+		return 0, err
+	}
+	return strconv.ParseInt(string(n), 10, 64)
+}
+
+// parseJSONFloat returns the bits of a float or double, which is either a
+// number or one of the strings written for non-finite values.
+func parseJSONFloat(data []byte, bitSize int) (uint64, error) {
+	var f float64
+	if len(data) > 0 && data[0] == '"' {
+		var s string
+		if err := json.Unmarshal(data, &s); err != nil {
+			return 0, err
+		}
+		switch s {
+		case "Infinity":
+			f = math.Inf(1)
+		case "-Infinity":
+			f = math.Inf(-1)
+		case "NaN":
+			f = math.NaN()
+		default:
+			hex, ok := strings.CutPrefix(s, "NaN(")
+			hex, ok2 := strings.CutSuffix(hex, ")")
+			if !ok || !ok2 {
+				return 0, fmt.Errorf("invalid floating point value %q", s)
+			}
+			bits, err := strconv.ParseUint(hex, 0, bitSize)
+			if err != nil {
+				return 0, fmt.Errorf("invalid NaN bit pattern %q", s)
+			}
+			return bits, nil
+		}
+	} else {
+		var err error
+		if f, err = strconv.ParseFloat(string(data), bitSize); err != nil {
+			return 0, err
+		}
+	}
+	if bitSize == 32 {
+		return uint64(math.Float32bits(float32(f))), nil
+	}
+	return math.Float64bits(f), nil
+}
@@ -0,0 +1,74 @@
+package nbt
+
+import (
+	"bytes"
+	"encoding/json"
+	"math"
+	"testing"
+)
+
+func TestJSONRoundTrip(t *testing.T) {
+	tag, err := ParseSNBTTag(`{
+		DataVersion: 3465,
+		Status: "minecraft:full",
+		"odd \"name\"": "<b>&amp;",
+		LastUpdate: 9007199254740993L,
+		Heightmaps: {MOTION_BLOCKING: [L; -9223372036854775808L, 1L]},
+		Light: [B; -128b, 0b, 127b],
+		Biomes: [I; 1, -2, 3],
+		sections: [{Y: -4b, block_states: {palette: [{Name: "minecraft:air"}]}}],
+		Empty: [],
+		Nested: [[1s, 2s], [], [3.5f]],
+		Motion: [0.1d, -0.0d, 1e300d],
+		Scale: 0.1f
+	}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := tag.(*Compound)
+	c.Set("NaN", Float(math.Float32frombits(0x7fc00001)))
+	c.Set("Inf", Double(math.Inf(-1)))
+	c.Set("Tab", String("a\tb\u00e9"))
+
+	var want bytes.Buffer
+	if err := NewEncoder(&want).Encode("chunk", c); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := ToJSON(NewDecoder(bytes.NewReader(want.Bytes())))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !json.Valid(data) {
+		t.Fatalf("ToJSON produced invalid JSON: %s", data)
+	}
+
+	// the output has to survive reformatting by other tools
+	var indented bytes.Buffer
+	if err := json.Indent(&indented, data, "", "  "); err != nil {
+		t.Fatal(err)
+	}
+	var got bytes.Buffer
+	if err := FromJSON(indented.Bytes(), NewEncoder(&got)); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got.Bytes(), want.Bytes()) {
+		t.Errorf("round trip through JSON changed the encoding\n%s", indented.Bytes())
+	}
+}
+
+func TestFromJSONErrors(t *testing.T) {
+	for _, s := range []string{
+		`{"name":"","type":"byte","value":128}`,
+		`{"name":"","type":"int"}`,
+		`{"name":"","type":"blob","value":1}`,
+		`{"name":"","type":"long","value":"1.5"}`,
+		`{"name":"","type":"float","value":"NaN(bits)"}`,
+		`{"name":"","type":"list","elementType":"int","value":[{"type":"short","value":1}]}`,
+		`{"name":"","type":"compound","value":[]}`,
+	} {
+		if err := FromJSON([]byte(s), NewEncoder(&bytes.Buffer{})); err == nil {
+			t.Errorf("FromJSON(%s) succeeded", s)
+		}
+	}
+}
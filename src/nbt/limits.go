@@ -0,0 +1,129 @@
+package nbt
+
+import (
+	"errors"
+	"fmt"
+)
+
+// limits.go
+// resource limits for decoding untrusted NBT
+
+// Limits bounds the resources an NBTDecoder may use while decoding. A zero
+// value for any field means that resource is not limited.
+type Limits struct {
+	// maximum nesting depth of compounds and lists
+	MaxDepth int
+	// maximum total number of bytes allocated for arrays, lists and strings
+	// during a single call to Decode
+	MaxBytes int64
+	// maximum number of elements in a single array or list
+	MaxArrayLen int
+	// maximum length of a single string, in bytes
+	MaxStringLen int
+}
+
+// DefaultLimits are the limits used by NewDecoder. Minecraft itself refuses
+// to read NBT nested more than 512 levels deep. The allocation limit is well
+// above anything the game writes, but stops a small compressed input (such as
+// a chunk in a region file) from expanding to fill memory.
+var DefaultLimits = Limits{MaxDepth: 512, MaxBytes: 256 << 20}
+
+// UntrustedLimits are suggested limits for decoding NBT from untrusted
+// sources, such as player-uploaded worlds.
+var UntrustedLimits = Limits{
+	MaxDepth:     512,
+	MaxBytes:     64 << 20,
+	MaxArrayLen:  1 << 20,
+	MaxStringLen: 1 << 16,
+}
+
+var (
+	ErrMaxDepth     = errors.New("nbt: maximum nesting depth exceeded")
+	ErrMaxBytes     = errors.New("nbt: maximum allocation exceeded")
+	ErrMaxArrayLen  = errors.New("nbt: maximum array length exceeded")
+	ErrMaxStringLen = errors.New("nbt: maximum string length exceeded")
+)
+
+// LimitError is returned when decoding would exceed one of the decoder's
+// Limits. It wraps one of ErrMaxDepth, ErrMaxBytes, ErrMaxArrayLen or
+// ErrMaxStringLen, so it can be checked with errors.Is.
+type LimitError struct {
+	Err error
+	// the configured limit, and the value that would have exceeded it
+	Limit, Value int64
+	// position in the input at which the limit was hit
+	Offset int64
+}
+
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("%s (%d > %d at offset %d)", e.Err.Error(), e.Value, e.Limit, e.Offset)
+}
+
+func (e *LimitError) Unwrap() error {
+	return e.Err
+}
+
+// SetLimits replaces the decoder's resource limits.
+func (d *NBTDecoder) SetLimits(l Limits) {
+	d.limits = l
+}
+
+func (d *NBTDecoder) limitError(err error, limit, value int64) error {
+	return &LimitError{Err: err, Limit: limit, Value: value, Offset: d.r.n}
+}
+
+// enter is called when descending into a compound or list, and leave when
+// coming back out of it.
+func (d *NBTDecoder) enter() error {
+	d.depth++
+	if d.limits.MaxDepth > 0 && d.depth > d.limits.MaxDepth {
+		d.depth--
+		return d.limitError(ErrMaxDepth, int64(d.limits.MaxDepth), int64(d.depth+1))
+	}
+	return nil
+}
+
+func (d *NBTDecoder) leave() {
+	d.depth--
+}
+
+// alloc accounts for n bytes about to be allocated by the decoder.
+func (d *NBTDecoder) alloc(n int64) error {
+	d.allocated += n
+	if d.limits.MaxBytes > 0 && d.allocated > d.limits.MaxBytes {
+		return d.limitError(ErrMaxBytes, d.limits.MaxBytes, d.allocated)
+	}
+	return nil
+}
+
+// readArrayLen reads the length prefix of an array or list, and checks it
+// against the decoder's limits.
+func (d *NBTDecoder) readArrayLen() (int, error) {
+	length, err := d.ReadInt32()
+	if err != nil {
+		return 0, err
+	}
+	if length < 0 {
+		return 0, fmt.Errorf("array or list has negative length %d", length)
+	}
+	if d.limits.MaxArrayLen > 0 && int(length) > d.limits.MaxArrayLen {
+		return 0, d.limitError(ErrMaxArrayLen, int64(d.limits.MaxArrayLen), int64(length))
+	}
+	return int(length), nil
+}
+
+// readListHeader reads the element type and length of a TAG_List.
+func (d *NBTDecoder) readListHeader() (byte, int, error) {
+	listType, err := d.r.ReadByte()
+	if err != nil {
+		return 0, 0, err
+	}
+	listLen, err := d.readArrayLen()
+	if err != nil {
+		return 0, 0, err
+	}
+	if listType == TAG_End && listLen > 0 {
+		return 0, 0, fmt.Errorf("TAG_List of TAG_End has non-zero length %d", listLen)
+	}
+	return listType, listLen, nil
+}
@@ -0,0 +1,147 @@
+package nbt
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"math"
+	"strings"
+	"testing"
+)
+
+// nestedLists returns a root TAG_List holding n more levels of lists.
+func nestedLists(n int) []byte {
+	b := []byte{TAG_List, 0, 0}
+	for i := 0; i < n; i++ {
+		b = append(b, TAG_List, 0, 0, 0, 1)
+	}
+	return append(b, TAG_End, 0, 0, 0, 0)
+}
+
+func TestLimits(t *testing.T) {
+	// a root TAG_String of length n
+	rootString := func(n int) []byte {
+		b := []byte{TAG_String, 0, 0, byte(n >> 8), byte(n)}
+		return append(b, strings.Repeat("x", n)...)
+	}
+	tests := []struct {
+		name   string
+		limits Limits
+		in     []byte
+		err    error
+		// the limit, the value that exceeded it and the input offset
+		limit, value, offset int64
+		// whether ReadAndDiscardTag is limited too; it never allocates
+		discard bool
+	}{
+		{"depth", Limits{MaxDepth: 5}, nestedLists(10), ErrMaxDepth, 5, 6, 28, true},
+		{"default depth", DefaultLimits, nestedLists(600), ErrMaxDepth, 512, 513, 2563, true},
+		{"default bytes", DefaultLimits, []byte{TAG_Long_Array, 0, 0, 0x7f, 0xff, 0xff, 0xff}, ErrMaxBytes, 256 << 20, 8 * math.MaxInt32, 7, false},
+		{"byte array length", Limits{MaxArrayLen: 10}, []byte{TAG_Byte_Array, 0, 0, 0, 0, 0, 11}, ErrMaxArrayLen, 10, 11, 7, true},
+		{"int array length", UntrustedLimits, []byte{TAG_Int_Array, 0, 0, 0x7f, 0xff, 0xff, 0xff}, ErrMaxArrayLen, 1 << 20, 1<<31 - 1, 7, true},
+		{"list length", Limits{MaxArrayLen: 2}, []byte{TAG_List, 0, 0, TAG_Byte, 0, 0, 0, 3, 1, 2, 3}, ErrMaxArrayLen, 2, 3, 8, true},
+		{"string length", Limits{MaxStringLen: 4}, rootString(5), ErrMaxStringLen, 4, 5, 5, true},
+		{"array bytes", Limits{MaxBytes: 16}, []byte{TAG_Long_Array, 0, 0, 0, 0, 0, 3}, ErrMaxBytes, 16, 24, 7, false},
+		{"string bytes", Limits{MaxBytes: 4}, rootString(5), ErrMaxBytes, 4, 5, 5, true},
+		// allocations, entry names included, add up over the whole call to
+		// Decode
+		{"total bytes", Limits{MaxBytes: 15}, []byte{
+			TAG_Compound, 0, 0,
+			TAG_String, 0, 1, 'a', 0, 8, 'a', 'a', 'a', 'a', 'a', 'a', 'a', 'a',
+			TAG_String, 0, 1, 'b', 0, 8, 'b', 'b', 'b', 'b', 'b', 'b', 'b', 'b',
+			TAG_End,
+		}, ErrMaxBytes, 15, 18, 23, true},
+	}
+	for _, tt := range tests {
+		targets := map[string]func(d *NBTDecoder) error{
+			"any": func(d *NBTDecoder) error {
+				var v any
+				_, err := d.Decode(&v)
+				return err
+			},
+			"Tag": func(d *NBTDecoder) error {
+				var v Tag
+				_, err := d.Decode(&v)
+				return err
+			},
+		}
+		if tt.discard {
+			targets["ReadAndDiscardTag"] = func(d *NBTDecoder) error {
+				tagType, _, err := d.ReadTagHeader()
+				if err != nil {
+					return err
+				}
+				return d.ReadAndDiscardTag(tagType)
+			}
+		}
+		for target, decode := range targets {
+			d := NewDecoder(bytes.NewReader(tt.in))
+			d.SetLimits(tt.limits)
+			err := decode(d)
+
+			var lerr *LimitError
+			if !errors.As(err, &lerr) {
+				t.Errorf("%s into %s: got %v, want a *LimitError", tt.name, target, err)
+				continue
+			}
+			if !errors.Is(err, tt.err) {
+				t.Errorf("%s into %s: %v, want %v", tt.name, target, err, tt.err)
+			}
+			if lerr.Limit != tt.limit || lerr.Value != tt.value || lerr.Offset != tt.offset {
+				t.Errorf("%s into %s: limit %d, value %d at offset %d, want %d, %d at %d",
+					tt.name, target, lerr.Limit, lerr.Value, lerr.Offset, tt.limit, tt.value, tt.offset)
+			}
+		}
+	}
+}
+
+func TestLimitsUnlimited(t *testing.T) {
+	d := NewDecoder(bytes.NewReader(nestedLists(600)))
+	d.SetLimits(Limits{})
+	var v any
+	if _, err := d.Decode(&v); err != nil {
+		t.Errorf("decoding with no limits: %v", err)
+	}
+
+	// limits apply to each call to Decode separately
+	var buf bytes.Buffer
+	for range 3 {
+		if err := NewEncoder(&buf).Encode("", strings.Repeat("x", 10)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	d = NewDecoder(&buf)
+	d.SetLimits(Limits{MaxBytes: 15})
+	for i := range 3 {
+		if _, err := d.Decode(&v); err != nil {
+			t.Errorf("Decode %d: %v", i, err)
+		}
+	}
+}
+
+// lengths are trusted only as far as the data goes, so even without limits a
+// bogus one fails instead of exhausting memory
+func TestLimitsHugeLength(t *testing.T) {
+	huge := []byte{0x7f, 0xff, 0xff, 0xff}
+	tests := []struct {
+		name    string
+		variant Variant
+		in      []byte
+		v       any
+	}{
+		{"long array", JavaEdition, append([]byte{TAG_Long_Array, 0, 0}, huge...), new(any)},
+		{"int array", JavaEdition, append([]byte{TAG_Int_Array, 0, 0}, huge...), new([]int32)},
+		{"byte array", JavaEdition, append([]byte{TAG_Byte_Array, 0, 0}, huge...), new([]int8)},
+		{"list", JavaEdition, append([]byte{TAG_List, 0, 0, TAG_Long}, huge...), new([]int64)},
+		{"list into a tree", JavaEdition, append([]byte{TAG_List, 0, 0, TAG_Compound}, huge...), new(Tag)},
+		{"string", BedrockNetwork, []byte{TAG_String, 0, 0xfe, 0xff, 0xff, 0xff, 0x07, 'x'}, new(any)},
+	}
+	for _, tt := range tests {
+		d := NewDecoder(bytes.NewReader(tt.in))
+		d.SetVariant(tt.variant)
+		d.SetLimits(Limits{})
+		if _, err := d.Decode(tt.v); !errors.Is(err, io.ErrUnexpectedEOF) {
+			t.Errorf("%s: got %v, want io.ErrUnexpectedEOF", tt.name, err)
+		}
+	}
+}
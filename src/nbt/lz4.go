@@ -0,0 +1,244 @@
+package nbt
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math/bits"
+)
+
+// lz4.go
+// decompression of the LZ4 framing written by lz4-java's LZ4BlockOutputStream,
+// which Minecraft uses for region files since 1.20.5. The stream is a series
+// of blocks, each with a 21 byte header:
+//
+//	"LZ4Block"            magic
+//	token                 compression method (high nibble: 0x10 stored,
+//	                      0x20 LZ4) and block size (low nibble: 1<<(10+n))
+//	compressed length     int32, little-endian
+//	decompressed length   int32, little-endian
+//	checksum              xxhash32 of the decompressed data, seed 0x9747b28c,
+//	                      masked to 28 bits
+//
+// followed by the block's data. A block with a decompressed length of 0 marks
+// the end of the stream.
+
+const (
+	lz4BlockMagic      = "LZ4Block"
+	lz4BlockHeaderLen  = len(lz4BlockMagic) + 13
+	lz4MethodStored    = 0x10
+	lz4MethodLZ4       = 0x20
+	lz4ChecksumSeed    = 0x9747b28c
+	lz4ChecksumMask    = 0xfffffff
+	lz4MaxBlockSizeLog = 25
+)
+
+var errLZ4Corrupt = errors.New("lz4: corrupt block")
+
+type lz4BlockReader struct {
+	r    io.Reader
+	buf  []byte // decompressed contents of the current block
+	pos  int
+	src  []byte // compressed contents of the current block
+	done bool
+	err  error
+}
+
+func newLZ4BlockReader(r io.Reader) *lz4BlockReader {
+	return &lz4BlockReader{r: r}
+}
+
+func (z *lz4BlockReader) Read(p []byte) (int, error) {
+	for z.pos == len(z.buf) {
+		if z.err != nil {
+			return 0, z.err
+		}
+		if z.done {
+			return 0, io.EOF
+		}
+		z.err = z.readBlock()
+	}
+	n := copy(p, z.buf[z.pos:])
+	z.pos += n
+	return n, nil
+}
+
+// readBlock reads and decompresses the next block into z.buf.
+func (z *lz4BlockReader) readBlock() error {
+	var hdr [lz4BlockHeaderLen]byte
+	if _, err := io.ReadFull(z.r, hdr[:]); err != nil {
+		if err == io.EOF {
+			// lz4-java also accepts a stream that ends without an end block
+			z.done = true
+			return nil
+		}
+		return err
+	}
+	if string(hdr[:len(lz4BlockMagic)]) != lz4BlockMagic {
+		return errors.New("lz4: invalid block magic")
+	}
+	token := hdr[8]
+	method := token & 0xf0
+	maxLen := 1 << (10 + int(token&0x0f))
+	compressedLen := int32(binary.LittleEndian.Uint32(hdr[9:]))
+	decompressedLen := int32(binary.LittleEndian.Uint32(hdr[13:]))
+	checksum := binary.LittleEndian.Uint32(hdr[17:])
+
+	switch {
+	case token&0x0f > lz4MaxBlockSizeLog-10,
+		decompressedLen < 0 || int(decompressedLen) > maxLen,
+		compressedLen < 0 || int(compressedLen) > maxLen+maxLen/255+16,
+		method != lz4MethodStored && method != lz4MethodLZ4,
+		method == lz4MethodStored && compressedLen != decompressedLen,
+		(decompressedLen == 0) != (compressedLen == 0):
+		return fmt.Errorf("lz4: invalid block header (token %#02x, lengths %d/%d)", token, compressedLen, decompressedLen)
+	}
+	if decompressedLen == 0 {
+		z.done = true
+		z.buf, z.pos = z.buf[:0], 0
+		return nil
+	}
+
+	if cap(z.src) < int(compressedLen) {
+		z.src = make([]byte, compressedLen)
+	}
+	src := z.src[:compressedLen]
+	if _, err := io.ReadFull(z.r, src); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return err
+	}
+
+	if cap(z.buf) < int(decompressedLen) {
+		z.buf = make([]byte, decompressedLen)
+	}
+	z.buf, z.pos = z.buf[:decompressedLen], 0
+	if method == lz4MethodStored {
+		copy(z.buf, src)
+	} else if err := lz4DecompressBlock(z.buf, src); err != nil {
+		return err
+	}
+
+	if xxhash32(z.buf, lz4ChecksumSeed)&lz4ChecksumMask != checksum {
+		return errors.New("lz4: block checksum mismatch")
+	}
+	return nil
+}
+
+// lz4DecompressBlock decompresses a raw LZ4 block, which must exactly fill
+// dst.
+func lz4DecompressBlock(dst, src []byte) error {
+	di, si := 0, 0
+	// readLen extends a 4-bit length with the bytes that follow it
+	readLen := func(n int) (int, bool) {
+		if n != 15 {
+			return n, true
+		}
+		for si < len(src) {
+			b := src[si]
+			si++
+			n += int(b)
+			if b != 255 {
+				return n, true
+			}
+		}
+		return 0, false
+	}
+
+	for si < len(src) {
+		token := src[si]
+		si++
+
+		litLen, ok := readLen(int(token >> 4))
+		if !ok || litLen > len(src)-si || litLen > len(dst)-di {
+			return errLZ4Corrupt
+		}
+		copy(dst[di:], src[si:si+litLen])
+		di += litLen
+		si += litLen
+		if si == len(src) {
+			// the last sequence only has literals
+			break
+		}
+
+		if len(src)-si < 2 {
+			return errLZ4Corrupt
+		}
+		offset := int(binary.LittleEndian.Uint16(src[si:]))
+		si += 2
+		matchLen, ok := readLen(int(token & 0x0f))
+		if !ok || offset == 0 || offset > di {
+			return errLZ4Corrupt
+		}
+		matchLen += 4
+		if matchLen > len(dst)-di {
+			return errLZ4Corrupt
+		}
+		if offset >= matchLen {
+			copy(dst[di:di+matchLen], dst[di-offset:])
+		} else {
+			// the match overlaps the bytes it produces, eg. a run of one byte
+			for i := range matchLen {
+				dst[di+i] = dst[di-offset+i]
+			}
+		}
+		di += matchLen
+	}
+	if di != len(dst) {
+		return errLZ4Corrupt
+	}
+	return nil
+}
+
+const (
+	xxPrime32_1 = 2654435761
+	xxPrime32_2 = 2246822519
+	xxPrime32_3 = 3266489917
+	xxPrime32_4 = 668265263
+	xxPrime32_5 = 374761393
+)
+
+// xxhash32 computes the 32-bit xxHash of b.
+func xxhash32(b []byte, seed uint32) uint32 {
+	round := func(acc, in uint32) uint32 {
+		return bits.RotateLeft32(acc+in*xxPrime32_2, 13) * xxPrime32_1
+	}
+
+	n := len(b)
+	var h uint32
+	if n >= 16 {
+		v1 := seed + xxPrime32_1 + xxPrime32_2
+		v2 := seed + xxPrime32_2
+		v3 := seed
+		v4 := seed - xxPrime32_1
+		for len(b) >= 16 {
+			v1 = round(v1, binary.LittleEndian.Uint32(b[0:]))
+			v2 = round(v2, binary.LittleEndian.Uint32(b[4:]))
+			v3 = round(v3, binary.LittleEndian.Uint32(b[8:]))
+			v4 = round(v4, binary.LittleEndian.Uint32(b[12:]))
+			b = b[16:]
+		}
+		h = bits.RotateLeft32(v1, 1) + bits.RotateLeft32(v2, 7) + bits.RotateLeft32(v3, 12) + bits.RotateLeft32(v4, 18)
+	} else {
+		h = seed + xxPrime32_5
+	}
+
+	h += uint32(n)
+	for ; len(b) >= 4; b = b[4:] {
+		h += binary.LittleEndian.Uint32(b) * xxPrime32_3
+		h = bits.RotateLeft32(h, 17) * xxPrime32_4
+	}
+	for _, c := range b {
+		h += uint32(c) * xxPrime32_5
+		h = bits.RotateLeft32(h, 11) * xxPrime32_1
+	}
+
+	h ^= h >> 15
+	h *= xxPrime32_2
+	h ^= h >> 13
+	h *= xxPrime32_3
+	h ^= h >> 16
+	return h
+}
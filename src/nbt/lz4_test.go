@@ -0,0 +1,72 @@
+package nbt
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"testing"
+)
+
+func TestXXHash32(t *testing.T) {
+	for _, tt := range []struct {
+		in   string
+		seed uint32
+		want uint32
+	}{
+		{"", 0, 0x02cc5d05},
+		{"abc", 0, 0x32d153ff},
+		{"Nobody inspects the spammish repetition", 0, 0xe2293b2f},
+	} {
+		if got := xxhash32([]byte(tt.in), tt.seed); got != tt.want {
+			t.Errorf("xxhash32(%q, %d) = %#x, want %#x", tt.in, tt.seed, got, tt.want)
+		}
+	}
+}
+
+// lz4Block frames a block the way LZ4BlockOutputStream does.
+func lz4Block(method byte, compressed, decompressed []byte) []byte {
+	b := []byte(lz4BlockMagic)
+	b = append(b, method|6) // 64KiB blocks
+	b = binary.LittleEndian.AppendUint32(b, uint32(len(compressed)))
+	b = binary.LittleEndian.AppendUint32(b, uint32(len(decompressed)))
+	var sum uint32
+	if len(decompressed) > 0 {
+		sum = xxhash32(decompressed, lz4ChecksumSeed) & lz4ChecksumMask
+	}
+	b = binary.LittleEndian.AppendUint32(b, sum)
+	return append(b, compressed...)
+}
+
+func TestLZ4BlockReader(t *testing.T) {
+	// "abc" followed by a 9 byte match at offset 3, then 5 literals
+	compressed := []byte{0x35, 'a', 'b', 'c', 3, 0, 0x50, 'h', 'e', 'l', 'l', 'o'}
+	// a run of 20 'x's, from a single literal and an overlapping match
+	run := []byte{0x1f, 'x', 1, 0, 0}
+
+	var stream []byte
+	stream = append(stream, lz4Block(lz4MethodLZ4, compressed, []byte("abcabcabcabchello"))...)
+	stream = append(stream, lz4Block(lz4MethodStored, []byte(" stored "), []byte(" stored "))...)
+	stream = append(stream, lz4Block(lz4MethodLZ4, run, bytes.Repeat([]byte("x"), 20))...)
+	stream = append(stream, lz4Block(lz4MethodStored, nil, nil)...)
+
+	r, err := Decompress(bytes.NewReader(stream))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "abcabcabcabchello stored xxxxxxxxxxxxxxxxxxxx"; string(got) != want {
+		t.Errorf("decompressed %q, want %q", got, want)
+	}
+
+	corrupt := lz4Block(lz4MethodLZ4, compressed, []byte("abcabcabcabchellp"))
+	if _, err := io.ReadAll(newLZ4BlockReader(bytes.NewReader(corrupt))); err == nil {
+		t.Errorf("checksum mismatch wasn't detected")
+	}
+	badOffset := lz4Block(lz4MethodLZ4, []byte{0x15, 'a', 9, 0}, []byte("aaaaaaaaaa"))
+	if _, err := io.ReadAll(newLZ4BlockReader(bytes.NewReader(badOffset))); err == nil {
+		t.Errorf("out of range match offset wasn't detected")
+	}
+}
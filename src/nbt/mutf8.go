@@ -0,0 +1,175 @@
+package nbt
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// mutf8.go
+// Java writes NBT strings in "Modified UTF-8" (see java.io.DataInput): NUL is
+// encoded as the two bytes 0xC0 0x80, and characters outside the basic
+// multilingual plane are encoded as a UTF-16 surrogate pair, with each half
+// encoded separately as a three byte sequence.
+
+// MUTF8Error describes a malformed Modified UTF-8 sequence. When returned by
+// NBTDecoder, Offset is the position of the offending byte in the input
+// stream; when returned by DecodeMUTF8 it is the position within the slice.
+type MUTF8Error struct {
+	Offset int64
+	msg    string
+}
+
+func (e *MUTF8Error) Error() string {
+	return fmt.Sprintf("nbt: malformed modified UTF-8 at offset %d: %s", e.Offset, e.msg)
+}
+
+// DecodeMUTF8 decodes a Modified UTF-8 byte sequence, returning a
+// *MUTF8Error for any malformed sequence.
+func DecodeMUTF8(b []byte) (string, error) {
+	return decodeMUTF8(b, true)
+}
+
+// decodeMUTF8 decodes a Modified UTF-8 byte sequence. If strict is false,
+// malformed sequences are replaced with U+FFFD instead of returning an error,
+// and plain UTF-8 encodings of NUL and supplementary characters (as written
+// by some third-party tools) are accepted, as are overlong encodings (which
+// java.io.DataInput accepts too). Strictly, only NUL may be overlong, so that
+// every string has a single encoding.
+func decodeMUTF8(b []byte, strict bool) (string, error) {
+	// fast path: most strings are plain ASCII, which is identical in all
+	// encodings
+	ascii := true
+	for _, c := range b {
+		if c == 0 || c >= 0x80 {
+			ascii = false
+			break
+		}
+	}
+	if ascii {
+		return string(b), nil
+	}
+
+	var sb strings.Builder
+	sb.Grow(len(b))
+
+	for i := 0; i < len(b); {
+		c := b[i]
+		switch {
+		case c == 0:
+			if strict {
+				return "", &MUTF8Error{Offset: int64(i), msg: "unencoded NUL byte"}
+			}
+			sb.WriteByte(0)
+			i++
+		case c < 0x80:
+			sb.WriteByte(c)
+			i++
+		case c&0xe0 == 0xc0: // 110xxxxx 10xxxxxx
+			if i+1 >= len(b) || b[i+1]&0xc0 != 0x80 {
+				if strict {
+					return "", &MUTF8Error{Offset: int64(i), msg: "truncated two byte sequence"}
+				}
+				sb.WriteRune(utf8.RuneError)
+				i++
+				continue
+			}
+			r := rune(c&0x1f)<<6 | rune(b[i+1]&0x3f)
+			// NUL is the only character Java encodes in more bytes than it
+			// needs
+			if strict && r < 0x80 && r != 0 {
+				return "", &MUTF8Error{Offset: int64(i), msg: "overlong two byte sequence"}
+			}
+			sb.WriteRune(r)
+			i += 2
+		case c&0xf0 == 0xe0: // 1110xxxx 10xxxxxx 10xxxxxx
+			unit, ok := decodeMUTF8Unit(b, i)
+			if !ok {
+				if strict {
+					return "", &MUTF8Error{Offset: int64(i), msg: "truncated three byte sequence"}
+				}
+				sb.WriteRune(utf8.RuneError)
+				i++
+				continue
+			}
+
+			switch {
+			case unit < 0x800 && strict:
+				return "", &MUTF8Error{Offset: int64(i), msg: "overlong three byte sequence"}
+			case unit >= 0xd800 && unit < 0xdc00:
+				// high surrogate, which must be followed by a low surrogate
+				low, ok := decodeMUTF8Unit(b, i+3)
+				if ok && low >= 0xdc00 && low < 0xe000 {
+					sb.WriteRune(0x10000 + (unit-0xd800)<<10 + (low - 0xdc00))
+					i += 6
+					continue
+				}
+				if strict {
+					return "", &MUTF8Error{Offset: int64(i), msg: "unpaired high surrogate"}
+				}
+				sb.WriteRune(utf8.RuneError)
+			case unit >= 0xdc00 && unit < 0xe000:
+				if strict {
+					return "", &MUTF8Error{Offset: int64(i), msg: "unpaired low surrogate"}
+				}
+				sb.WriteRune(utf8.RuneError)
+			default:
+				sb.WriteRune(unit)
+			}
+			i += 3
+		default:
+			// four byte sequences are valid UTF-8, but never produced by Java
+			if !strict {
+				if r, size := utf8.DecodeRune(b[i:]); r != utf8.RuneError {
+					sb.WriteRune(r)
+					i += size
+					continue
+				}
+				sb.WriteRune(utf8.RuneError)
+				i++
+				continue
+			}
+			return "", &MUTF8Error{Offset: int64(i), msg: fmt.Sprintf("invalid byte %#02x", c)}
+		}
+	}
+	return sb.String(), nil
+}
+
+// decodeMUTF8Unit decodes the three byte sequence at b[i:] into a single
+// UTF-16 code unit
+func decodeMUTF8Unit(b []byte, i int) (rune, bool) {
+	if i+2 >= len(b) || b[i]&0xf0 != 0xe0 || b[i+1]&0xc0 != 0x80 || b[i+2]&0xc0 != 0x80 {
+		return 0, false
+	}
+	return rune(b[i]&0x0f)<<12 | rune(b[i+1]&0x3f)<<6 | rune(b[i+2]&0x3f), true
+}
+
+// EncodeMUTF8 encodes a string as Modified UTF-8. Invalid UTF-8 in s is
+// encoded as U+FFFD.
+func EncodeMUTF8(s string) []byte {
+	return appendMUTF8(make([]byte, 0, len(s)), s)
+}
+
+func appendMUTF8(buf []byte, s string) []byte {
+	for _, r := range s {
+		switch {
+		case r == 0:
+			buf = append(buf, 0xc0, 0x80)
+		case r < 0x80:
+			buf = append(buf, byte(r))
+		case r < 0x800:
+			buf = append(buf, 0xc0|byte(r>>6), 0x80|byte(r&0x3f))
+		case r < 0x10000:
+			buf = appendMUTF8Unit(buf, r)
+		default:
+			r -= 0x10000
+			buf = appendMUTF8Unit(buf, 0xd800+(r>>10))
+			buf = appendMUTF8Unit(buf, 0xdc00+(r&0x3ff))
+		}
+	}
+	return buf
+}
+
+func appendMUTF8Unit(buf []byte, unit rune) []byte {
+	return append(buf, 0xe0|byte(unit>>12), 0x80|byte((unit>>6)&0x3f), 0x80|byte(unit&0x3f))
+}
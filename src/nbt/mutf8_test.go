@@ -0,0 +1,82 @@
+package nbt
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestDecodeMUTF8(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"", ""},
+		{"Level", "Level"},
+		{"\xc0\x80", "\x00"},
+		{"a\xc0\x80b", "a\x00b"},
+		{"\xc2\x80", "\u0080"},
+		{"\xc3\xa9t\xc3\xa9", "été"},
+		{"\xdf\xbf", "߿"},
+		{"\xe0\xa0\x80", "ࠀ"},
+		{"\xe2\x82\xac", "€"},
+		{"\xef\xbf\xbf", "￿"},
+		// supplementary characters as surrogate pairs
+		{"\xed\xa0\x80\xed\xb0\x80", "\U00010000"},
+		{"\xed\xa0\xbd\xed\xb8\x80", "😀"},
+		{"\xed\xaf\xbf\xed\xbf\xbf", "\U0010ffff"},
+	}
+	for _, tt := range tests {
+		got, err := DecodeMUTF8([]byte(tt.in))
+		if err != nil {
+			t.Errorf("DecodeMUTF8(%x): %v", tt.in, err)
+		} else if got != tt.want {
+			t.Errorf("DecodeMUTF8(%x) = %q, want %q", tt.in, got, tt.want)
+		}
+		if enc := EncodeMUTF8(tt.want); string(enc) != tt.in {
+			t.Errorf("EncodeMUTF8(%q) = %x, want %x", tt.want, enc, tt.in)
+		}
+	}
+}
+
+func TestDecodeMUTF8Invalid(t *testing.T) {
+	tests := []struct {
+		in     string
+		offset int64
+		// what the lenient decoder makes of it
+		lenient string
+	}{
+		{"\x00", 0, "\x00"},
+		{"a\xc3", 1, "a�"},
+		{"\xe2\x82", 0, "��"},
+		{"\x80", 0, "�"},
+		{"\xf0\x9f\x98\x80", 0, "😀"},
+		// overlong encodings, other than the two byte NUL
+		{"\xc0\x81", 0, "\x01"},
+		{"\xc0\xbf", 0, "?"},
+		{"\xc1\x80", 0, "@"},
+		{"\xc1\xbf", 0, "\x7f"},
+		{"\xe0\x80\x80", 0, "\x00"},
+		{"\xe0\x80\xbf", 0, "?"},
+		{"\xe0\x81\xbf", 0, "\x7f"},
+		{"\xe0\x82\x80", 0, "\u0080"},
+		{"ab\xe0\x9f\xbf", 2, "ab߿"},
+		// unpaired surrogates
+		{"\xed\xa0\x80", 0, "�"},
+		{"\xed\xb0\x80", 0, "�"},
+		{"\xed\xb0\x80\xed\xa0\x80", 0, "��"},
+		{"\xed\xa0\x80a", 0, "�a"},
+	}
+	for _, tt := range tests {
+		_, err := DecodeMUTF8([]byte(tt.in))
+		var merr *MUTF8Error
+		if !errors.As(err, &merr) {
+			t.Errorf("DecodeMUTF8(%x): got error %v, want a *MUTF8Error", tt.in, err)
+		} else if merr.Offset != tt.offset {
+			t.Errorf("DecodeMUTF8(%x): error at offset %d, want %d", tt.in, merr.Offset, tt.offset)
+		}
+
+		if got, err := decodeMUTF8([]byte(tt.in), false); err != nil || got != tt.lenient {
+			t.Errorf("lenient decodeMUTF8(%x) = %q, %v, want %q", tt.in, got, err, tt.lenient)
+		}
+	}
+}
@@ -1,12 +1,12 @@
 package nbt
 
 import (
-	"encoding/binary"
 	"errors"
 	"fmt"
 	"io"
 	"math"
 	"reflect"
+	"slices"
 )
 
 const (
@@ -52,143 +52,205 @@ type NBTUnmarshaler interface {
 }
 
 type NBTDecoder struct {
-	r NBTReader
+	r *bufReader
+
+	variant       Variant
+	namelessRoot  bool
+	strictStrings bool
+
+	limits    Limits
+	depth     int   // current nesting depth
+	allocated int64 // bytes allocated during the current call to Decode
+
+	path []pathSegment // path to the tag currently being decoded
+
+	defaultReader bufReader // reads struct field defaults (see applyDefaults)
+
+	caseInsensitive       bool
+	reinterpretUnsigned   bool
+	disallowUnknownFields bool
+	disallowCoercion      bool
+	recordUnknownFields   bool
+	unknownFields         []string
 }
 
+// NewDecoder returns a decoder that reads from r. The decoder buffers its
+// input, so it may read data from r beyond the NBT values requested (see
+// Buffered).
 func NewDecoder(r io.Reader) *NBTDecoder {
-	d := &NBTDecoder{}
-	if nbtR, ok := r.(NBTReader); ok {
-		d.r = nbtR
+	d := &NBTDecoder{limits: DefaultLimits}
+	if br, ok := r.(*bufReader); ok {
+		// a custom NBTUnmarshaler decoding from the reader it was given, which
+		// is already buffered
+		d.r = br
 	} else {
-		d.r = NBTByteReader{r}
+		d.r = newBufReader(r)
 	}
 	return d
 }
 
+// InputOffset returns the number of bytes the decoder has read so far.
+func (d *NBTDecoder) InputOffset() int64 {
+	return d.r.n
+}
+
+// SetVariant selects the binary layout of the NBT being read (JavaEdition by
+// default).
+func (d *NBTDecoder) SetVariant(v Variant) {
+	d.variant = v
+}
+
+// SetNamelessRoot controls whether the root tag is expected to have a name.
+// Java protocol 764+ (1.20.2) network NBT omits the root name. Bedrock network
+// NBT doesn't; its root name is usually empty, but still present.
+func (d *NBTDecoder) SetNamelessRoot(nameless bool) {
+	d.namelessRoot = nameless
+}
+
+// SetStrictStrings controls how malformed Modified UTF-8 in Java Edition
+// strings is handled. By default malformed sequences are replaced with
+// U+FFFD; in strict mode they cause a *MUTF8Error instead.
+func (d *NBTDecoder) SetStrictStrings(strict bool) {
+	d.strictStrings = strict
+}
+
+// SetCaseInsensitive controls whether tags that don't exactly match the name
+// or an alias of a struct field are matched ignoring case, eg. so that a
+// "Palette" tag is decoded into a field tagged `nbt:"palette"`.
+func (d *NBTDecoder) SetCaseInsensitive(caseInsensitive bool) {
+	d.caseInsensitive = caseInsensitive
+}
+
+// SetReinterpretUnsigned controls how a negative integer tag is decoded into
+// an unsigned go type. By default it's an overflow error. When reinterpret is
+// set, a tag of the width the encoder writes that type as (eg. TAG_Byte for
+// uint8) is read back as the unsigned value with the same bits, so -56b
+// decodes into a uint8 as 200, just as it was encoded.
+func (d *NBTDecoder) SetReinterpretUnsigned(reinterpret bool) {
+	d.reinterpretUnsigned = reinterpret
+}
+
+// DisallowUnknownFields causes Decode to return an error when a compound
+// contains a tag that doesn't match any field of the destination struct,
+// instead of silently discarding it.
+func (d *NBTDecoder) DisallowUnknownFields() {
+	d.disallowUnknownFields = true
+}
+
+// DisallowTypeCoercion causes Decode to return an error when a tag's type
+// differs from the type NBTEncoder would write for the destination field (eg.
+// a TAG_Short decoded into an int, which would be written as a TAG_Int).
+func (d *NBTDecoder) DisallowTypeCoercion() {
+	d.disallowCoercion = true
+}
+
+// RecordUnknownFields causes the decoder to record the path of every tag it
+// discards because it doesn't match a struct field. The paths are available
+// from UnknownFields.
+func (d *NBTDecoder) RecordUnknownFields() {
+	d.recordUnknownFields = true
+}
+
+// UnknownFields returns the paths of the tags discarded during the last call
+// to Decode, if RecordUnknownFields is enabled.
+func (d *NBTDecoder) UnknownFields() []string {
+	return d.unknownFields
+}
+
 // Decodes an NBT value from the decoder's reader into v.
 func (d *NBTDecoder) Decode(v any) (string, error) {
 	val := reflect.ValueOf(v)
 	if val.Kind() != reflect.Ptr {
 		return "", errors.New("nbt: non-pointer passed to Decode")
 	}
+	if val.IsNil() {
+		return "", errors.New("nbt: nil pointer passed to Decode")
+	}
+
+	d.allocated = 0
+	d.path = d.path[:0]
+	d.unknownFields = nil
 
 	// Read the top-level tag header (usually this is TAG_Compound)
-	tagType, tagName, err := d.ReadTagHeader()
+	tagType, tagName, err := d.readRootHeader()
 	if err != nil {
 		return tagName, err
 	}
 
 	err = d.unmarshal(val, tagType)
-	if err != nil {
-		return tagName, fmt.Errorf("nbt: failed to decode tag %q: %w", tagName, err)
+	return tagName, err
+}
+
+// callUnmarshaler hands the tag body to a custom unmarshaler. RawMessages are
+// captured by the decoder itself, so they respect its variant and limits.
+func (d *NBTDecoder) callUnmarshaler(u NBTUnmarshaler, tagType byte) error {
+	if m, ok := u.(*RawMessage); ok {
+		return d.readRaw(m, tagType)
 	}
-	return tagName, nil
+	return u.UnmarshalNBT(tagType, d.r)
 }
 
 // Reads the tag body from the decoder's reader (determined by tagType), and
-// unmarshals it into v if possible
+// unmarshals it into v if possible. Errors are returned as a *DecodeError
+// describing where the failure happened.
 func (d *NBTDecoder) unmarshal(val reflect.Value, tagType byte) error {
-	// ensure we have a settable pointer (or an unmarshaler)
-	u, t, val := indirect(val, tagType == TAG_End)
-	if u != nil {
-		return u.UnmarshalNBT(tagType, d.r)
+	if err := d.unmarshalValue(val, tagType); err != nil {
+		return d.wrapError(err, tagType, val.Type())
 	}
+	return nil
+}
 
-	switch tagType {
-	case TAG_End:
-		return errors.New("unexpected TAG_End")
-	case TAG_Byte:
-		byte, err := d.ReadInt8()
-		if err != nil {
-			return err
-		}
-		switch vk := val.Kind(); vk {
-		case reflect.Bool:
-			val.SetBool(byte != 0)
-		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
-			val.SetInt(int64(byte))
-		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
-			val.SetUint(uint64(byte))
-		case reflect.Interface:
-			val.Set(reflect.ValueOf(byte))
-		default:
-			return fmt.Errorf("can't unmarshal TAG_Byte into go type %q", vk.String())
-		}
-	case TAG_Short:
-		value, err := d.ReadInt16()
+func (d *NBTDecoder) unmarshalValue(val reflect.Value, tagType byte) error {
+	// generic trees (see tag.go) are read directly rather than via reflection
+	if val.Type() == tagInterfaceType {
+		tag, err := d.readTag(tagType)
 		if err != nil {
 			return err
 		}
-		switch vk := val.Kind(); vk {
-		case reflect.Int, reflect.Int16, reflect.Int32, reflect.Int64:
-			val.SetInt(int64(value))
-		case reflect.Uint, reflect.Uint16, reflect.Uint32, reflect.Uint64:
-			val.SetUint(uint64(value))
-		case reflect.Interface:
-			val.Set(reflect.ValueOf(value))
-		default:
-			return fmt.Errorf("can't unmarshal TAG_Short into go type %q", vk.String())
-		}
-	case TAG_Int:
-		value, err := d.ReadInt32()
-		if err != nil {
-			return err
-		}
-		switch vk := val.Kind(); vk {
-		case reflect.Int, reflect.Int32, reflect.Int64:
-			val.SetInt(int64(value))
-		case reflect.Uint, reflect.Uint32, reflect.Uint64:
-			val.SetUint(uint64(value))
-		case reflect.Interface:
-			val.Set(reflect.ValueOf(value))
-		default:
-			return fmt.Errorf("can't unmarshal TAG_Int into go type %q", vk.String())
-		}
-	case TAG_Long:
-		value, err := d.ReadInt64()
+		val.Set(reflect.ValueOf(tag))
+		return nil
+	}
+
+	// ensure we have a settable pointer (or an unmarshaler)
+	u, t, val := indirect(val, tagType == TAG_End)
+	if u != nil {
+		return d.callUnmarshaler(u, tagType)
+	}
+	if t == nil && (val.Type() == tagInterfaceType || treeTypes[val.Type()]) {
+		tag, err := d.readTag(tagType)
 		if err != nil {
 			return err
 		}
-		switch vk := val.Kind(); vk {
-		case reflect.Int, reflect.Int64:
-			val.SetInt(int64(value))
-		case reflect.Uint, reflect.Uint64:
-			val.SetUint(uint64(value))
-		case reflect.Interface:
-			val.Set(reflect.ValueOf(value))
-		default:
-			return fmt.Errorf("can't unmarshal TAG_Long into go type %q", vk.String())
+		return setTreeTag(val, tag)
+	}
+	if val.Kind() == reflect.Interface && val.NumMethod() != 0 {
+		// only an empty interface can hold the go types used for tags
+		return fmt.Errorf("can't unmarshal %s into go type %q", TagName(tagType), val.Type().String())
+	}
+	if d.disallowCoercion && t == nil && val.Kind() != reflect.Interface {
+		// the zero value of the destination tells us what the encoder would write
+		if want, err := tagTypeOf(reflect.New(val.Type()).Elem()); err == nil && want != tagType {
+			return fmt.Errorf("can't unmarshal %s into go type %q without coercion (expected %s)", TagName(tagType), val.Type().String(), TagName(want))
 		}
-	case TAG_Float:
-		vInt, err := d.ReadInt32()
+	}
+
+	switch tagType {
+	case TAG_End:
+		return errors.New("unexpected TAG_End")
+	case TAG_Byte, TAG_Short, TAG_Int, TAG_Long:
+		value, err := d.readInteger(tagType)
 		if err != nil {
 			return err
 		}
-		value := math.Float32frombits(uint32(vInt))
-		switch vk := val.Kind(); vk {
-		case reflect.Float32, reflect.Float64:
-			val.SetFloat(float64(value))
-		case reflect.Interface:
-			val.Set(reflect.ValueOf(value))
-		default:
-			return fmt.Errorf("can't unmarshal TAG_Float into go type %q", vk.String())
-		}
-	case TAG_Double:
-		vInt, err := d.ReadInt64()
+		return setInteger(val, tagType, value, d.reinterpretUnsigned)
+	case TAG_Float, TAG_Double:
+		value, err := d.readFloat(tagType)
 		if err != nil {
 			return err
 		}
-		value := math.Float64frombits(uint64(vInt))
-		switch vk := val.Kind(); vk {
-		case reflect.Float64:
-			val.SetFloat(float64(value))
-		case reflect.Interface:
-			val.Set(reflect.ValueOf(value))
-		default:
-			return fmt.Errorf("can't unmarshal TAG_Double into go type %q", vk.String())
-		}
+		return setFloat(val, tagType, value)
 	case TAG_Byte_Array:
-		arrayLen, err := d.ReadInt32()
+		arrayLen, err := d.readArrayLen()
 		if err != nil {
 			return err
 		}
@@ -197,7 +259,7 @@ func (d *NBTDecoder) unmarshal(val reflect.Value, tagType byte) error {
 		vk := vt.Kind()
 		if vk == reflect.Interface {
 			vt = reflect.TypeOf([]byte{})
-		} else if vk == reflect.Array && vt.Len() != int(arrayLen) {
+		} else if vk == reflect.Array && vt.Len() != arrayLen {
 			return fmt.Errorf("can't unmarshal TAG_Byte_Array into %q - length does not match", vt.String())
 		} else if vk != reflect.Slice && vk != reflect.Array {
 			return fmt.Errorf("can't unmarshal TAG_Byte_Array into go type %q", vt.String())
@@ -205,24 +267,24 @@ func (d *NBTDecoder) unmarshal(val reflect.Value, tagType byte) error {
 			return fmt.Errorf("can't unmarshal TAG_Byte_Array into go type %q", vt.String())
 		}
 
-		// if we're working with an array, we can write directly to it. if we're
-		// working with a slice, we need to allocate a new one with the correct
-		// size
-		buf := val
-		if vk != reflect.Array {
-			buf = reflect.MakeSlice(vt, int(arrayLen), int(arrayLen))
-		}
-		for i := 0; i < int(arrayLen); i++ {
-			byte, err := d.r.ReadByte()
-			if err != nil {
-				return err
+		fill := func(part reflect.Value, _ int) error {
+			if dst, ok := bulkSlice[byte](part); ok {
+				return d.readBytes(dst)
 			}
-			buf.Index(i).Set(reflect.ValueOf(byte))
-		}
-
-		if vk != reflect.Array {
-			val.Set(buf)
+			for i := 0; i < part.Len(); i++ {
+				byte, err := d.r.ReadByte()
+				if err != nil {
+					return err
+				}
+				if elem := part.Index(i); elem.Kind() == reflect.Int8 {
+					elem.SetInt(int64(int8(byte)))
+				} else {
+					elem.SetUint(uint64(byte))
+				}
+			}
+			return nil
 		}
+		return d.fillArray(val, vt, arrayLen, fill)
 
 	case TAG_String:
 		str, err := d.ReadString()
@@ -230,10 +292,7 @@ func (d *NBTDecoder) unmarshal(val reflect.Value, tagType byte) error {
 			return err
 		}
 		if t != nil {
-			err := t.UnmarshalText([]byte(str))
-			if err != nil {
-				return err
-			}
+			return t.UnmarshalText([]byte(str))
 		}
 
 		switch vk := val.Kind(); vk {
@@ -245,48 +304,52 @@ func (d *NBTDecoder) unmarshal(val reflect.Value, tagType byte) error {
 			return fmt.Errorf("can't unmarshal TAG_String into go type %q", vk.String())
 		}
 	case TAG_List:
-		listType, err := d.r.ReadByte()
-		if err != nil {
+		if err := d.enter(); err != nil {
 			return err
 		}
+		defer d.leave()
 
-		listLen, err := d.ReadInt32()
+		listType, listLen, err := d.readListHeader()
 		if err != nil {
 			return err
 		}
-		if listLen < 0 {
-			return errors.New("can't unmarshal TAG_List with negative length")
-		}
 
-		var buf reflect.Value
-		vk := val.Kind()
-		switch vk {
+		vt := val.Type()
+		switch vk := vt.Kind(); vk {
 		case reflect.Interface:
-			buf = reflect.ValueOf(make([]any, listLen))
+			vt = reflect.TypeFor[[]any]()
 		case reflect.Slice:
-			buf = reflect.MakeSlice(val.Type(), int(listLen), int(listLen))
 		case reflect.Array:
-			if arrLen := val.Len(); arrLen < int(listLen) {
+			if arrLen := val.Len(); arrLen < listLen {
 				return fmt.Errorf("can't unmarshal TAG_List of len %d into array of len %d", listLen, arrLen)
 			}
-			buf = val
 		default:
 			return fmt.Errorf("can't unmarshal TAG_List into go type %q", vk.String())
 		}
 
-		for i := 0; i < int(listLen); i++ {
-			if err := d.unmarshal(buf.Index(i), listType); err != nil {
-				return err
+		fill := func(part reflect.Value, start int) error {
+			for i := 0; i < part.Len(); i++ {
+				d.pushIndex(start + i)
+				if err := d.unmarshal(part.Index(i), listType); err != nil {
+					return err
+				}
+				d.pop()
 			}
+			return nil
 		}
-
-		if vk != reflect.Array {
-			val.Set(buf)
+		if val.Kind() == reflect.Array {
+			return fill(val.Slice(0, listLen), 0)
 		}
+		return d.fillArray(val, vt, listLen, fill)
 	case TAG_Compound:
+		if err := d.enter(); err != nil {
+			return err
+		}
+		defer d.leave()
+
 		u, ut, val := indirect(val, false)
 		if u != nil {
-			return u.UnmarshalNBT(tagType, d.r)
+			return d.callUnmarshaler(u, tagType)
 		}
 		if ut != nil {
 			return errors.New("can't unmarshal TAG_Compound into a string")
@@ -296,6 +359,10 @@ func (d *NBTDecoder) unmarshal(val reflect.Value, tagType byte) error {
 		case reflect.Struct:
 			// parse the struct fields and their struct tags
 			fields := cachedTypeFields(val.Type())
+			var seen map[string]bool
+			if fields.hasDefaults {
+				seen = make(map[string]bool)
+			}
 			for {
 				fieldTagType, fieldTagName, err := d.ReadTagHeader()
 				if err != nil {
@@ -304,41 +371,42 @@ func (d *NBTDecoder) unmarshal(val reflect.Value, tagType byte) error {
 				if fieldTagType == TAG_End {
 					break
 				}
+				d.pushName(fieldTagName)
 
-				f, ok := fields.byExactName[fieldTagName]
+				f, ok := fields.lookup(fieldTagName, d.caseInsensitive)
 				if ok {
-					val := val
 					// if the struct embeds other structs, we need to walk down the tree
 					// until we reach the actual location of the field we're trying to
 					// set. f.index contains a path to traverse, where the values are the
 					// index of the field at each level where the next level can be found
-					for _, i := range f.index {
-						if val.Kind() == reflect.Pointer {
-							// if val points to an uninitialized pointer, initialize it.
-							if val.IsNil() {
-								// check if the field is an exported value
-								if !val.CanSet() {
-									return fmt.Errorf("cannot set embedded pointer to unexported struct: %v", val.Type().Elem())
-								}
-								val.Set(reflect.New(val.Type().Elem()))
-							}
-							val = val.Elem()
-						}
-						val = val.Field(i)
-					}
-
-					err = d.unmarshal(val, fieldTagType)
+					fv, err := fieldForDecode(val, f.index)
 					if err != nil {
-						// wrap error so we know where it's coming from
-						return fmt.Errorf("failed to decode field %q in TAG_Compound: %w", fieldTagName, err)
+						return err
+					}
+					if err := d.unmarshalField(fv, f, fieldTagType); err != nil {
+						return err
+					}
+					if seen != nil {
+						seen[f.name] = true
 					}
 				} else {
-					// fmt.Printf("no matching struct field found for tagname %q (type %#02x) - discarding\n", fieldTagName, fieldTagType)
+					if d.disallowUnknownFields {
+						return d.wrapError(fmt.Errorf("no field matches tag %q in go type %q", fieldTagName, val.Type().String()), fieldTagType, nil)
+					}
+					if d.recordUnknownFields {
+						d.unknownFields = append(d.unknownFields, d.pathString())
+					}
 					if err := d.ReadAndDiscardTag(fieldTagType); err != nil {
 						// if we can't find a field to write the tag to, discard it
 						return err
 					}
 				}
+				d.pop()
+			}
+			if fields.hasDefaults {
+				if err := d.applyDefaults(val, &fields, seen); err != nil {
+					return err
+				}
 			}
 
 		case reflect.Map:
@@ -357,11 +425,13 @@ func (d *NBTDecoder) unmarshal(val reflect.Value, tagType byte) error {
 				if fieldTagType == TAG_End {
 					break
 				}
+				d.pushName(fieldTagName)
 				v := reflect.New(vt.Elem())
 				if err = d.unmarshal(v.Elem(), fieldTagType); err != nil {
-					return fmt.Errorf("failed to decode field %q in TAG_Compound: %w", fieldTagName, err)
+					return err
 				}
 				val.SetMapIndex(reflect.ValueOf(fieldTagName), v.Elem())
+				d.pop()
 			}
 		case reflect.Interface:
 			buf := make(map[string]any)
@@ -373,18 +443,20 @@ func (d *NBTDecoder) unmarshal(val reflect.Value, tagType byte) error {
 				if fieldTagType == TAG_End {
 					break
 				}
+				d.pushName(fieldTagName)
 				var value any
 				if err = d.unmarshal(reflect.ValueOf(&value).Elem(), fieldTagType); err != nil {
-					return fmt.Errorf("failed to decode field %q in TAG_Compound: %w", fieldTagName, err)
+					return err
 				}
 				buf[fieldTagName] = value
+				d.pop()
 			}
 			val.Set(reflect.ValueOf(buf))
 		default:
 			return fmt.Errorf("can't unmarshal TAG_Compound into go type %q", vk.String())
 		}
 	case TAG_Int_Array:
-		arrayLen, err := d.ReadInt32()
+		arrayLen, err := d.readArrayLen()
 		if err != nil {
 			return err
 		}
@@ -393,7 +465,7 @@ func (d *NBTDecoder) unmarshal(val reflect.Value, tagType byte) error {
 		vk := vt.Kind()
 		if vk == reflect.Interface {
 			vt = reflect.TypeOf([]int32{})
-		} else if vk == reflect.Array && vt.Len() != int(arrayLen) {
+		} else if vk == reflect.Array && vt.Len() != arrayLen {
 			return fmt.Errorf("can't unmarshal TAG_Int_Array into %q - length does not match", vt.String())
 		} else if vk != reflect.Slice && vk != reflect.Array {
 			return fmt.Errorf("can't unmarshal TAG_Int_Array into go type %q", vt.String())
@@ -401,27 +473,23 @@ func (d *NBTDecoder) unmarshal(val reflect.Value, tagType byte) error {
 			return fmt.Errorf("can't unmarshal TAG_Int_Array into go type %q", vt.String())
 		}
 
-		// if we're working with an array, we can write directly to it. if we're
-		// working with a slice, we need to allocate a new one with the correct
-		// size
-		buf := val
-		if vk != reflect.Array {
-			buf = reflect.MakeSlice(vt, int(arrayLen), int(arrayLen))
-		}
-		for i := 0; i < int(arrayLen); i++ {
-			value, err := d.ReadInt32()
-			if err != nil {
-				return err
+		fill := func(part reflect.Value, _ int) error {
+			if dst, ok := bulkSlice[int32](part); ok {
+				return d.readInt32s(dst)
 			}
-			buf.Index(i).SetInt(int64(value))
-		}
-
-		if vk != reflect.Array {
-			val.Set(buf)
+			for i := 0; i < part.Len(); i++ {
+				value, err := d.ReadInt32()
+				if err != nil {
+					return err
+				}
+				part.Index(i).SetInt(int64(value))
+			}
+			return nil
 		}
+		return d.fillArray(val, vt, arrayLen, fill)
 
 	case TAG_Long_Array:
-		arrayLen, err := d.ReadInt32()
+		arrayLen, err := d.readArrayLen()
 		if err != nil {
 			return err
 		}
@@ -430,7 +498,7 @@ func (d *NBTDecoder) unmarshal(val reflect.Value, tagType byte) error {
 		vk := vt.Kind()
 		if vk == reflect.Interface {
 			vt = reflect.TypeOf([]int64{})
-		} else if vk == reflect.Array && vt.Len() != int(arrayLen) {
+		} else if vk == reflect.Array && vt.Len() != arrayLen {
 			return fmt.Errorf("can't unmarshal TAG_Long_Array into %q - length does not match", vt.String())
 		} else if vk != reflect.Slice && vk != reflect.Array {
 			return fmt.Errorf("can't unmarshal TAG_Long_Array into go type %q", vt.String())
@@ -438,24 +506,20 @@ func (d *NBTDecoder) unmarshal(val reflect.Value, tagType byte) error {
 			return fmt.Errorf("can't unmarshal TAG_Long_Array into go type %q", vt.String())
 		}
 
-		// if we're working with an array, we can write directly to it. if we're
-		// working with a slice, we need to allocate a new one with the correct
-		// size
-		buf := val
-		if vk == reflect.Slice {
-			buf = reflect.MakeSlice(vt, int(arrayLen), int(arrayLen))
-		}
-		for i := 0; i < int(arrayLen); i++ {
-			value, err := d.ReadInt64()
-			if err != nil {
-				return err
+		fill := func(part reflect.Value, _ int) error {
+			if dst, ok := bulkSlice[int64](part); ok {
+				return d.readInt64s(dst)
 			}
-			buf.Index(i).SetInt(value)
-		}
-
-		if vt.Kind() == reflect.Slice {
-			val.Set(buf)
+			for i := 0; i < part.Len(); i++ {
+				value, err := d.ReadInt64()
+				if err != nil {
+					return err
+				}
+				part.Index(i).SetInt(value)
+			}
+			return nil
 		}
+		return d.fillArray(val, vt, arrayLen, fill)
 
 	default:
 		return fmt.Errorf("can't unmarshal unknown tag type %#02x", tagType)
@@ -465,86 +529,134 @@ func (d *NBTDecoder) unmarshal(val reflect.Value, tagType byte) error {
 
 // Read primitives
 
+// ReadAndDiscardTag reads the payload of a tag of the given type without
+// storing it. Errors are returned as a *DecodeError, as for Decode.
 func (d *NBTDecoder) ReadAndDiscardTag(tagType byte) error {
-	var buf [8]byte
+	if err := d.discardTag(tagType); err != nil {
+		return d.wrapError(err, tagType, nil)
+	}
+	return nil
+}
+
+func (d *NBTDecoder) discardTag(tagType byte) error {
 	switch tagType {
+	case TAG_End:
+		return errors.New("unexpected TAG_End")
 	case TAG_Byte:
 		_, err := d.r.ReadByte()
 		return err
 	case TAG_Short:
-		_, err := io.ReadFull(d.r, buf[:2])
-		return err
-	case TAG_Int, TAG_Float:
-		_, err := io.ReadFull(d.r, buf[:4])
+		return d.r.skip(2)
+	case TAG_Int:
+		_, err := d.ReadInt32()
 		return err
-	case TAG_Long, TAG_Double:
-		_, err := io.ReadFull(d.r, buf[:8])
+	case TAG_Long:
+		_, err := d.ReadInt64()
 		return err
+	case TAG_Float:
+		return d.r.skip(4)
+	case TAG_Double:
+		return d.r.skip(8)
 	case TAG_Byte_Array:
-		length, err := d.ReadInt32()
+		length, err := d.readArrayLen()
 		if err != nil {
 			return err
 		}
 
-		if _, err := io.CopyN(io.Discard, d.r, int64(length)); err != nil {
+		if err := d.r.skip(int64(length)); err != nil {
 			return err
 		}
 	case TAG_String:
 		_, err := d.ReadString()
 		return err
 	case TAG_List:
-		elemType, err := d.r.ReadByte()
-		if err != nil {
+		if err := d.enter(); err != nil {
 			return err
 		}
-		length, err := d.ReadInt32()
+		defer d.leave()
+
+		elemType, length, err := d.readListHeader()
 		if err != nil {
 			return err
 		}
-		for i := 0; i < int(length); i++ {
+		for i := 0; i < length; i++ {
+			d.pushIndex(i)
 			if err := d.ReadAndDiscardTag(elemType); err != nil {
 				return err
 			}
+			d.pop()
 		}
 	case TAG_Compound:
+		if err := d.enter(); err != nil {
+			return err
+		}
+		defer d.leave()
+
 		for {
-			innerTagType, _, err := d.ReadTagHeader()
+			innerTagType, innerTagName, err := d.ReadTagHeader()
 			if err != nil {
 				return err
 			}
 			if innerTagType == TAG_End {
 				break
 			}
+			d.pushName(innerTagName)
 			err = d.ReadAndDiscardTag(innerTagType)
 			if err != nil {
 				return err
 			}
+			d.pop()
 		}
 
 	case TAG_Int_Array:
-		length, err := d.ReadInt32()
+		length, err := d.readArrayLen()
 		if err != nil {
 			return err
 		}
 
-		if _, err := io.CopyN(io.Discard, d.r, int64(length)*4); err != nil {
+		if d.variant == BedrockNetwork {
+			// elements are varints, so they have to be read one at a time
+			for i := 0; i < length; i++ {
+				if _, err := d.ReadInt32(); err != nil {
+					return err
+				}
+			}
+		} else if err := d.r.skip(int64(length) * 4); err != nil {
 			return err
 		}
 
 	case TAG_Long_Array:
-		length, err := d.ReadInt32()
+		length, err := d.readArrayLen()
 		if err != nil {
 			return err
 		}
 
-		if _, err := io.CopyN(io.Discard, d.r, int64(length)*8); err != nil {
+		if d.variant == BedrockNetwork {
+			for i := 0; i < length; i++ {
+				if _, err := d.ReadInt64(); err != nil {
+					return err
+				}
+			}
+		} else if err := d.r.skip(int64(length) * 8); err != nil {
 			return err
 		}
 
+	default:
+		return fmt.Errorf("can't discard unknown tag type %#02x", tagType)
 	}
 	return nil
 }
 
+// readRootHeader reads the header of the top-level tag, which has no name if
+// SetNamelessRoot is enabled.
+func (d *NBTDecoder) readRootHeader() (byte, string, error) {
+	if !d.namelessRoot {
+		return d.ReadTagHeader()
+	}
+	tagType, err := d.r.ReadByte()
+	return tagType, "", err
+}
+
 func (d *NBTDecoder) ReadTagHeader() (byte, string, error) {
 	var tagType byte
 	var tagName string
@@ -574,35 +686,108 @@ func (d *NBTDecoder) ReadInt8() (int8, error) {
 	return int8(byte), err
 }
 func (d *NBTDecoder) ReadInt16() (int16, error) {
-	var v int16
-	err := binary.Read(d.r, binary.BigEndian, &v)
-	return v, err
+	buf, err := d.r.next(2)
+	if err != nil {
+		return 0, err
+	}
+	return int16(d.variant.byteOrder().Uint16(buf)), nil
 }
 func (d *NBTDecoder) ReadInt32() (int32, error) {
-	var v int32
-	err := binary.Read(d.r, binary.BigEndian, &v)
-	return v, err
+	if d.variant == BedrockNetwork {
+		v, err := readUvarint(d.r, 35)
+		return unzigzag32(uint32(v)), err
+	}
+	buf, err := d.r.next(4)
+	if err != nil {
+		return 0, err
+	}
+	return int32(d.variant.byteOrder().Uint32(buf)), nil
 }
 func (d *NBTDecoder) ReadInt64() (int64, error) {
-	var v int64
-	err := binary.Read(d.r, binary.BigEndian, &v)
-	return v, err
+	if d.variant == BedrockNetwork {
+		v, err := readUvarint(d.r, 70)
+		return unzigzag64(v), err
+	}
+	buf, err := d.r.next(8)
+	if err != nil {
+		return 0, err
+	}
+	return int64(d.variant.byteOrder().Uint64(buf)), nil
+}
+func (d *NBTDecoder) ReadFloat32() (float32, error) {
+	buf, err := d.r.next(4)
+	if err != nil {
+		return 0, err
+	}
+	return math.Float32frombits(d.variant.byteOrder().Uint32(buf)), nil
+}
+func (d *NBTDecoder) ReadFloat64() (float64, error) {
+	buf, err := d.r.next(8)
+	if err != nil {
+		return 0, err
+	}
+	return math.Float64frombits(d.variant.byteOrder().Uint64(buf)), nil
+}
+
+// readStringLen reads the length prefix of a string, which is an unsigned
+// short for both Java and Bedrock Edition, and an unsigned varint for Bedrock
+// network NBT.
+func (d *NBTDecoder) readStringLen() (int, error) {
+	if d.variant == BedrockNetwork {
+		v, err := readUvarint(d.r, 35)
+		if err == nil && v > math.MaxInt32 {
+			err = errVarintOverflow
+		}
+		return int(v), err
+	}
+	v, err := d.ReadInt16()
+	return int(uint16(v)), err
 }
+
+// Reads a string. Java Edition strings are decoded from Modified UTF-8 (see
+// mutf8.go), while Bedrock strings are plain UTF-8.
 func (d *NBTDecoder) ReadString() (string, error) {
-	strLen, err := d.ReadInt16()
+	strLen, err := d.readStringLen()
 	if err != nil {
 		return "", err
 	}
 
-	if strLen < 0 {
-		return "", errors.New("string has negative length")
+	if strLen == 0 {
+		return "", nil
+	}
+	if d.limits.MaxStringLen > 0 && strLen > d.limits.MaxStringLen {
+		return "", d.limitError(ErrMaxStringLen, int64(d.limits.MaxStringLen), int64(strLen))
+	}
+	if err := d.alloc(int64(strLen)); err != nil {
+		return "", err
+	}
+
+	start := d.r.n
+	var buffer []byte
+	if strLen <= readBufSize {
+		// decoded straight out of the read buffer; both paths below copy it
+		buffer, err = d.r.next(strLen)
+	} else {
+		// grown as the data arrives, as the length is only a varint in
+		// BedrockNetwork
+		for len(buffer) < strLen && err == nil {
+			buffer = slices.Grow(buffer, min(strLen-len(buffer), max(len(buffer), maxPrealloc)))
+			var n int
+			n, err = io.ReadFull(d.r, buffer[len(buffer):min(cap(buffer), strLen)])
+			buffer = buffer[:len(buffer)+n]
+		}
+	}
+	if err != nil {
+		return "", err
+	}
+	if d.variant != JavaEdition {
+		return string(buffer), nil
 	}
 
-	var str string
-	if strLen > 0 {
-		buffer := make([]byte, strLen)
-		_, err = io.ReadFull(d.r, buffer)
-		str = string(buffer)
+	str, err := decodeMUTF8(buffer, d.strictStrings)
+	if mErr, ok := err.(*MUTF8Error); ok {
+		// report the position in the stream rather than in the string
+		mErr.Offset += start
 	}
 	return str, err
 }
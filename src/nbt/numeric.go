@@ -0,0 +1,177 @@
+package nbt
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+)
+
+// numeric.go
+// decoding of numeric tags. Mods and older versions of the game don't always
+// agree on which tag type a value is stored as (eg. SpawnY as a TAG_Short
+// rather than a TAG_Int), so any numeric tag can be decoded into any go
+// number type, as long as the value survives the conversion:
+//
+//	tag                      into int*, uint*         into float32, float64
+//	TAG_Byte .. TAG_Long     if the value is in range  if the value is exactly
+//	                                                   representable
+//	TAG_Float, TAG_Double    if the value is a whole   float64 always, float32
+//	                         number and in range       if in range (rounding)
+//
+// Anything else, such as a negative value into an unsigned type, is an error
+// rather than a silent overflow. The encoder writes the bits of an unsigned
+// value into the signed tag of the same width (eg. uint8 200 as -56b), so
+// with SetReinterpretUnsigned a negative tag of that width is read back as the
+// unsigned value with the same bits instead. A TAG_Byte may also be decoded
+// into a bool.
+//
+// Decoding into an interface gives the tag's own go type (int8, int16, int32,
+// int64, float32 or float64), which the encoder maps back to the same tag, so
+// the value round trips unchanged.
+
+// readInteger reads the payload of a TAG_Byte, TAG_Short, TAG_Int or TAG_Long.
+func (d *NBTDecoder) readInteger(tagType byte) (int64, error) {
+	switch tagType {
+	case TAG_Byte:
+		v, err := d.ReadInt8()
+		return int64(v), err
+	case TAG_Short:
+		v, err := d.ReadInt16()
+		return int64(v), err
+	case TAG_Int:
+		v, err := d.ReadInt32()
+		return int64(v), err
+	case TAG_Long:
+		return d.ReadInt64()
+	}
+	return 0, fmt.Errorf("%s is not an integer tag", TagName(tagType))
+}
+
+// readFloat reads the payload of a TAG_Float or TAG_Double.
+func (d *NBTDecoder) readFloat(tagType byte) (float64, error) {
+	switch tagType {
+	case TAG_Float:
+		v, err := d.ReadFloat32()
+		return float64(v), err
+	case TAG_Double:
+		return d.ReadFloat64()
+	}
+	return 0, fmt.Errorf("%s is not a floating point tag", TagName(tagType))
+}
+
+// integerValue returns v as the go type of an integer tag.
+func integerValue(tagType byte, v int64) reflect.Value {
+	switch tagType {
+	case TAG_Byte:
+		return reflect.ValueOf(int8(v))
+	case TAG_Short:
+		return reflect.ValueOf(int16(v))
+	case TAG_Int:
+		return reflect.ValueOf(int32(v))
+	}
+	return reflect.ValueOf(v)
+}
+
+// unsignedTagBits returns the width of tagType if it's the tag the encoder
+// writes unsigned go type vk as.
+func unsignedTagBits(vk reflect.Kind, tagType byte) (int, bool) {
+	switch {
+	case vk == reflect.Uint8 && tagType == TAG_Byte:
+		return 8, true
+	case vk == reflect.Uint16 && tagType == TAG_Short:
+		return 16, true
+	case (vk == reflect.Uint || vk == reflect.Uint32) && tagType == TAG_Int:
+		return 32, true
+	case vk == reflect.Uint64 && tagType == TAG_Long:
+		return 64, true
+	}
+	return 0, false
+}
+
+func overflowError(tagType byte, v any, t reflect.Type) error {
+	return fmt.Errorf("%s value %v overflows go type %q", TagName(tagType), v, t.String())
+}
+
+// setInteger stores the value of an integer tag in val. If reinterpret is set,
+// a negative value of the width the encoder writes val's unsigned type as is
+// stored as the unsigned value with the same bits.
+func setInteger(val reflect.Value, tagType byte, v int64, reinterpret bool) error {
+	switch vk := val.Kind(); vk {
+	case reflect.Bool:
+		if tagType != TAG_Byte {
+			return fmt.Errorf("can't unmarshal %s into go type %q", TagName(tagType), vk.String())
+		}
+		val.SetBool(v != 0)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if val.OverflowInt(v) {
+			return overflowError(tagType, v, val.Type())
+		}
+		val.SetInt(v)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		u := uint64(v)
+		if v < 0 {
+			bits, ok := unsignedTagBits(vk, tagType)
+			if !ok || !reinterpret {
+				return overflowError(tagType, v, val.Type())
+			}
+			u &= math.MaxUint64 >> (64 - bits)
+		}
+		if val.OverflowUint(u) {
+			return overflowError(tagType, v, val.Type())
+		}
+		val.SetUint(u)
+	case reflect.Float32, reflect.Float64:
+		f := float64(v)
+		if vk == reflect.Float32 {
+			f = float64(float32(f))
+		}
+		// 1<<63 is exactly representable, but doesn't fit in an int64
+		if f >= 1<<63 || int64(f) != v {
+			return fmt.Errorf("%s value %d can't be represented exactly by go type %q", TagName(tagType), v, val.Type().String())
+		}
+		val.SetFloat(f)
+	case reflect.Interface:
+		val.Set(integerValue(tagType, v))
+	default:
+		return fmt.Errorf("can't unmarshal %s into go type %q", TagName(tagType), vk.String())
+	}
+	return nil
+}
+
+// setFloat stores the value of a TAG_Float or TAG_Double in val.
+func setFloat(val reflect.Value, tagType byte, f float64) error {
+	switch vk := val.Kind(); vk {
+	case reflect.Float32:
+		if math.Abs(f) > math.MaxFloat32 && !math.IsInf(f, 0) {
+			return overflowError(tagType, f, val.Type())
+		}
+		val.SetFloat(f)
+	case reflect.Float64:
+		val.SetFloat(f)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if f != math.Trunc(f) {
+			return fmt.Errorf("%s value %v is not a whole number, so can't be stored in go type %q", TagName(tagType), f, val.Type().String())
+		}
+		if f < -(1<<63) || f >= 1<<63 || val.OverflowInt(int64(f)) {
+			return overflowError(tagType, f, val.Type())
+		}
+		val.SetInt(int64(f))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if f != math.Trunc(f) {
+			return fmt.Errorf("%s value %v is not a whole number, so can't be stored in go type %q", TagName(tagType), f, val.Type().String())
+		}
+		if f < 0 || f >= 1<<64 || val.OverflowUint(uint64(f)) {
+			return overflowError(tagType, f, val.Type())
+		}
+		val.SetUint(uint64(f))
+	case reflect.Interface:
+		if tagType == TAG_Float {
+			val.Set(reflect.ValueOf(float32(f)))
+		} else {
+			val.Set(reflect.ValueOf(f))
+		}
+	default:
+		return fmt.Errorf("can't unmarshal %s into go type %q", TagName(tagType), vk.String())
+	}
+	return nil
+}
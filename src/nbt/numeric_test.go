@@ -0,0 +1,127 @@
+package nbt
+
+import (
+	"bytes"
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestNumericConversions(t *testing.T) {
+	type Level struct {
+		SpawnY     int     `nbt:"SpawnY"`
+		Count      uint8   `nbt:"Count"`
+		Seed       uint64  `nbt:"Seed"`
+		Scale      float32 `nbt:"Scale"`
+		BorderSize float64 `nbt:"BorderSize"`
+		Small      int8    `nbt:"Small"`
+		Any        any     `nbt:"Any"`
+	}
+
+	tests := []struct {
+		snbt string
+		want Level
+	}{
+		{`{SpawnY:64s}`, Level{SpawnY: 64}},
+		{`{SpawnY:64b}`, Level{SpawnY: 64}},
+		{`{SpawnY:64L}`, Level{SpawnY: 64}},
+		{`{SpawnY:64.0d}`, Level{SpawnY: 64}},
+		{`{Count:255}`, Level{Count: 255}},
+		{`{Seed:9223372036854775807L}`, Level{Seed: 1<<63 - 1}},
+		{`{Scale:1b}`, Level{Scale: 1}},
+		{`{Scale:0.1d}`, Level{Scale: 0.1}},
+		{`{BorderSize:60000000}`, Level{BorderSize: 60000000}},
+		{`{BorderSize:1.5f}`, Level{BorderSize: 1.5}},
+		{`{Small:-128L}`, Level{Small: -128}},
+		{`{Any:1.5f}`, Level{Any: float32(1.5)}},
+		{`{Any:1.5d}`, Level{Any: 1.5}},
+		{`{Any:3s}`, Level{Any: int16(3)}},
+	}
+	for _, tt := range tests {
+		var out Level
+		if err := decodeSNBT(t, tt.snbt, &out); err != nil {
+			t.Errorf("decoding %s: %v", tt.snbt, err)
+		} else if !reflect.DeepEqual(out, tt.want) {
+			t.Errorf("decoding %s: got %+v, want %+v", tt.snbt, out, tt.want)
+		}
+	}
+
+	for _, snbt := range []string{
+		`{Count:-1s}`,
+		`{Count:256}`,
+		`{Seed:-1}`,
+		`{Small:128}`,
+		`{SpawnY:64.5d}`,
+		`{Small:1e10d}`,
+		`{Scale:1e300d}`,
+		`{Scale:16777217}`,
+		`{BorderSize:9007199254740993L}`,
+		// negative values overflow unless SetReinterpretUnsigned is set
+		`{Count:-56b}`,
+		`{Seed:-1L}`,
+	} {
+		var out Level
+		if err := decodeSNBT(t, snbt, &out); err == nil {
+			t.Errorf("decoding %s succeeded: %+v", snbt, out)
+		}
+	}
+
+	// with SetReinterpretUnsigned, a negative tag of the width the encoder
+	// writes an unsigned type as is read back as the same bits
+	for _, tt := range []struct {
+		snbt string
+		want Level
+		ok   bool
+	}{
+		{`{Count:-56b}`, Level{Count: 200}, true},
+		{`{Seed:-1L}`, Level{Seed: 1<<64 - 1}, true},
+		{`{Count:-1s}`, Level{}, false},
+		{`{Seed:-1}`, Level{}, false},
+	} {
+		tag, err := ParseSNBTTag(tt.snbt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var buf bytes.Buffer
+		if err := NewEncoder(&buf).Encode("", tag); err != nil {
+			t.Fatal(err)
+		}
+		d := NewDecoder(&buf)
+		d.SetReinterpretUnsigned(true)
+		var out Level
+		_, err = d.Decode(&out)
+		if tt.ok && (err != nil || out != tt.want) {
+			t.Errorf("reinterpreting %s: got %+v, %v, want %+v", tt.snbt, out, err, tt.want)
+		} else if !tt.ok && err == nil {
+			t.Errorf("reinterpreting %s succeeded: %+v", tt.snbt, out)
+		}
+	}
+}
+
+func TestUnsignedRoundTrip(t *testing.T) {
+	type Unsigned struct {
+		U8  uint8  `nbt:"u8"`
+		U16 uint16 `nbt:"u16"`
+		U32 uint32 `nbt:"u32"`
+		U   uint   `nbt:"u"`
+		U64 uint64 `nbt:"u64"`
+	}
+	for _, in := range []Unsigned{
+		{math.MaxUint8, math.MaxUint16, math.MaxUint32, math.MaxUint32, math.MaxUint64},
+		{1 << 7, 1 << 15, 1 << 31, 1 << 31, 1 << 63},
+		{1<<7 - 1, 1<<15 - 1, 1<<31 - 1, 1<<31 - 1, 1<<63 - 1},
+	} {
+		var buf bytes.Buffer
+		if err := NewEncoder(&buf).Encode("", in); err != nil {
+			t.Fatalf("encoding %+v: %v", in, err)
+		}
+		d := NewDecoder(&buf)
+		d.SetReinterpretUnsigned(true)
+		var out Unsigned
+		if _, err := d.Decode(&out); err != nil {
+			t.Errorf("decoding %+v: %v", in, err)
+		} else if out != in {
+			t.Errorf("%+v decoded as %+v", in, out)
+		}
+	}
+}
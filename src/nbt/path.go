@@ -0,0 +1,497 @@
+package nbt
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"strconv"
+)
+
+// path.go
+// NBT paths, as used by the game's /data command, eg. Data.Player.Inventory,
+// Items[{id:"minecraft:diamond"}].count or sections[-1].block_states
+
+var ErrPathNotFound = errors.New("nbt: no tags match path")
+
+// Path selects tags in a generic tree (see Tag). It is made up of nodes:
+//
+//	{a:1b}      at the start of a path, matches the root if it is a compound
+//	            containing the given entries
+//	name        the entry called name in a compound (may be quoted)
+//	name{a:1b}  the entry called name, if it contains the given entries
+//	[2]         the element at an index of a list or array. Negative indexes
+//	            count back from the end
+//	[]          every element of a list or array
+//	[{a:1b}]    every element of a list of compounds containing the given
+//	            entries
+//
+// Nodes are separated by '.', which may be omitted before a '['.
+type Path struct {
+	src   string
+	nodes []pathNode
+}
+
+type pathNodeKind byte
+
+const (
+	nodeRoot pathNodeKind = iota
+	nodeName
+	nodeIndex
+	nodeAll
+	nodeFilter
+)
+
+type pathNode struct {
+	kind   pathNodeKind
+	name   string
+	index  int
+	filter *Compound // nil for a plain name
+}
+
+// ParsePath parses an NBT path. Syntax errors are reported as a wrapped
+// *SNBTSyntaxError.
+func ParsePath(s string) (Path, error) {
+	p := snbtParser{s: s}
+	var nodes []pathNode
+
+	for p.pos < len(s) {
+		node, err := p.parsePathNode(len(nodes) == 0)
+		if err != nil {
+			return Path{}, fmt.Errorf("nbt: invalid path %q: %w", s, err)
+		}
+		nodes = append(nodes, node)
+
+		if p.pos < len(s) {
+			switch s[p.pos] {
+			case '[':
+			case '.':
+				p.pos++
+				if p.pos == len(s) {
+					return Path{}, fmt.Errorf("nbt: invalid path %q: %w", s, p.errorf("expected name after '.'"))
+				}
+			default:
+				return Path{}, fmt.Errorf("nbt: invalid path %q: %w", s, p.errorf("unexpected character %q", s[p.pos]))
+			}
+		}
+	}
+	if len(nodes) == 0 {
+		return Path{}, errors.New("nbt: empty path")
+	}
+	return Path{src: s, nodes: nodes}, nil
+}
+
+func isPathNameChar(c byte) bool {
+	switch c {
+	case ' ', '"', '\'', '[', ']', '.', '{', '}':
+		return false
+	}
+	return true
+}
+
+func (p *snbtParser) parsePathNode(first bool) (pathNode, error) {
+	switch p.s[p.pos] {
+	case '{':
+		if !first {
+			return pathNode{}, p.errorf("compound filter must follow a name or start the path")
+		}
+		filter, err := p.parseCompound()
+		return pathNode{kind: nodeRoot, filter: filter}, err
+	case '[':
+		p.pos++
+		if p.peek() == ']' {
+			p.pos++
+			return pathNode{kind: nodeAll}, nil
+		}
+		if p.peek() == '{' {
+			filter, err := p.parseCompound()
+			if err != nil {
+				return pathNode{}, err
+			}
+			return pathNode{kind: nodeFilter, filter: filter}, p.expect(']')
+		}
+
+		start := p.pos
+		if p.pos < len(p.s) && p.s[p.pos] == '-' {
+			p.pos++
+		}
+		for p.pos < len(p.s) && p.s[p.pos] >= '0' && p.s[p.pos] <= '9' {
+			p.pos++
+		}
+		index, err := strconv.Atoi(p.s[start:p.pos])
+		if err != nil {
+			p.pos = start
+			return pathNode{}, p.errorf("expected index, compound filter or ']'")
+		}
+		return pathNode{kind: nodeIndex, index: index}, p.expect(']')
+	}
+
+	var name string
+	if c := p.s[p.pos]; c == '"' || c == '\'' {
+		var err error
+		if name, err = p.parseQuoted(); err != nil {
+			return pathNode{}, err
+		}
+	} else {
+		start := p.pos
+		for p.pos < len(p.s) && isPathNameChar(p.s[p.pos]) {
+			p.pos++
+		}
+		if p.pos == start {
+			return pathNode{}, p.errorf("unexpected character %q", p.s[p.pos])
+		}
+		name = p.s[start:p.pos]
+	}
+
+	node := pathNode{kind: nodeName, name: name}
+	if p.pos < len(p.s) && p.s[p.pos] == '{' {
+		filter, err := p.parseCompound()
+		if err != nil {
+			return pathNode{}, err
+		}
+		node.filter = filter
+	}
+	return node, nil
+}
+
+func (p Path) String() string {
+	return p.src
+}
+
+// Get returns every tag in root matched by the path, or ErrPathNotFound if
+// there are none.
+func (p Path) Get(root Tag) ([]Tag, error) {
+	matches := p.walk(root, len(p.nodes), false)
+	if len(matches) == 0 {
+		return nil, p.notFound()
+	}
+	tags := make([]Tag, len(matches))
+	for i, m := range matches {
+		tags[i] = m.tag
+	}
+	return tags, nil
+}
+
+// Set replaces every tag in root matched by the path with a copy of value,
+// and returns how many were replaced. Like the game, missing compound entries
+// along the way are created, as are missing elements of [{filter}] nodes.
+func (p Path) Set(root Tag, value Tag) (int, error) {
+	last := p.nodes[len(p.nodes)-1]
+	if last.kind == nodeRoot {
+		return 0, errors.New("nbt: can't replace the root tag")
+	}
+
+	count := 0
+	for _, m := range p.walk(root, len(p.nodes)-1, true) {
+		if last.kind == nodeName && last.filter == nil {
+			if c, ok := m.tag.(*Compound); ok {
+				c.Set(last.name, CloneTag(value))
+				count++
+			}
+			continue
+		}
+		for _, target := range last.appendMatches(nil, m, false, nil) {
+			if err := target.set(CloneTag(value)); err != nil {
+				return count, fmt.Errorf("nbt: failed to set %s: %w", p.src, err)
+			}
+			count++
+		}
+	}
+	if count == 0 {
+		return 0, p.notFound()
+	}
+	return count, nil
+}
+
+// Remove deletes every tag in root matched by the path, and returns how many
+// were removed.
+func (p Path) Remove(root Tag) (int, error) {
+	last := p.nodes[len(p.nodes)-1]
+	if last.kind == nodeRoot {
+		return 0, errors.New("nbt: can't remove the root tag")
+	}
+
+	count := 0
+	for _, m := range p.walk(root, len(p.nodes)-1, false) {
+		switch last.kind {
+		case nodeName:
+			c, ok := m.tag.(*Compound)
+			if !ok {
+				continue
+			}
+			if child, ok := c.Get(last.name); ok && (last.filter == nil || tagMatches(child, last.filter)) {
+				c.Delete(last.name)
+				count++
+			}
+		default:
+			n, err := last.removeElems(m)
+			if err != nil {
+				return count, fmt.Errorf("nbt: failed to remove %s: %w", p.src, err)
+			}
+			count += n
+		}
+	}
+	if count == 0 {
+		return 0, p.notFound()
+	}
+	return count, nil
+}
+
+func (p Path) notFound() error {
+	return fmt.Errorf("%w: %s", ErrPathNotFound, p.src)
+}
+
+// pathMatch is a tag selected by a path, and a way to replace it in its parent
+type pathMatch struct {
+	tag Tag
+	set func(Tag) error // nil for the root
+}
+
+// walk applies the first n nodes of the path to root. If create is set,
+// missing compound entries are created as they would be by Set.
+func (p Path) walk(root Tag, n int, create bool) []pathMatch {
+	matches := []pathMatch{{tag: root}}
+	for i, node := range p.nodes[:n] {
+		var next *pathNode
+		if i+1 < len(p.nodes) {
+			next = &p.nodes[i+1]
+		}
+
+		var out []pathMatch
+		for _, m := range matches {
+			out = node.appendMatches(out, m, create, next)
+		}
+		matches = out
+		if len(matches) == 0 {
+			break
+		}
+	}
+	return matches
+}
+
+// appendMatches appends the children of m selected by the node to out. next
+// is the node that follows, which decides what kind of tag to create for a
+// missing entry.
+func (n *pathNode) appendMatches(out []pathMatch, m pathMatch, create bool, next *pathNode) []pathMatch {
+	switch n.kind {
+	case nodeRoot:
+		if tagMatches(m.tag, n.filter) {
+			out = append(out, m)
+		}
+	case nodeName:
+		c, ok := m.tag.(*Compound)
+		if !ok {
+			return out
+		}
+		child, ok := c.Get(n.name)
+		if !ok {
+			if !create {
+				return out
+			}
+			switch {
+			case n.filter != nil:
+				child = CloneTag(n.filter)
+			case next != nil && next.kind != nodeName && next.kind != nodeRoot:
+				child = &List{}
+			default:
+				child = &Compound{}
+			}
+			c.Set(n.name, child)
+		}
+		if n.filter != nil && !tagMatches(child, n.filter) {
+			return out
+		}
+		name := n.name
+		out = append(out, pathMatch{tag: child, set: func(t Tag) error {
+			c.Set(name, t)
+			return nil
+		}})
+	case nodeIndex:
+		count := elemCount(m.tag)
+		i := n.index
+		if i < 0 {
+			i += count
+		}
+		if i >= 0 && i < count {
+			out = append(out, elemAt(m, i))
+		}
+	case nodeAll:
+		for i := range elemCount(m.tag) {
+			out = append(out, elemAt(m, i))
+		}
+	case nodeFilter:
+		l, ok := m.tag.(*List)
+		if !ok {
+			return out
+		}
+		found := false
+		for i, elem := range l.Elems {
+			if tagMatches(elem, n.filter) {
+				out = append(out, elemAt(m, i))
+				found = true
+			}
+		}
+		if !found && create && (len(l.Elems) == 0 || l.ElemType == TAG_Compound) {
+			l.Append(CloneTag(n.filter))
+			out = append(out, elemAt(m, len(l.Elems)-1))
+		}
+	}
+	return out
+}
+
+// removeElems removes the elements of the list or array m selected by the
+// node, and returns how many were removed
+func (n *pathNode) removeElems(m pathMatch) (int, error) {
+	count := elemCount(m.tag)
+	var remove func(i int) bool
+	switch n.kind {
+	case nodeIndex:
+		index := n.index
+		if index < 0 {
+			index += count
+		}
+		remove = func(i int) bool { return i == index }
+	case nodeAll:
+		remove = func(int) bool { return true }
+	case nodeFilter:
+		l, ok := m.tag.(*List)
+		if !ok {
+			return 0, nil
+		}
+		remove = func(i int) bool { return tagMatches(l.Elems[i], n.filter) }
+	}
+
+	var removed int
+	switch t := m.tag.(type) {
+	case *List:
+		t.Elems, removed = deleteElems(t.Elems, remove)
+		return removed, nil
+	case ByteArray:
+		t, removed = deleteElems(t, remove)
+		return removed, replaceArray(m, t, removed)
+	case IntArray:
+		t, removed = deleteElems(t, remove)
+		return removed, replaceArray(m, t, removed)
+	case LongArray:
+		t, removed = deleteElems(t, remove)
+		return removed, replaceArray(m, t, removed)
+	}
+	return 0, nil
+}
+
+// replaceArray stores an array that has had elements removed back in its
+// parent
+func replaceArray(m pathMatch, arr Tag, removed int) error {
+	if removed == 0 {
+		return nil
+	}
+	if m.set == nil {
+		return errors.New("can't resize the root tag")
+	}
+	return m.set(arr)
+}
+
+func deleteElems[S ~[]E, E any](s S, remove func(i int) bool) (S, int) {
+	out := make(S, 0, len(s))
+	for i, e := range s {
+		if !remove(i) {
+			out = append(out, e)
+		}
+	}
+	return out, len(s) - len(out)
+}
+
+// elemCount returns the number of elements in a list or array
+func elemCount(tag Tag) int {
+	switch t := tag.(type) {
+	case *List:
+		return len(t.Elems)
+	case ByteArray:
+		return len(t)
+	case IntArray:
+		return len(t)
+	case LongArray:
+		return len(t)
+	}
+	return 0
+}
+
+// elemAt returns element i of the list or array m. Array elements are
+// returned as Byte, Int or Long.
+func elemAt(m pathMatch, i int) pathMatch {
+	switch t := m.tag.(type) {
+	case *List:
+		return pathMatch{tag: t.Elems[i], set: func(v Tag) error {
+			if v.TagType() != t.ElemType && len(t.Elems) > 1 {
+				return fmt.Errorf("can't insert %s into TAG_List of %s", TagName(v.TagType()), TagName(t.ElemType))
+			}
+			t.ElemType = v.TagType()
+			t.Elems[i] = v
+			return nil
+		}}
+	case ByteArray:
+		return pathMatch{tag: Byte(t[i]), set: func(v Tag) error {
+			b, ok := v.(Byte)
+			if !ok {
+				return fmt.Errorf("can't insert %s into TAG_Byte_Array", TagName(v.TagType()))
+			}
+			t[i] = byte(b)
+			return nil
+		}}
+	case IntArray:
+		return pathMatch{tag: Int(t[i]), set: func(v Tag) error {
+			n, ok := v.(Int)
+			if !ok {
+				return fmt.Errorf("can't insert %s into TAG_Int_Array", TagName(v.TagType()))
+			}
+			t[i] = int32(n)
+			return nil
+		}}
+	case LongArray:
+		return pathMatch{tag: Long(t[i]), set: func(v Tag) error {
+			n, ok := v.(Long)
+			if !ok {
+				return fmt.Errorf("can't insert %s into TAG_Long_Array", TagName(v.TagType()))
+			}
+			t[i] = int64(n)
+			return nil
+		}}
+	}
+	panic("nbt: elemAt called on " + TagName(m.tag.TagType()))
+}
+
+// tagMatches reports whether tag matches filter, following the game's rules:
+// compounds match if every entry in the filter matches, lists match if every
+// element in the filter matches some element of the list, and everything else
+// must be equal.
+func tagMatches(tag, filter Tag) bool {
+	switch f := filter.(type) {
+	case *Compound:
+		c, ok := tag.(*Compound)
+		if !ok {
+			return false
+		}
+		for _, e := range f.Entries {
+			v, ok := c.Get(e.Name)
+			if !ok || !tagMatches(v, e.Value) {
+				return false
+			}
+		}
+		return true
+	case *List:
+		l, ok := tag.(*List)
+		if !ok {
+			return false
+		}
+		if len(f.Elems) == 0 {
+			return len(l.Elems) == 0
+		}
+		for _, fe := range f.Elems {
+			if !slices.ContainsFunc(l.Elems, func(e Tag) bool { return tagMatches(e, fe) }) {
+				return false
+			}
+		}
+		return true
+	}
+	return reflect.DeepEqual(tag, filter)
+}
@@ -0,0 +1,238 @@
+package nbt
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+const pathFixture = `{Data: {Player: {Inventory: [{id: "minecraft:diamond", count: 3b, Slot: 0b}, {id: "minecraft:dirt", count: 64b, Slot: 1b}]}}, arr: [I; 1, 2, 3], "odd key": {x: 1}}`
+
+// getPath formats every tag matched by path, or returns nil if there are none.
+func getPath(t *testing.T, root Tag, path string) []string {
+	t.Helper()
+	p, err := ParsePath(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tags, err := p.Get(root)
+	if errors.Is(err, ErrPathNotFound) {
+		return nil
+	} else if err != nil {
+		t.Fatalf("Get(%s): %v", path, err)
+	}
+	var out []string
+	for _, tag := range tags {
+		s, err := FormatSNBT(tag, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		out = append(out, s)
+	}
+	return out
+}
+
+func TestParsePath(t *testing.T) {
+	for _, s := range []string{
+		`Data`,
+		`Data.Player.Inventory`,
+		`Items[0]`,
+		`Items[-1].id`,
+		`Items[]`,
+		`Items[{id: "minecraft:dirt"}].count`,
+		`Items.[0]`,
+		`{DataVersion: 3465}.Level`,
+		`Data.Player{Dimension: "minecraft:overworld"}.Pos[1]`,
+		`"odd key".'x.y'`,
+		`a[0][1][]`,
+	} {
+		p, err := ParsePath(s)
+		if err != nil {
+			t.Errorf("ParsePath(%s): %v", s, err)
+		} else if p.String() != s {
+			t.Errorf("ParsePath(%s).String() = %s", s, p.String())
+		}
+	}
+
+	tests := []struct {
+		in     string
+		offset int
+	}{
+		{`a..b`, 2},
+		{`a.`, 2},
+		{`[`, 1},
+		{`a[x]`, 2},
+		{`a[0`, 3},
+		{`a[{b: 1}`, 8},
+		{`a{b:}`, 4},
+		{`{a: 1}{b: 2}`, 6},
+		{`a]`, 1},
+		{`"unterminated`, 13},
+	}
+	for _, tt := range tests {
+		_, err := ParsePath(tt.in)
+		var serr *SNBTSyntaxError
+		if !errors.As(err, &serr) {
+			t.Errorf("ParsePath(%s): got %v, want a *SNBTSyntaxError", tt.in, err)
+		} else if serr.Offset != tt.offset {
+			t.Errorf("ParsePath(%s): %v, want offset %d", tt.in, err, tt.offset)
+		}
+	}
+	if _, err := ParsePath(""); err == nil {
+		t.Errorf("parsed an empty path")
+	}
+}
+
+func TestPathGet(t *testing.T) {
+	root, err := ParseSNBTTag(pathFixture)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		path string
+		want []string
+	}{
+		{`Data.Player.Inventory[0].id`, []string{`"minecraft:diamond"`}},
+		{`Data.Player.Inventory[-1].Slot`, []string{`1b`}},
+		{`Data.Player.Inventory[].count`, []string{`3b`, `64b`}},
+		{`Data.Player.Inventory[{id: "minecraft:dirt"}].count`, []string{`64b`}},
+		{`Data.Player.Inventory[{}].Slot`, []string{`0b`, `1b`}},
+		{`Data.Player.Inventory[0]`, []string{`{id: "minecraft:diamond", count: 3b, Slot: 0b}`}},
+		{`arr[-1]`, []string{`3`}},
+		{`arr[]`, []string{`1`, `2`, `3`}},
+		{`"odd key".x`, []string{`1`}},
+		{`'odd key'{x: 1}`, []string{`{x: 1}`}},
+		{`{arr: [I; 1, 2, 3]}.Data.Player.Inventory[1].Slot`, []string{`1b`}},
+		// a list in a filter matches if each of its elements matches one in the
+		// list
+		{`Data.Player{Inventory: [{Slot: 1b}]}.Inventory[0].Slot`, []string{`0b`}},
+
+		{`Data.missing`, nil},
+		{`Data.Player.Inventory[2]`, nil},
+		{`arr[-4]`, nil},
+		{`arr.x`, nil},
+		{`Data[0]`, nil},
+		{`Data.Player.Inventory[{count: 1b}]`, nil},
+		{`Data.Player{Inventory: [{Slot: 5b}]}`, nil},
+		{`{arr: [I; 1]}.Data`, nil},
+	}
+	for _, tt := range tests {
+		if got := getPath(t, root, tt.path); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Get(%s) = %q, want %q", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestPathSet(t *testing.T) {
+	tests := []struct {
+		path  string
+		value string
+		count int
+		// the tags matched by check after setting the value
+		check string
+		want  []string
+	}{
+		{`Data.Player.Inventory[].count`, `1b`, 2, `Data.Player.Inventory[].count`, []string{`1b`, `1b`}},
+		{`Data.Player.Inventory[{id: "minecraft:dirt"}].count`, `9b`, 1, `Data.Player.Inventory[].count`, []string{`3b`, `9b`}},
+		// missing elements of filters are created, as are compound entries
+		{`Data.Player.Inventory[{id: "minecraft:stone"}].count`, `9b`, 1, `Data.Player.Inventory[2]`, []string{`{id: "minecraft:stone", count: 9b}`}},
+		{`new.sub.x`, `1`, 1, `new`, []string{`{sub: {x: 1}}`}},
+		{`arr[0]`, `42`, 1, `arr`, []string{`[I; 42, 2, 3]`}},
+		{`arr[]`, `0`, 3, `arr`, []string{`[I; 0, 0, 0]`}},
+		{`"odd key"`, `"s"`, 1, `"odd key"`, []string{`"s"`}},
+		{`Data.Player{Inventory: []}.x`, `1`, 0, `Data.Player.x`, nil},
+	}
+	for _, tt := range tests {
+		root, _ := ParseSNBTTag(pathFixture)
+		value, err := ParseSNBTTag(tt.value)
+		if err != nil {
+			t.Fatal(err)
+		}
+		p, err := ParsePath(tt.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		n, err := p.Set(root, value)
+		if tt.count == 0 {
+			if !errors.Is(err, ErrPathNotFound) {
+				t.Errorf("Set(%s): got %d, %v, want ErrPathNotFound", tt.path, n, err)
+			}
+		} else if n != tt.count || err != nil {
+			t.Errorf("Set(%s) = %d, %v, want %d", tt.path, n, err, tt.count)
+		}
+		if got := getPath(t, root, tt.check); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("after Set(%s), %s = %q, want %q", tt.path, tt.check, got, tt.want)
+		}
+	}
+
+	// values are copied, not shared between the tags they replace
+	root, _ := ParseSNBTTag(pathFixture)
+	p, _ := ParsePath(`Data.Player.Inventory[].tag`)
+	value := &Compound{}
+	if _, err := p.Set(root, value); err != nil {
+		t.Fatal(err)
+	}
+	value.Set("x", Byte(1))
+	if got := getPath(t, root, `Data.Player.Inventory[].tag`); !reflect.DeepEqual(got, []string{`{}`, `{}`}) {
+		t.Errorf("changing the value set changed the tree: %q", got)
+	}
+
+	for path, value := range map[string]Tag{
+		`{}`:                       &Compound{},
+		`arr[0]`:                   Long(1),
+		`Data.Player.Inventory[0]`: Int(1),
+	} {
+		root, _ := ParseSNBTTag(pathFixture)
+		p, err := ParsePath(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n, err := p.Set(root, value); err == nil || errors.Is(err, ErrPathNotFound) {
+			t.Errorf("Set(%s, %v) = %d, %v, want an error", path, value, n, err)
+		}
+	}
+}
+
+func TestPathRemove(t *testing.T) {
+	tests := []struct {
+		path  string
+		count int
+		check string
+		want  []string
+	}{
+		{`Data.Player.Inventory[{count: 64b}]`, 1, `Data.Player.Inventory[].id`, []string{`"minecraft:diamond"`}},
+		{`Data.Player.Inventory[].Slot`, 2, `Data.Player.Inventory[0]`, []string{`{id: "minecraft:diamond", count: 3b}`}},
+		{`Data.Player.Inventory[-1]`, 1, `Data.Player.Inventory[].Slot`, []string{`0b`}},
+		{`Data.Player.Inventory[]`, 2, `Data.Player.Inventory`, []string{`[]`}},
+		{`arr[1]`, 1, `arr`, []string{`[I; 1, 3]`}},
+		{`arr[]`, 3, `arr`, []string{`[I;]`}},
+		{`"odd key"{x: 1}`, 1, `"odd key"`, nil},
+		{`"odd key"{x: 2}`, 0, `"odd key".x`, []string{`1`}},
+		{`Data.missing`, 0, `Data`, []string{`{Player: {Inventory: [{id: "minecraft:diamond", count: 3b, Slot: 0b}, {id: "minecraft:dirt", count: 64b, Slot: 1b}]}}`}},
+		{`arr[3]`, 0, `arr`, []string{`[I; 1, 2, 3]`}},
+	}
+	for _, tt := range tests {
+		root, _ := ParseSNBTTag(pathFixture)
+		p, err := ParsePath(tt.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		n, err := p.Remove(root)
+		if tt.count == 0 {
+			if !errors.Is(err, ErrPathNotFound) {
+				t.Errorf("Remove(%s): got %d, %v, want ErrPathNotFound", tt.path, n, err)
+			}
+		} else if n != tt.count || err != nil {
+			t.Errorf("Remove(%s) = %d, %v, want %d", tt.path, n, err, tt.count)
+		}
+		if got := getPath(t, root, tt.check); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("after Remove(%s), %s = %q, want %q", tt.path, tt.check, got, tt.want)
+		}
+	}
+
+	root, _ := ParseSNBTTag(pathFixture)
+	p, _ := ParsePath(`{}`)
+	if _, err := p.Remove(root); err == nil {
+		t.Errorf("removed the root tag")
+	}
+}
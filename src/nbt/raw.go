@@ -0,0 +1,76 @@
+package nbt
+
+import (
+	"bytes"
+	"errors"
+	"io"
+)
+
+// raw.go
+// deferred decoding of individual tags
+
+// RawMessage holds the encoded form of a single tag: its type byte followed by
+// its payload, without a name. It can be used as a struct field to delay
+// decoding part of a structure, or to pass it through unchanged: NBTDecoder
+// captures the exact bytes of the tag, and NBTEncoder writes them back
+// verbatim.
+//
+// The bytes are in whichever Variant the decoder was reading, so a
+// RawMessage should only be re-encoded with the same Variant. An empty
+// RawMessage holds no tag, and is left out of the enclosing compound.
+type RawMessage []byte
+
+// TagType returns the type of the captured tag, or TAG_End if m is empty.
+func (m RawMessage) TagType() byte {
+	if len(m) == 0 {
+		return TAG_End
+	}
+	return m[0]
+}
+
+// Payload returns the captured tag's payload, without its type byte.
+func (m RawMessage) Payload() []byte {
+	if len(m) == 0 {
+		return nil
+	}
+	return m[1:]
+}
+
+// MarshalNBT writes the captured payload verbatim.
+func (m RawMessage) MarshalNBT(w io.Writer) error {
+	if len(m) == 0 {
+		return errors.New("nbt: can't marshal empty RawMessage")
+	}
+	_, err := w.Write(m[1:])
+	return err
+}
+
+// UnmarshalNBT captures a Java Edition tag from r. When a RawMessage is
+// decoded as part of a larger value, NBTDecoder captures it directly instead,
+// respecting the decoder's Variant and Limits.
+func (m *RawMessage) UnmarshalNBT(tagType byte, r NBTReader) error {
+	return NewDecoder(r).readRaw(m, tagType)
+}
+
+// Unmarshal decodes the captured Java Edition tag into v. For other variants,
+// use a decoder with SetNamelessRoot(true) reading from bytes.NewReader(m).
+func (m RawMessage) Unmarshal(v any) error {
+	if len(m) == 0 {
+		return errors.New("nbt: can't unmarshal empty RawMessage")
+	}
+	d := NewDecoder(bytes.NewReader(m))
+	d.SetNamelessRoot(true)
+	_, err := d.Decode(v)
+	return err
+}
+
+// readRaw reads a tag of type tagType, copying its encoded form into m.
+func (d *NBTDecoder) readRaw(m *RawMessage, tagType byte) error {
+	if d.r.capture != nil {
+		return errors.New("nbt: nested RawMessage capture")
+	}
+	d.r.capture = append((*m)[:0], tagType)
+	err := d.ReadAndDiscardTag(tagType)
+	*m, d.r.capture = d.r.capture, nil
+	return err
+}
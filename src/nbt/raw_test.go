@@ -0,0 +1,169 @@
+package nbt
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"reflect"
+	"testing"
+)
+
+func TestRawMessage(t *testing.T) {
+	tests := []struct {
+		snbt string
+		want RawMessage
+	}{
+		{`1b`, RawMessage{TAG_Byte, 1}},
+		{`-1`, RawMessage{TAG_Int, 0xff, 0xff, 0xff, 0xff}},
+		{`"hi"`, RawMessage{TAG_String, 0, 2, 'h', 'i'}},
+		{`[I; 1]`, RawMessage{TAG_Int_Array, 0, 0, 0, 1, 0, 0, 0, 1}},
+		{`[]`, RawMessage{TAG_List, TAG_End, 0, 0, 0, 0}},
+		{`[{}, {}]`, RawMessage{TAG_List, TAG_Compound, 0, 0, 0, 2, TAG_End, TAG_End}},
+		{`{a: 1b}`, RawMessage{TAG_Compound, TAG_Byte, 0, 1, 'a', 1, TAG_End}},
+	}
+	for _, tt := range tests {
+		var v struct {
+			Before int8       `nbt:"before"`
+			Raw    RawMessage `nbt:"raw"`
+			After  int8       `nbt:"after"`
+		}
+		if err := decodeSNBT(t, `{before: 1b, raw: `+tt.snbt+`, after: 2b}`, &v); err != nil {
+			t.Errorf("%s: %v", tt.snbt, err)
+			continue
+		}
+		if !bytes.Equal(v.Raw, tt.want) {
+			t.Errorf("%s: captured %x, want %x", tt.snbt, []byte(v.Raw), []byte(tt.want))
+		}
+		if v.Before != 1 || v.After != 2 {
+			t.Errorf("%s: the surrounding fields were decoded as %d, %d", tt.snbt, v.Before, v.After)
+		}
+		if v.Raw.TagType() != tt.want[0] || !bytes.Equal(v.Raw.Payload(), tt.want[1:]) {
+			t.Errorf("%s: TagType %s, Payload %x", tt.snbt, TagName(v.Raw.TagType()), v.Raw.Payload())
+		}
+
+		// decoding on demand gives the same value as decoding directly
+		var got, want Tag
+		if err := v.Raw.Unmarshal(&got); err != nil {
+			t.Errorf("%s: Unmarshal: %v", tt.snbt, err)
+		}
+		want, _ = ParseSNBTTag(tt.snbt)
+		if !tagsEqual(got, want) {
+			t.Errorf("%s: Unmarshal gave %v", tt.snbt, got)
+		}
+	}
+}
+
+// a RawMessage is written back exactly as it was read, in every variant
+func TestRawMessagePassThrough(t *testing.T) {
+	type blockEntity struct {
+		ID    string         `nbt:"id"`
+		X     int32          `nbt:"x"`
+		Items []string       `nbt:"Items"`
+		Data  map[string]any `nbt:"data"`
+	}
+	type full struct {
+		DataVersion   int32         `nbt:"DataVersion"`
+		BlockEntities []blockEntity `nbt:"block_entities"`
+		Status        string        `nbt:"Status"`
+		Tags          []string      `nbt:"tags"`
+	}
+	type lazy struct {
+		DataVersion   int32        `nbt:"DataVersion"`
+		BlockEntities RawMessage   `nbt:"block_entities"`
+		Status        string       `nbt:"Status"`
+		Tags          []RawMessage `nbt:"tags"`
+	}
+	in := full{
+		DataVersion:   3465,
+		BlockEntities: []blockEntity{{ID: "minecraft:chest", X: -5, Items: []string{"a", "b"}, Data: map[string]any{"q": int8(1)}}},
+		Status:        "minecraft:full",
+		Tags:          []string{"x", "yy"},
+	}
+	for _, variant := range []Variant{JavaEdition, BedrockEdition, BedrockNetwork} {
+		var buf bytes.Buffer
+		e := NewEncoder(&buf)
+		e.SetVariant(variant)
+		if err := e.Encode("root", in); err != nil {
+			t.Fatal(err)
+		}
+
+		d := NewDecoder(bytes.NewReader(buf.Bytes()))
+		d.SetVariant(variant)
+		var l lazy
+		if _, err := d.Decode(&l); err != nil {
+			t.Fatalf("variant %d: %v", variant, err)
+		}
+		if len(l.Tags) != 2 || l.Tags[0].TagType() != TAG_String {
+			t.Errorf("variant %d: captured list elements %x", variant, l.Tags)
+		}
+
+		var out bytes.Buffer
+		e = NewEncoder(&out)
+		e.SetVariant(variant)
+		if err := e.Encode("root", l); err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(out.Bytes(), buf.Bytes()) {
+			t.Errorf("variant %d: re-encoded as %x, want %x", variant, out.Bytes(), buf.Bytes())
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := NewEncoder(&buf).Encode("", in); err != nil {
+		t.Fatal(err)
+	}
+	var l lazy
+	if _, err := NewDecoder(&buf).Decode(&l); err != nil {
+		t.Fatal(err)
+	}
+	var be []blockEntity
+	if err := l.BlockEntities.Unmarshal(&be); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(be, in.BlockEntities) {
+		t.Errorf("Unmarshal gave %+v, want %+v", be, in.BlockEntities)
+	}
+}
+
+// an empty RawMessage holds no tag, so it's left out like a nil pointer
+func TestRawMessageEmpty(t *testing.T) {
+	v := struct {
+		Before int8       `nbt:"before"`
+		Raw    RawMessage `nbt:"raw"`
+		Any    any        `nbt:"any"`
+		After  int8       `nbt:"after"`
+	}{Before: 1, Any: RawMessage{}, After: 2}
+	c := encodeTag(t, v)
+	if got := entryNames(c); !reflect.DeepEqual(got, []string{"before", "after"}) {
+		t.Errorf("encoded entries %q, want before and after", got)
+	}
+
+	m := map[string]RawMessage{"a": nil, "b": {TAG_Byte, 1}}
+	if got := entryNames(encodeTag(t, m)); !reflect.DeepEqual(got, []string{"b"}) {
+		t.Errorf("encoded map entries %q, want b", got)
+	}
+}
+
+func TestRawMessageErrors(t *testing.T) {
+	var empty RawMessage
+	if err := NewEncoder(io.Discard).Encode("", empty); err == nil {
+		t.Errorf("encoded an empty RawMessage")
+	}
+	if err := empty.Unmarshal(new(any)); err == nil {
+		t.Errorf("unmarshalled an empty RawMessage")
+	}
+
+	// captures are subject to the decoder's limits
+	var buf bytes.Buffer
+	if err := NewEncoder(&buf).Encode("", map[string]string{"raw": "too long"}); err != nil {
+		t.Fatal(err)
+	}
+	d := NewDecoder(&buf)
+	d.SetLimits(Limits{MaxStringLen: 4})
+	var v struct {
+		Raw RawMessage `nbt:"raw"`
+	}
+	if _, err := d.Decode(&v); !errors.Is(err, ErrMaxStringLen) {
+		t.Errorf("got %v, want ErrMaxStringLen", err)
+	}
+}
@@ -0,0 +1,169 @@
+package nbt
+
+import (
+	"bytes"
+	"io"
+)
+
+// reader.go
+// the decoder's input layer. Reading primitives through io.ReadFull with a
+// stack buffer makes the buffer escape, so every ReadInt32 allocated; instead
+// the decoder reads ahead into its own buffer and decodes straight out of it.
+
+const readBufSize = 16 << 10
+
+// bufReader buffers the decoder's underlying reader, keeps track of how many
+// bytes have been consumed, and optionally keeps a copy of them (see
+// RawMessage).
+type bufReader struct {
+	r        io.Reader
+	buf      []byte
+	pos, end int   // unread data is buf[pos:end]
+	err      error // error returned by r, reported once the buffer is drained
+
+	n       int64  // number of bytes consumed
+	capture []byte // non-nil while capturing
+}
+
+func newBufReader(r io.Reader) *bufReader {
+	size := readBufSize
+	if l, ok := r.(interface{ Len() int }); ok {
+		// eg. a *bytes.Reader holding a single small tag; the buffer grows in
+		// fill if more turns out to be needed
+		size = min(max(l.Len(), minReadBufSize), readBufSize)
+	}
+	return &bufReader{r: r, buf: make([]byte, size)}
+}
+
+// minReadBufSize is the smallest buffer newBufReader allocates.
+const minReadBufSize = 64
+
+// reset makes b read from data alone, which it never modifies.
+func (b *bufReader) reset(data []byte) {
+	*b = bufReader{buf: data, end: len(data), err: io.EOF}
+}
+
+// consumed records that p has been read by the decoder
+func (b *bufReader) consumed(p []byte) {
+	b.n += int64(len(p))
+	if b.capture != nil {
+		b.capture = append(b.capture, p...)
+	}
+}
+
+// fill moves any unread data to the start of the buffer, and reads at least
+// one more byte after it
+func (b *bufReader) fill() error {
+	if b.err != nil {
+		return b.err
+	}
+	if b.pos > 0 {
+		b.end = copy(b.buf, b.buf[b.pos:b.end])
+		b.pos = 0
+	}
+	if b.end == len(b.buf) && len(b.buf) < readBufSize {
+		grown := make([]byte, min(2*len(b.buf), readBufSize))
+		b.end = copy(grown, b.buf[:b.end])
+		b.buf = grown
+	}
+
+	// give up if the reader keeps returning no data and no error, like bufio
+	for i := 0; i < 100 && b.err == nil; i++ {
+		n, err := b.r.Read(b.buf[b.end:])
+		b.end += n
+		b.err = err
+		if n > 0 {
+			return nil
+		}
+	}
+	if b.err == nil {
+		return io.ErrNoProgress
+	}
+	return b.err
+}
+
+func (b *bufReader) ReadByte() (byte, error) {
+	if b.pos == b.end {
+		if err := b.fill(); err != nil {
+			return 0, err
+		}
+	}
+	c := b.buf[b.pos]
+	b.pos++
+	b.n++
+	if b.capture != nil {
+		b.capture = append(b.capture, c)
+	}
+	return c, nil
+}
+
+func (b *bufReader) Read(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	if b.pos == b.end {
+		if len(p) >= len(b.buf) {
+			// large reads go straight into p rather than through the buffer
+			if b.err != nil {
+				return 0, b.err
+			}
+			n, err := b.r.Read(p)
+			b.consumed(p[:n])
+			return n, err
+		}
+		if err := b.fill(); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, b.buf[b.pos:b.end])
+	b.pos += n
+	b.consumed(p[:n])
+	return n, nil
+}
+
+// next consumes the next n bytes (at most readBufSize) and returns them. The
+// result is only valid until the next read. Like io.ReadFull, it returns
+// io.EOF if no bytes could be read and io.ErrUnexpectedEOF if only some
+// could.
+func (b *bufReader) next(n int) ([]byte, error) {
+	for b.end-b.pos < n {
+		if err := b.fill(); err != nil {
+			if err == io.EOF && b.end > b.pos {
+				err = io.ErrUnexpectedEOF
+			}
+			return nil, err
+		}
+	}
+	p := b.buf[b.pos : b.pos+n]
+	b.pos += n
+	b.consumed(p)
+	return p, nil
+}
+
+// skip consumes and discards the next n bytes
+func (b *bufReader) skip(n int64) error {
+	for n > 0 {
+		if b.pos == b.end {
+			if err := b.fill(); err != nil {
+				if err == io.EOF {
+					err = io.ErrUnexpectedEOF
+				}
+				return err
+			}
+		}
+		k := b.end - b.pos
+		if int64(k) > n {
+			k = int(n)
+		}
+		b.consumed(b.buf[b.pos : b.pos+k])
+		b.pos += k
+		n -= int64(k)
+	}
+	return nil
+}
+
+// Buffered returns the data remaining in the decoder's buffer, which it has
+// read from the underlying reader but not decoded yet.
+func (d *NBTDecoder) Buffered() io.Reader {
+	return bytes.NewReader(d.r.buf[d.r.pos:d.r.end])
+}
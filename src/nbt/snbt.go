@@ -0,0 +1,539 @@
+package nbt
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// snbt.go
+// parsing and printing of stringified NBT, the textual format used by
+// commands like /data get and /give
+
+// SNBTSyntaxError describes a malformed SNBT string, and the byte offset in
+// the input where the problem was found.
+type SNBTSyntaxError struct {
+	msg    string
+	Offset int
+}
+
+func (e *SNBTSyntaxError) Error() string {
+	return fmt.Sprintf("snbt: %s at offset %d", e.msg, e.Offset)
+}
+
+// patterns used to classify unquoted values, following the game's own parser
+var (
+	snbtByte   = regexp.MustCompile(`^[-+]?(?:0|[1-9][0-9]*)[bB]$`)
+	snbtShort  = regexp.MustCompile(`^[-+]?(?:0|[1-9][0-9]*)[sS]$`)
+	snbtInt    = regexp.MustCompile(`^[-+]?(?:0|[1-9][0-9]*)$`)
+	snbtLong   = regexp.MustCompile(`^[-+]?(?:0|[1-9][0-9]*)[lL]$`)
+	snbtFloat  = regexp.MustCompile(`^[-+]?(?:[0-9]+[.]?|[0-9]*[.][0-9]+)(?:[eE][-+]?[0-9]+)?[fF]$`)
+	snbtDouble = regexp.MustCompile(`^[-+]?(?:[0-9]+[.]?|[0-9]*[.][0-9]+)(?:[eE][-+]?[0-9]+)?[dD]$`)
+	// doubles may omit the suffix, as long as they can't be mistaken for an int
+	snbtBareDouble = regexp.MustCompile(`^[-+]?(?:[0-9]+[.]|[0-9]*[.][0-9]+)(?:[eE][-+]?[0-9]+)?$`)
+)
+
+// ParseSNBT parses a single SNBT value. The result has the same shape as
+// decoding the equivalent binary tag into an `any` with NBTDecoder:
+// map[string]any for compounds, []any for lists, int8/int16/int32/int64,
+// float32/float64, string, []byte, []int32 and []int64.
+func ParseSNBT(s string) (any, error) {
+	tag, err := ParseSNBTTag(s)
+	if err != nil {
+		return nil, err
+	}
+	return ToAny(tag), nil
+}
+
+// ParseSNBTTag parses a single SNBT value into a generic tree, keeping the
+// order of compound entries.
+func ParseSNBTTag(s string) (Tag, error) {
+	p := snbtParser{s: s}
+	v, err := p.parseValue()
+	if err != nil {
+		return nil, err
+	}
+	p.skipWhitespace()
+	if p.pos != len(p.s) {
+		return nil, p.errorf("unexpected trailing data")
+	}
+	return v, nil
+}
+
+type snbtParser struct {
+	s   string
+	pos int
+}
+
+func (p *snbtParser) errorf(format string, args ...any) error {
+	return &SNBTSyntaxError{msg: fmt.Sprintf(format, args...), Offset: p.pos}
+}
+
+func (p *snbtParser) skipWhitespace() {
+	for p.pos < len(p.s) && strings.IndexByte(" \t\r\n", p.s[p.pos]) >= 0 {
+		p.pos++
+	}
+}
+
+// peek returns the next non-whitespace character, or 0 at the end of input
+func (p *snbtParser) peek() byte {
+	p.skipWhitespace()
+	if p.pos >= len(p.s) {
+		return 0
+	}
+	return p.s[p.pos]
+}
+
+func (p *snbtParser) expect(c byte) error {
+	if p.peek() != c {
+		if p.pos >= len(p.s) {
+			return p.errorf("expected %q but reached end of input", c)
+		}
+		return p.errorf("expected %q but found %q", c, p.s[p.pos])
+	}
+	p.pos++
+	return nil
+}
+
+func isUnquotedChar(c byte) bool {
+	return c >= '0' && c <= '9' ||
+		c >= 'a' && c <= 'z' ||
+		c >= 'A' && c <= 'Z' ||
+		c == '_' || c == '-' || c == '.' || c == '+'
+}
+
+func (p *snbtParser) parseValue() (Tag, error) {
+	switch p.peek() {
+	case 0:
+		return nil, p.errorf("expected value but reached end of input")
+	case '{':
+		return p.parseCompound()
+	case '[':
+		if p.pos+2 < len(p.s) && isUnquotedChar(p.s[p.pos+1]) && p.s[p.pos+2] == ';' {
+			return p.parseArray()
+		}
+		return p.parseList()
+	case '"', '\'':
+		str, err := p.parseQuoted()
+		return String(str), err
+	}
+
+	token := p.parseUnquoted()
+	if token == "" {
+		return nil, p.errorf("unexpected character %q", p.s[p.pos])
+	}
+	return parseSNBTPrimitive(token), nil
+}
+
+// parseSNBTPrimitive interprets an unquoted token as a number, a boolean or a
+// bare string. Numbers that are out of range for their type are treated as
+// strings, matching the game's behaviour.
+func parseSNBTPrimitive(token string) Tag {
+	trimSuffix := func(s string) string { return s[:len(s)-1] }
+
+	switch {
+	case snbtByte.MatchString(token):
+		if v, err := strconv.ParseInt(trimSuffix(token), 10, 8); err == nil {
+			return Byte(v)
+		}
+	case snbtShort.MatchString(token):
+		if v, err := strconv.ParseInt(trimSuffix(token), 10, 16); err == nil {
+			return Short(v)
+		}
+	case snbtInt.MatchString(token):
+		if v, err := strconv.ParseInt(token, 10, 32); err == nil {
+			return Int(v)
+		}
+	case snbtLong.MatchString(token):
+		if v, err := strconv.ParseInt(trimSuffix(token), 10, 64); err == nil {
+			return Long(v)
+		}
+	case snbtFloat.MatchString(token):
+		if v, err := strconv.ParseFloat(trimSuffix(token), 32); err == nil {
+			return Float(v)
+		}
+	case snbtDouble.MatchString(token):
+		if v, err := strconv.ParseFloat(trimSuffix(token), 64); err == nil {
+			return Double(v)
+		}
+	case snbtBareDouble.MatchString(token):
+		if v, err := strconv.ParseFloat(token, 64); err == nil {
+			return Double(v)
+		}
+	case token == "true":
+		return Byte(1)
+	case token == "false":
+		return Byte(0)
+	}
+	return String(token)
+}
+
+func (p *snbtParser) parseUnquoted() string {
+	start := p.pos
+	for p.pos < len(p.s) && isUnquotedChar(p.s[p.pos]) {
+		p.pos++
+	}
+	return p.s[start:p.pos]
+}
+
+func (p *snbtParser) parseQuoted() (string, error) {
+	quote := p.s[p.pos]
+	p.pos++
+
+	var sb strings.Builder
+	for p.pos < len(p.s) {
+		c := p.s[p.pos]
+		switch c {
+		case '\\':
+			if p.pos+1 >= len(p.s) {
+				return "", p.errorf("unterminated escape sequence")
+			}
+			next := p.s[p.pos+1]
+			if next != '\\' && next != quote {
+				return "", p.errorf("invalid escape sequence \\%c", next)
+			}
+			sb.WriteByte(next)
+			p.pos += 2
+		case quote:
+			p.pos++
+			return sb.String(), nil
+		default:
+			sb.WriteByte(c)
+			p.pos++
+		}
+	}
+	return "", p.errorf("unterminated string")
+}
+
+func (p *snbtParser) parseKey() (string, error) {
+	switch p.peek() {
+	case '"', '\'':
+		return p.parseQuoted()
+	}
+	key := p.parseUnquoted()
+	if key == "" {
+		return "", p.errorf("expected key")
+	}
+	return key, nil
+}
+
+func (p *snbtParser) parseCompound() (*Compound, error) {
+	if err := p.expect('{'); err != nil {
+		return nil, err
+	}
+
+	compound := &Compound{}
+	if p.peek() == '}' {
+		p.pos++
+		return compound, nil
+	}
+
+	for {
+		key, err := p.parseKey()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(':'); err != nil {
+			return nil, err
+		}
+		value, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		compound.Set(key, value)
+
+		if p.peek() == ',' {
+			p.pos++
+			continue
+		}
+		if err := p.expect('}'); err != nil {
+			return nil, err
+		}
+		return compound, nil
+	}
+}
+
+func (p *snbtParser) parseList() (*List, error) {
+	if err := p.expect('['); err != nil {
+		return nil, err
+	}
+
+	list := &List{}
+	if p.peek() == ']' {
+		p.pos++
+		return list, nil
+	}
+
+	for {
+		p.skipWhitespace()
+		start := p.pos
+		value, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+
+		// every element of a list must share the same tag type
+		if err := list.Append(value); err != nil {
+			p.pos = start
+			return nil, p.errorf("%s", err.Error())
+		}
+
+		if p.peek() == ',' {
+			p.pos++
+			continue
+		}
+		if err := p.expect(']'); err != nil {
+			return nil, err
+		}
+		return list, nil
+	}
+}
+
+// parseArray parses the typed array syntax: [B;...], [I;...] and [L;...]
+func (p *snbtParser) parseArray() (Tag, error) {
+	if err := p.expect('['); err != nil {
+		return nil, err
+	}
+	arrayType := p.s[p.pos]
+	p.pos += 2 // type character and ';'
+
+	var bits int
+	switch arrayType {
+	case 'B':
+		bits = 8
+	case 'I':
+		bits = 32
+	case 'L':
+		bits = 64
+	default:
+		p.pos -= 2
+		return nil, p.errorf("invalid array type %q", arrayType)
+	}
+
+	var values []int64
+	if p.peek() != ']' {
+		for {
+			p.skipWhitespace()
+			start := p.pos
+			value, err := p.parseValue()
+			if err != nil {
+				return nil, err
+			}
+
+			var n int64
+			switch v := value.(type) {
+			case Byte:
+				n = int64(v)
+			case Short:
+				n = int64(v)
+			case Int:
+				n = int64(v)
+			case Long:
+				n = int64(v)
+			default:
+				p.pos = start
+				return nil, p.errorf("can't insert %s into [%c;] array", TagName(value.TagType()), arrayType)
+			}
+			if n < -1<<(bits-1) || n > 1<<(bits-1)-1 {
+				p.pos = start
+				return nil, p.errorf("value %d overflows [%c;] array", n, arrayType)
+			}
+			values = append(values, n)
+
+			if p.peek() == ',' {
+				p.pos++
+				continue
+			}
+			break
+		}
+	}
+	if err := p.expect(']'); err != nil {
+		return nil, err
+	}
+
+	switch arrayType {
+	case 'B':
+		out := make(ByteArray, len(values))
+		for i, v := range values {
+			out[i] = byte(v)
+		}
+		return out, nil
+	case 'I':
+		out := make(IntArray, len(values))
+		for i, v := range values {
+			out[i] = int32(v)
+		}
+		return out, nil
+	default:
+		return LongArray(values), nil
+	}
+}
+
+// FormatSNBT renders a decoded NBT value as SNBT. v may be a generic tree
+// (see Tag), or the representation produced by decoding into an `any`, in
+// which case compound entries are sorted by name. If indent is empty the
+// output is written on a single line, otherwise nested compounds and lists are
+// placed on their own lines and indented by indent per level.
+func FormatSNBT(v any, indent string) (string, error) {
+	tag, err := FromAny(v)
+	if err != nil {
+		return "", fmt.Errorf("snbt: %w", err)
+	}
+
+	var sb strings.Builder
+	if err := writeSNBT(&sb, tag, indent, 0); err != nil {
+		return "", err
+	}
+	return sb.String(), nil
+}
+
+func writeSNBT(sb *strings.Builder, tag Tag, indent string, depth int) error {
+	switch v := tag.(type) {
+	case Byte:
+		fmt.Fprintf(sb, "%db", v)
+	case Short:
+		fmt.Fprintf(sb, "%ds", v)
+	case Int:
+		fmt.Fprintf(sb, "%d", v)
+	case Long:
+		fmt.Fprintf(sb, "%dL", v)
+	case Float:
+		if math.IsInf(float64(v), 0) || math.IsNaN(float64(v)) {
+			return fmt.Errorf("snbt: can't format %v", v)
+		}
+		sb.WriteString(strconv.FormatFloat(float64(v), 'g', -1, 32))
+		sb.WriteByte('f')
+	case Double:
+		if math.IsInf(float64(v), 0) || math.IsNaN(float64(v)) {
+			return fmt.Errorf("snbt: can't format %v", v)
+		}
+		sb.WriteString(strconv.FormatFloat(float64(v), 'g', -1, 64))
+		sb.WriteByte('d')
+	case String:
+		sb.WriteString(quoteSNBT(string(v)))
+	case ByteArray:
+		sb.WriteString("[B;")
+		if len(v) > 0 {
+			sb.WriteByte(' ')
+		}
+		for i, b := range v {
+			writeSNBTSeparator(sb, i)
+			fmt.Fprintf(sb, "%db", int8(b))
+		}
+		sb.WriteByte(']')
+	case IntArray:
+		sb.WriteString("[I;")
+		if len(v) > 0 {
+			sb.WriteByte(' ')
+		}
+		for i, n := range v {
+			writeSNBTSeparator(sb, i)
+			fmt.Fprintf(sb, "%d", n)
+		}
+		sb.WriteByte(']')
+	case LongArray:
+		sb.WriteString("[L;")
+		if len(v) > 0 {
+			sb.WriteByte(' ')
+		}
+		for i, n := range v {
+			writeSNBTSeparator(sb, i)
+			fmt.Fprintf(sb, "%dL", n)
+		}
+		sb.WriteByte(']')
+	case *List:
+		// only break lists across lines if they contain other containers
+		multiline := indent != "" && len(v.Elems) > 0 && (v.ElemType == TAG_List || v.ElemType == TAG_Compound)
+
+		sb.WriteByte('[')
+		for i, elem := range v.Elems {
+			if multiline {
+				if i > 0 {
+					sb.WriteByte(',')
+				}
+				writeSNBTNewline(sb, indent, depth+1)
+			} else {
+				writeSNBTSeparator(sb, i)
+			}
+			if err := writeSNBT(sb, elem, indent, depth+1); err != nil {
+				return err
+			}
+		}
+		if multiline {
+			writeSNBTNewline(sb, indent, depth)
+		}
+		sb.WriteByte(']')
+	case *Compound:
+		sb.WriteByte('{')
+		for i, entry := range v.Entries {
+			if indent != "" {
+				if i > 0 {
+					sb.WriteByte(',')
+				}
+				writeSNBTNewline(sb, indent, depth+1)
+			} else {
+				writeSNBTSeparator(sb, i)
+			}
+			sb.WriteString(quoteSNBTKey(entry.Name))
+			sb.WriteString(": ")
+			if err := writeSNBT(sb, entry.Value, indent, depth+1); err != nil {
+				return fmt.Errorf("%w (in field %q)", err, entry.Name)
+			}
+		}
+		if indent != "" && len(v.Entries) > 0 {
+			writeSNBTNewline(sb, indent, depth)
+		}
+		sb.WriteByte('}')
+	default:
+		return fmt.Errorf("snbt: can't format go type %T", tag)
+	}
+	return nil
+}
+
+func writeSNBTSeparator(sb *strings.Builder, i int) {
+	if i > 0 {
+		sb.WriteString(", ")
+	}
+}
+
+func writeSNBTNewline(sb *strings.Builder, indent string, depth int) {
+	sb.WriteByte('\n')
+	for i := 0; i < depth; i++ {
+		sb.WriteString(indent)
+	}
+}
+
+// quoteSNBT quotes a string value, preferring double quotes unless the string
+// contains double quotes but no single quotes.
+func quoteSNBT(s string) string {
+	quote := byte('"')
+	if strings.IndexByte(s, '"') >= 0 && strings.IndexByte(s, '\'') < 0 {
+		quote = '\''
+	}
+
+	var sb strings.Builder
+	sb.WriteByte(quote)
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' || s[i] == quote {
+			sb.WriteByte('\\')
+		}
+		sb.WriteByte(s[i])
+	}
+	sb.WriteByte(quote)
+	return sb.String()
+}
+
+// quoteSNBTKey leaves compound keys unquoted where the syntax allows it
+func quoteSNBTKey(k string) string {
+	if k == "" {
+		return quoteSNBT(k)
+	}
+	for i := 0; i < len(k); i++ {
+		if !isUnquotedChar(k[i]) {
+			return quoteSNBT(k)
+		}
+	}
+	return k
+}
@@ -0,0 +1,196 @@
+package nbt
+
+import (
+	"bytes"
+	"errors"
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestParseSNBT(t *testing.T) {
+	list := func(elemType byte, elems ...Tag) *List {
+		return &List{ElemType: elemType, Elems: elems}
+	}
+	tests := []struct {
+		in   string
+		want Tag
+	}{
+		{`1b`, Byte(1)},
+		{`-128B`, Byte(-128)},
+		{`128b`, String("128b")}, // out of range, so a string
+		{`32767s`, Short(32767)},
+		{`-7`, Int(-7)},
+		{`+7`, Int(7)},
+		{`2147483648`, String("2147483648")},
+		{`01`, String("01")},
+		{`5L`, Long(5)},
+		{`-9223372036854775808l`, Long(-1 << 63)},
+		{`1.5f`, Float(1.5)},
+		{`1e3F`, Float(1000)},
+		{`.5d`, Double(0.5)},
+		{`1.`, Double(1)},
+		{`1.5`, Double(1.5)},
+		{`-2.5e-3`, Double(-0.0025)},
+		{`true`, Byte(1)},
+		{`false`, Byte(0)},
+		{`abc_1.x`, String("abc_1.x")},
+		{`"a \"b\" \\ c"`, String(`a "b" \ c`)},
+		{`'say "hi"'`, String(`say "hi"`)},
+		{`"é😀"`, String("é😀")},
+		{`[B; 1b, -1b]`, ByteArray{1, 0xff}},
+		{`[I;]`, IntArray{}},
+		{`[I; 1, 2s, 3b]`, IntArray{1, 2, 3}},
+		{`[L;1L,-1]`, LongArray{1, -1}},
+		{`[]`, list(TAG_End)},
+		{`[1s, 2s]`, list(TAG_Short, Short(1), Short(2))},
+		{`[[], [1]]`, list(TAG_List, list(TAG_End), list(TAG_Int, Int(1)))},
+		{` { } `, &Compound{}},
+		{`{b: 1b, a: "x", "key with spaces": [], 'q"': 0}`, &Compound{Entries: []NamedTag{
+			{Name: "b", Value: Byte(1)},
+			{Name: "a", Value: String("x")},
+			{Name: "key with spaces", Value: list(TAG_End)},
+			{Name: `q"`, Value: Int(0)},
+		}}},
+		{`{Pos:[0.5d,64.0d],Data:{Time:1L}}`, &Compound{Entries: []NamedTag{
+			{Name: "Pos", Value: list(TAG_Double, Double(0.5), Double(64))},
+			{Name: "Data", Value: &Compound{Entries: []NamedTag{{Name: "Time", Value: Long(1)}}}},
+		}}},
+	}
+	for _, tt := range tests {
+		got, err := ParseSNBTTag(tt.in)
+		if err != nil {
+			t.Errorf("ParseSNBTTag(%s): %v", tt.in, err)
+		} else if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseSNBTTag(%s) = %#v, want %#v", tt.in, got, tt.want)
+		}
+	}
+}
+
+// ParseSNBT gives the same values as decoding the binary tag into an any.
+func TestParseSNBTAny(t *testing.T) {
+	for _, s := range []string{
+		`{a: 1b, b: 2s, c: 3, d: 4L, e: 5f, f: 6d, g: "s"}`,
+		`{arrays: {b: [B; 1b], i: [I; 1], l: [L; 1L]}}`,
+		`{lists: [[1, 2], [], [{x: 1b}]], empty: {}}`,
+	} {
+		want, err := ParseSNBT(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got any
+		if err := decodeSNBT(t, s, &got); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: ParseSNBT gave %#v, decoding gave %#v", s, want, got)
+		}
+	}
+}
+
+func TestParseSNBTErrors(t *testing.T) {
+	tests := []struct {
+		in     string
+		offset int
+	}{
+		{``, 0},
+		{`{`, 1},
+		{`{a}`, 2},
+		{`{a: 1,}`, 6},
+		{`{a: 1 b: 2}`, 6},
+		{`[1, 2b]`, 4},
+		{`[1,]`, 3},
+		{`[I; 1, "a"]`, 7},
+		{`[B; 128]`, 4},
+		{`[X; 1]`, 1},
+		{`"unterminated`, 13},
+		{`"bad \q escape"`, 5},
+		{`1 2`, 2},
+		{`minecraft:stone`, 9},
+		{`{a: 1} }`, 7},
+	}
+	for _, tt := range tests {
+		_, err := ParseSNBTTag(tt.in)
+		var serr *SNBTSyntaxError
+		if !errors.As(err, &serr) {
+			t.Errorf("ParseSNBTTag(%s): got error %v, want a *SNBTSyntaxError", tt.in, err)
+		} else if serr.Offset != tt.offset {
+			t.Errorf("ParseSNBTTag(%s): %v, want offset %d", tt.in, err, tt.offset)
+		}
+	}
+}
+
+func TestFormatSNBT(t *testing.T) {
+	tag, err := ParseSNBTTag(`{name: "Steve", pos: [0.5d, 64.0d], uuid: [I; 1, -2],
+		inv: [{id: "minecraft:dirt", n: 1b}, {}], empty: [], tags: [[]], "a b": 'x"y', f: 0.1f, l: [L;], ba: [B; -1b]}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	compact := `{name: "Steve", pos: [0.5d, 64d], uuid: [I; 1, -2], inv: [{id: "minecraft:dirt", n: 1b}, {}], empty: [], tags: [[]], "a b": 'x"y', f: 0.1f, l: [L;], ba: [B; -1b]}`
+	if got, err := FormatSNBT(tag, ""); err != nil || got != compact {
+		t.Errorf("FormatSNBT(tag, \"\") = %s, %v\nwant %s", got, err, compact)
+	}
+
+	indented := `{
+  name: "Steve",
+  pos: [0.5d, 64d],
+  uuid: [I; 1, -2],
+  inv: [
+    {
+      id: "minecraft:dirt",
+      n: 1b
+    },
+    {}
+  ],
+  empty: [],
+  tags: [
+    []
+  ],
+  "a b": 'x"y',
+  f: 0.1f,
+  l: [L;],
+  ba: [B; -1b]
+}`
+	if got, err := FormatSNBT(tag, "  "); err != nil || got != indented {
+		t.Errorf("FormatSNBT(tag, \"  \") = %s, %v\nwant %s", got, err, indented)
+	}
+
+	// the output parses back to the same tree
+	again, err := ParseSNBTTag(compact)
+	if err != nil || !tagsEqual(again, tag) {
+		t.Errorf("formatted SNBT parsed as %v, %v", again, err)
+	}
+
+	// maps from decoding into an any are sorted by key
+	if got, err := FormatSNBT(map[string]any{"b": int8(1), "a": []any{"x"}}, ""); err != nil || got != `{a: ["x"], b: 1b}` {
+		t.Errorf("formatting a map gave %s, %v", got, err)
+	}
+}
+
+func TestFormatSNBTErrors(t *testing.T) {
+	for _, v := range []any{Double(math.Inf(1)), Float(math.NaN()), make(chan int), map[string]any{"x": struct{}{}}} {
+		if s, err := FormatSNBT(v, ""); err == nil {
+			t.Errorf("FormatSNBT(%#v) = %s, want an error", v, s)
+		}
+	}
+}
+
+// make sure the SNBT used as fixtures elsewhere survives the binary format
+func TestSNBTBinaryRoundTrip(t *testing.T) {
+	tag, err := ParseSNBTTag(`{Data: {LevelName: "New World", Version: {Id: 3465, Snapshot: 0b}, Pos: [0.5d], Tags: []}}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := NewEncoder(&buf).Encode("", tag); err != nil {
+		t.Fatal(err)
+	}
+	var out Tag
+	if _, err := NewDecoder(&buf).Decode(&out); err != nil {
+		t.Fatal(err)
+	}
+	if !tagsEqual(out, tag) {
+		t.Errorf("binary round trip changed the tree:\n%s", Diff(tag, out))
+	}
+}
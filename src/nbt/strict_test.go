@@ -0,0 +1,184 @@
+package nbt
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+type strictPlayer struct {
+	Name      string `nbt:"Name"`
+	Health    float32
+	Inventory []struct {
+		ID    string `nbt:"id"`
+		Count int8
+	}
+	Attributes map[string]any
+	Extra      any
+}
+
+func TestDisallowUnknownFields(t *testing.T) {
+	tests := []struct {
+		snbt string
+		// path of the unknown tag, or "" if decoding should succeed
+		path string
+	}{
+		{`{Name: "Steve", Health: 20f, Inventory: [{id: "minecraft:dirt", Count: 1b}]}`, ""},
+		// maps and interfaces take any entry
+		{`{Attributes: {anything: 1}, Extra: {at: {all: []}}}`, ""},
+		{`{Name: "Steve", Score: 10}`, "Score"},
+		{`{Inventory: [{id: "a"}, {id: "b", Damage: 3}]}`, "Inventory[1].Damage"},
+		// names must match exactly unless SetCaseInsensitive is enabled
+		{`{name: "Steve"}`, "name"},
+	}
+	for _, tt := range tests {
+		tag, err := ParseSNBTTag(tt.snbt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var buf bytes.Buffer
+		if err := NewEncoder(&buf).Encode("", tag); err != nil {
+			t.Fatal(err)
+		}
+		d := NewDecoder(&buf)
+		d.DisallowUnknownFields()
+		var p strictPlayer
+		_, err = d.Decode(&p)
+
+		if tt.path == "" {
+			if err != nil {
+				t.Errorf("%s: %v", tt.snbt, err)
+			}
+			continue
+		}
+		var derr *DecodeError
+		if !errors.As(err, &derr) {
+			t.Errorf("%s: got %v, want a *DecodeError", tt.snbt, err)
+		} else if derr.Path != tt.path {
+			t.Errorf("%s: error at %s, want %s", tt.snbt, derr.Path, tt.path)
+		}
+	}
+}
+
+func TestDisallowTypeCoercion(t *testing.T) {
+	tests := []struct {
+		snbt string
+		v    any
+		ok   bool
+	}{
+		{`1b`, new(int8), true},
+		{`1b`, new(uint8), true},
+		{`1b`, new(bool), true},
+		{`1s`, new(int16), true},
+		{`1`, new(int), true},
+		{`1`, new(int32), true},
+		{`1L`, new(int64), true},
+		{`1.5f`, new(float32), true},
+		{`1.5d`, new(float64), true},
+		{`"x"`, new(string), true},
+		{`[B; 1b]`, new([]byte), true},
+		{`[I; 1]`, new([]int32), true},
+		{`[L; 1L]`, new([]int64), true},
+		{`[1s, 2s]`, new([]int16), true},
+		{`1s`, new(any), true},
+		{`{a: 1b}`, new(map[string]int8), true},
+
+		{`1b`, new(int32), false},
+		{`1s`, new(int), false},
+		{`1`, new(int64), false},
+		{`1L`, new(int32), false},
+		{`1s`, new(int8), false},
+		{`1.5f`, new(float64), false},
+		{`1.5d`, new(float32), false},
+		{`[1, 2]`, new([]int32), false},
+		{`[1L, 2L]`, new([]int64), false},
+		{`[1b]`, new([]int16), false},
+		{`{a: 1s}`, new(map[string]int8), false},
+	}
+	for _, tt := range tests {
+		tag, err := ParseSNBTTag(tt.snbt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var buf bytes.Buffer
+		if err := NewEncoder(&buf).Encode("", tag); err != nil {
+			t.Fatal(err)
+		}
+		in := buf.Bytes()
+
+		// every case decodes when coercion is allowed
+		out := reflect.New(reflect.TypeOf(tt.v).Elem())
+		if _, err := NewDecoder(bytes.NewReader(in)).Decode(out.Interface()); err != nil {
+			t.Errorf("%s into %s with coercion: %v", tt.snbt, out.Type().Elem(), err)
+		}
+
+		d := NewDecoder(bytes.NewReader(in))
+		d.DisallowTypeCoercion()
+		_, err = d.Decode(tt.v)
+		if tt.ok && err != nil {
+			t.Errorf("%s into %s: %v", tt.snbt, out.Type().Elem(), err)
+		} else if !tt.ok && err == nil {
+			t.Errorf("%s into %s: coerced without an error", tt.snbt, out.Type().Elem())
+		}
+	}
+}
+
+func TestRecordUnknownFields(t *testing.T) {
+	encode := func(snbt string) []byte {
+		tag, err := ParseSNBTTag(snbt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var buf bytes.Buffer
+		if err := NewEncoder(&buf).Encode("", tag); err != nil {
+			t.Fatal(err)
+		}
+		return buf.Bytes()
+	}
+	tests := []struct {
+		snbt            string
+		caseInsensitive bool
+		want            []string
+	}{
+		{`{Name: "Steve", Attributes: {x: 1}, Extra: 2}`, false, nil},
+		{`{Name: "Steve", Score: 10, "odd name": 1b}`, false, []string{"Score", `"odd name"`}},
+		{`{Inventory: [{id: "a", Damage: 3}, {id: "b", tag: {Damage: 4}}], XpLevel: 5}`, false,
+			[]string{"Inventory[0].Damage", "Inventory[1].tag", "XpLevel"}},
+		{`{name: "Steve", HEALTH: 20f}`, false, []string{"name", "HEALTH"}},
+		{`{name: "Steve", HEALTH: 20f}`, true, nil},
+	}
+	for _, tt := range tests {
+		d := NewDecoder(bytes.NewReader(encode(tt.snbt)))
+		d.RecordUnknownFields()
+		d.SetCaseInsensitive(tt.caseInsensitive)
+		var p strictPlayer
+		if _, err := d.Decode(&p); err != nil {
+			t.Errorf("%s: %v", tt.snbt, err)
+		} else if got := d.UnknownFields(); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: unknown fields %q, want %q", tt.snbt, got, tt.want)
+		}
+	}
+
+	// the fields are reset by each call to Decode, and not recorded at all
+	// unless asked for
+	in := append(encode(`{Score: 1}`), encode(`{Name: "x"}`)...)
+	d := NewDecoder(bytes.NewReader(in))
+	d.RecordUnknownFields()
+	var p strictPlayer
+	for i, want := range [][]string{{"Score"}, nil} {
+		if _, err := d.Decode(&p); err != nil {
+			t.Fatal(err)
+		}
+		if got := d.UnknownFields(); !reflect.DeepEqual(got, want) {
+			t.Errorf("Decode %d: unknown fields %q, want %q", i, got, want)
+		}
+	}
+	d = NewDecoder(bytes.NewReader(in))
+	if _, err := d.Decode(&p); err != nil {
+		t.Fatal(err)
+	}
+	if got := d.UnknownFields(); got != nil {
+		t.Errorf("recorded unknown fields %q without RecordUnknownFields", got)
+	}
+}
@@ -23,6 +23,21 @@ func parseTag(tag string) (string, tagOptions) {
 	return tag, tagOptions(opt)
 }
 
+// Values returns the values of every option of the form "name=value", eg.
+// Values("alias") for "alias=a,alias=b" returns ["a", "b"].
+func (o tagOptions) Values(optionName string) []string {
+	var values []string
+	s := string(o)
+	for s != "" {
+		var opt string
+		opt, s, _ = strings.Cut(s, ",")
+		if name, value, ok := strings.Cut(opt, "="); ok && name == optionName {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
 // Contains reports whether a comma-separated list of options
 // contains a particular substr flag. substr must be surrounded by a
 // string boundary or commas.
@@ -44,6 +59,27 @@ func (o tagOptions) Contains(optionName string) bool {
 type structFields struct {
 	list        []field
 	byExactName map[string]*field
+	// alternative names declared with ",alias=", which are only used if no
+	// field has that exact name
+	byAlias map[string]*field
+	// names and aliases folded to lower case, for NBTDecoder.SetCaseInsensitive
+	byFoldedName map[string]*field
+	hasDefaults  bool // whether any field has a ",default=" option
+}
+
+// lookup finds the field a tag should be decoded into.
+func (fs *structFields) lookup(name string, caseInsensitive bool) (*field, bool) {
+	if f, ok := fs.byExactName[name]; ok {
+		return f, true
+	}
+	if f, ok := fs.byAlias[name]; ok {
+		return f, true
+	}
+	if caseInsensitive {
+		f, ok := fs.byFoldedName[strings.ToLower(name)]
+		return f, ok
+	}
+	return nil, false
 }
 
 // A field represents a single field found in a struct.
@@ -53,7 +89,15 @@ type field struct {
 	tag       bool
 	index     []int
 	typ       reflect.Type
+	aliases   []string
 	omitEmpty bool
+	asString  bool // ",string"
+	asBool    bool // ",bool"
+
+	// encoded ",default=" value (see fieldopts.go), or the error from parsing
+	// it, which is reported if the default is ever needed
+	defaultValue RawMessage
+	defaultErr   error
 }
 
 // typeFields returns a list of fields that JSON should recognize for the given type.
@@ -117,18 +161,27 @@ func typeFields(t reflect.Type) structFields {
 					ft = ft.Elem()
 				}
 
-				// Record found field and index sequence.
-				if name != "" || !sf.Anonymous || ft.Kind() != reflect.Struct {
+				// Record found field and index sequence. Inlined structs are
+				// treated like embedded ones, even if they have a name.
+				inline := opts.Contains("inline") && ft.Kind() == reflect.Struct
+				if !inline && (name != "" || !sf.Anonymous || ft.Kind() != reflect.Struct) {
 					tagged := name != ""
 					if name == "" {
 						name = sf.Name
 					}
+					opts, def, hasDefault := opts.cutDefault()
 					field := field{
 						name:      name,
 						tag:       tagged,
 						index:     index,
 						typ:       ft,
+						aliases:   opts.Values("alias"),
 						omitEmpty: opts.Contains("omitempty"),
+						asString:  opts.Contains("string"),
+						asBool:    opts.Contains("bool"),
+					}
+					if hasDefault {
+						field.defaultValue, field.defaultErr = parseDefault(def, ft)
 					}
 
 					fields = append(fields, field)
@@ -204,11 +257,41 @@ func typeFields(t reflect.Type) structFields {
 	})
 
 	exactNameIndex := make(map[string]*field, len(fields))
+	aliasIndex := make(map[string]*field)
+	hasDefaults := false
 	for i, field := range fields {
 		exactNameIndex[field.name] = &fields[i]
+		for _, alias := range field.aliases {
+			if _, ok := aliasIndex[alias]; !ok {
+				aliasIndex[alias] = &fields[i]
+			}
+		}
+		hasDefaults = hasDefaults || field.defaultValue != nil || field.defaultErr != nil
+	}
+
+	// when names differ only by case, the first field (in declaration order)
+	// wins, and names win over aliases
+	foldedNameIndex := make(map[string]*field, len(fields))
+	for i, field := range fields {
+		if folded := strings.ToLower(field.name); foldedNameIndex[folded] == nil {
+			foldedNameIndex[folded] = &fields[i]
+		}
+	}
+	for i, field := range fields {
+		for _, alias := range field.aliases {
+			if folded := strings.ToLower(alias); foldedNameIndex[folded] == nil {
+				foldedNameIndex[folded] = &fields[i]
+			}
+		}
 	}
 
-	return structFields{fields, exactNameIndex}
+	return structFields{
+		list:         fields,
+		byExactName:  exactNameIndex,
+		byAlias:      aliasIndex,
+		byFoldedName: foldedNameIndex,
+		hasDefaults:  hasDefaults,
+	}
 }
 
 // dominantField looks through the fields, all of which are known to
@@ -0,0 +1,339 @@
+package nbt
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+// encodeTag encodes v and decodes it back as a generic tree, so tests can
+// check exactly which tags were written.
+func encodeTag(t *testing.T, v any) *Compound {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := NewEncoder(&buf).Encode("", v); err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+	var tag Tag
+	if _, err := NewDecoder(&buf).Decode(&tag); err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	return tag.(*Compound)
+}
+
+// decodeSNBT encodes an SNBT compound and decodes it into v.
+func decodeSNBT(t *testing.T, snbt string, v any) error {
+	t.Helper()
+	tag, err := ParseSNBTTag(snbt)
+	if err != nil {
+		t.Fatalf("ParseSNBTTag: %v", err)
+	}
+	var buf bytes.Buffer
+	if err := NewEncoder(&buf).Encode("", tag); err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+	_, err = NewDecoder(&buf).Decode(v)
+	return err
+}
+
+func entryNames(c *Compound) []string {
+	names := make([]string, len(c.Entries))
+	for i, e := range c.Entries {
+		names[i] = e.Name
+	}
+	return names
+}
+
+type Position struct {
+	X, Y, Z int32
+}
+
+type Named struct {
+	Name string `nbt:"name"`
+	// conflicts with Entity.ID at a shallower depth, so it is hidden
+	ID string `nbt:"id"`
+}
+
+type Tagged struct {
+	// conflicts with Untagged.Health at the same depth, but wins because it
+	// has a tag
+	Health float32 `nbt:"Health"`
+}
+
+type Untagged struct {
+	Health float32
+}
+
+type Ambiguous1 struct{ Motion []float64 }
+type Ambiguous2 struct{ Motion []float64 }
+
+type Entity struct {
+	ID string `nbt:"id"`
+	Position
+	*Named
+	Tagged
+	Untagged
+	// neither field is promoted, so Motion is dropped entirely
+	Ambiguous1
+	Ambiguous2
+}
+
+func TestTypeFieldsEmbedded(t *testing.T) {
+	fields := cachedTypeFields(reflect.TypeFor[Entity]())
+
+	got := map[string][]int{}
+	for _, f := range fields.list {
+		got[f.name] = f.index
+	}
+	want := map[string][]int{
+		"id":     {0},
+		"X":      {1, 0},
+		"Y":      {1, 1},
+		"Z":      {1, 2},
+		"name":   {2, 0},
+		"Health": {3, 0},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("typeFields(Entity) = %v, want %v", got, want)
+	}
+}
+
+func TestEmbeddedRoundTrip(t *testing.T) {
+	in := Entity{ID: "minecraft:pig", Position: Position{1, 2, 3}, Named: &Named{Name: "Bob"}}
+	in.Tagged.Health = 10
+
+	c := encodeTag(t, in)
+	if names, want := entryNames(c), []string{"id", "X", "Y", "Z", "name", "Health"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("encoded entries = %v, want %v", names, want)
+	}
+
+	var out Entity
+	if err := decodeSNBT(t, `{id:"minecraft:pig",X:1,Y:2,Z:3,name:"Bob",Health:10.0f}`, &out); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(out, in) {
+		t.Errorf("decoded %+v, want %+v", out, in)
+	}
+
+	// a nil embedded pointer is skipped when encoding
+	in.Named = nil
+	if _, ok := encodeTag(t, in).Get("name"); ok {
+		t.Errorf("field of nil embedded pointer was encoded")
+	}
+}
+
+type Velocity struct {
+	DX, DY, DZ float64
+}
+
+type Vehicle struct {
+	Kind     string    `nbt:"kind"`
+	Position Position  `nbt:",inline"`
+	Velocity *Velocity `nbt:"velocity,inline"`
+	Speed    float64   `nbt:"speed,omitempty"`
+}
+
+func TestInline(t *testing.T) {
+	c := encodeTag(t, Vehicle{Kind: "boat", Position: Position{1, 2, 3}})
+	if names, want := entryNames(c), []string{"kind", "X", "Y", "Z"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("encoded entries = %v, want %v", names, want)
+	}
+
+	var out Vehicle
+	if err := decodeSNBT(t, `{kind:"boat",X:4,Y:5,Z:6,DX:0.5d,speed:1.5d}`, &out); err != nil {
+		t.Fatal(err)
+	}
+	want := Vehicle{Kind: "boat", Position: Position{4, 5, 6}, Velocity: &Velocity{DX: 0.5}, Speed: 1.5}
+	if !reflect.DeepEqual(out, want) {
+		t.Errorf("decoded %+v, want %+v", out, want)
+	}
+}
+
+func TestOmitEmpty(t *testing.T) {
+	type S struct {
+		A int32          `nbt:"a,omitempty"`
+		B string         `nbt:"b,omitempty"`
+		C []int32        `nbt:"c,omitempty"`
+		D map[string]any `nbt:"d,omitempty"`
+		E int32          `nbt:"e"`
+	}
+	if names, want := entryNames(encodeTag(t, S{})), []string{"e"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("encoded entries = %v, want %v", names, want)
+	}
+	if names := entryNames(encodeTag(t, S{A: 1, B: "x", C: []int32{1}, D: map[string]any{"k": int8(1)}})); len(names) != 5 {
+		t.Errorf("encoded entries = %v, want all 5", names)
+	}
+}
+
+type GameRules struct {
+	DoDaylightCycle bool    `nbt:"doDaylightCycle,string"`
+	RandomTickSpeed int     `nbt:"randomTickSpeed,string"`
+	SpawnRadius     *uint16 `nbt:"spawnRadius,string"`
+	Scale           float32 `nbt:"scale,string"`
+}
+
+func TestStringOption(t *testing.T) {
+	radius := uint16(10)
+	in := GameRules{DoDaylightCycle: true, RandomTickSpeed: 3, SpawnRadius: &radius, Scale: 0.5}
+
+	c := encodeTag(t, in)
+	for name, want := range map[string]Tag{
+		"doDaylightCycle": String("true"),
+		"randomTickSpeed": String("3"),
+		"spawnRadius":     String("10"),
+		"scale":           String("0.5"),
+	} {
+		if got, _ := c.Get(name); got != want {
+			t.Errorf("%s encoded as %#v, want %#v", name, got, want)
+		}
+	}
+
+	var out GameRules
+	if err := decodeSNBT(t, `{doDaylightCycle:"true",randomTickSpeed:"3",spawnRadius:"10",scale:"0.5"}`, &out); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(out, in) {
+		t.Errorf("decoded %+v, want %+v", out, in)
+	}
+
+	// tags that already have the field's type are still accepted
+	out = GameRules{}
+	if err := decodeSNBT(t, `{randomTickSpeed:7}`, &out); err != nil || out.RandomTickSpeed != 7 {
+		t.Errorf("decoding TAG_Int with ,string: got %d, %v", out.RandomTickSpeed, err)
+	}
+
+	if err := decodeSNBT(t, `{randomTickSpeed:"fast"}`, &out); err == nil {
+		t.Errorf("decoding invalid number succeeded")
+	}
+}
+
+func TestBoolOption(t *testing.T) {
+	type S struct {
+		A bool `nbt:"a,bool"`
+		B bool `nbt:"b,bool"`
+		C bool `nbt:"c"`
+	}
+	var out S
+	if err := decodeSNBT(t, `{a:1,b:0L,c:1b}`, &out); err != nil {
+		t.Fatal(err)
+	}
+	if want := (S{A: true, C: true}); out != want {
+		t.Errorf("decoded %+v, want %+v", out, want)
+	}
+	if err := decodeSNBT(t, `{c:1}`, &out); err == nil {
+		t.Errorf("decoding TAG_Int into bool without ,bool succeeded")
+	}
+}
+
+func TestDefault(t *testing.T) {
+	type S struct {
+		Count  int8     `nbt:"count,default=1"`
+		ID     string   `nbt:"id,default=minecraft:air"`
+		Quoted string   `nbt:"quoted,omitempty,default=\"a,b\""`
+		Scale  float64  `nbt:"scale,default=2"`
+		Tags   []string `nbt:"tags,default=[\"x\",\"y\"]"`
+		Pos    Position `nbt:"pos,default={X:1,Y:2,Z:3}"`
+		Plain  int32    `nbt:"plain"`
+	}
+
+	var out S
+	if err := decodeSNBT(t, `{plain:5}`, &out); err != nil {
+		t.Fatal(err)
+	}
+	want := S{Count: 1, ID: "minecraft:air", Quoted: "a,b", Scale: 2, Tags: []string{"x", "y"}, Pos: Position{1, 2, 3}, Plain: 5}
+	if !reflect.DeepEqual(out, want) {
+		t.Errorf("decoded %+v, want %+v", out, want)
+	}
+
+	out = S{}
+	if err := decodeSNBT(t, `{count:64b,id:"minecraft:stone"}`, &out); err != nil {
+		t.Fatal(err)
+	}
+	if out.Count != 64 || out.ID != "minecraft:stone" || out.Scale != 2 {
+		t.Errorf("present tags should override defaults: %+v", out)
+	}
+
+	type Invalid struct {
+		N int32 `nbt:"n,default={a:1}"`
+	}
+	if err := decodeSNBT(t, `{}`, &Invalid{}); err == nil {
+		t.Errorf("decoding with an invalid default succeeded")
+	}
+}
+
+// defaults are decoded by the decoder already in use, and a small input only
+// gets a small read buffer, so decoding a tag with defaults is cheap
+func TestDefaultAllocs(t *testing.T) {
+	type S struct {
+		Count int8    `nbt:"count,default=1"`
+		Scale float64 `nbt:"scale,default=2"`
+		Pos   [3]int8 `nbt:"pos,default=[1b,2b,3b]"`
+		Plain int32   `nbt:"plain"`
+	}
+	in := []byte{TAG_Compound, 0, 0, TAG_Int, 0, 5, 'p', 'l', 'a', 'i', 'n', 0, 0, 0, 5, TAG_End}
+	var out S
+	allocs := testing.AllocsPerRun(100, func() {
+		if _, err := NewDecoder(bytes.NewReader(in)).Decode(&out); err != nil {
+			t.Fatal(err)
+		}
+	})
+	if want := (S{1, 2, [3]int8{1, 2, 3}, 5}); out != want {
+		t.Errorf("decoded %+v, want %+v", out, want)
+	}
+	if allocs > 8 {
+		t.Errorf("%v allocations per Decode, want at most 8", allocs)
+	}
+	if n := len(newBufReader(bytes.NewReader(in)).buf); n >= readBufSize {
+		t.Errorf("read buffer of %d bytes for %d bytes of input", n, len(in))
+	}
+}
+
+func TestAliases(t *testing.T) {
+	type Section struct {
+		Y       int8    `nbt:"Y"`
+		Palette []int32 `nbt:"palette,alias=Palette,alias=BlockPalette"`
+		// an alias never hides a field's real name
+		Data   []int64 `nbt:"data,alias=Light"`
+		Light  []byte  `nbt:"Light"`
+		Status string  `nbt:"status"`
+	}
+
+	var out Section
+	if err := decodeSNBT(t, `{Y:1b,Palette:[1,2],data:[L;3L],Light:[B;4b]}`, &out); err != nil {
+		t.Fatal(err)
+	}
+	want := Section{Y: 1, Palette: []int32{1, 2}, Data: []int64{3}, Light: []byte{4}}
+	if !reflect.DeepEqual(out, want) {
+		t.Errorf("decoded %+v, want %+v", out, want)
+	}
+
+	out = Section{}
+	if err := decodeSNBT(t, `{BlockPalette:[5]}`, &out); err != nil || !reflect.DeepEqual(out.Palette, []int32{5}) {
+		t.Errorf("decoding second alias: got %v, %v", out.Palette, err)
+	}
+
+	// case folding is off by default
+	tag, err := ParseSNBTTag(`{y:2b,STATUS:"full",blockpalette:[6]}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := NewEncoder(&buf).Encode("", tag); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+
+	out = Section{}
+	if _, err := NewDecoder(bytes.NewReader(data)).Decode(&out); err != nil || out.Y != 0 || out.Status != "" {
+		t.Errorf("decoded %+v, %v without case folding", out, err)
+	}
+
+	d := NewDecoder(bytes.NewReader(data))
+	d.SetCaseInsensitive(true)
+	if _, err := d.Decode(&out); err != nil {
+		t.Fatal(err)
+	}
+	if out.Y != 2 || out.Status != "full" || !reflect.DeepEqual(out.Palette, []int32{6}) {
+		t.Errorf("decoded %+v with case folding", out)
+	}
+}
@@ -0,0 +1,475 @@
+package nbt
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+)
+
+// tag.go
+// a typed, order-preserving model for arbitrary NBT trees. Unlike decoding
+// into `any`, decoding into a Tag keeps the element type of empty lists and
+// the order of compound entries, so a tree can be re-encoded byte-for-byte.
+
+// Tag is a single value in a generic NBT tree. It is implemented by Byte,
+// Short, Int, Long, Float, Double, ByteArray, String, *List, *Compound,
+// IntArray and LongArray.
+type Tag interface {
+	TagType() byte
+}
+
+type (
+	Byte      int8
+	Short     int16
+	Int       int32
+	Long      int64
+	Float     float32
+	Double    float64
+	ByteArray []byte
+	String    string
+	IntArray  []int32
+	LongArray []int64
+)
+
+// List is a TAG_List. ElemType is kept even when the list is empty.
+type List struct {
+	ElemType byte
+	Elems    []Tag
+}
+
+// NamedTag is a single entry in a Compound.
+type NamedTag struct {
+	Name  string
+	Value Tag
+}
+
+// Compound is a TAG_Compound whose entries are kept in the order they were
+// read (or added).
+type Compound struct {
+	Entries []NamedTag
+}
+
+func (Byte) TagType() byte      { return TAG_Byte }
+func (Short) TagType() byte     { return TAG_Short }
+func (Int) TagType() byte       { return TAG_Int }
+func (Long) TagType() byte      { return TAG_Long }
+func (Float) TagType() byte     { return TAG_Float }
+func (Double) TagType() byte    { return TAG_Double }
+func (ByteArray) TagType() byte { return TAG_Byte_Array }
+func (String) TagType() byte    { return TAG_String }
+func (*List) TagType() byte     { return TAG_List }
+func (*Compound) TagType() byte { return TAG_Compound }
+func (IntArray) TagType() byte  { return TAG_Int_Array }
+func (LongArray) TagType() byte { return TAG_Long_Array }
+
+// Get returns the value of the entry called name, if present.
+func (c *Compound) Get(name string) (Tag, bool) {
+	for _, e := range c.Entries {
+		if e.Name == name {
+			return e.Value, true
+		}
+	}
+	return nil, false
+}
+
+// Set replaces the value of the entry called name, or appends a new entry if
+// there is none.
+func (c *Compound) Set(name string, value Tag) {
+	for i, e := range c.Entries {
+		if e.Name == name {
+			c.Entries[i].Value = value
+			return
+		}
+	}
+	c.Entries = append(c.Entries, NamedTag{Name: name, Value: value})
+}
+
+// Delete removes the entry called name, and reports whether it was present.
+func (c *Compound) Delete(name string) bool {
+	for i, e := range c.Entries {
+		if e.Name == name {
+			c.Entries = slices.Delete(c.Entries, i, i+1)
+			return true
+		}
+	}
+	return false
+}
+
+// Append adds a value to the end of the list. If the list is empty its element
+// type is taken from the value, otherwise the types must match.
+func (l *List) Append(value Tag) error {
+	if len(l.Elems) == 0 {
+		l.ElemType = value.TagType()
+	} else if value.TagType() != l.ElemType {
+		return fmt.Errorf("can't insert %s into TAG_List of %s", TagName(value.TagType()), TagName(l.ElemType))
+	}
+	l.Elems = append(l.Elems, value)
+	return nil
+}
+
+var tagNames = [...]string{
+	TAG_End:        "TAG_End",
+	TAG_Byte:       "TAG_Byte",
+	TAG_Short:      "TAG_Short",
+	TAG_Int:        "TAG_Int",
+	TAG_Long:       "TAG_Long",
+	TAG_Float:      "TAG_Float",
+	TAG_Double:     "TAG_Double",
+	TAG_Byte_Array: "TAG_Byte_Array",
+	TAG_String:     "TAG_String",
+	TAG_List:       "TAG_List",
+	TAG_Compound:   "TAG_Compound",
+	TAG_Int_Array:  "TAG_Int_Array",
+	TAG_Long_Array: "TAG_Long_Array",
+}
+
+// TagName returns the name of a tag type, eg. "TAG_Compound"
+func TagName(tagType byte) string {
+	if int(tagType) < len(tagNames) {
+		return tagNames[tagType]
+	}
+	return fmt.Sprintf("TAG_Unknown(%#02x)", tagType)
+}
+
+var (
+	tagInterfaceType = reflect.TypeFor[Tag]()
+
+	// concrete types that make up a generic tree
+	treeTypes = map[reflect.Type]bool{
+		reflect.TypeFor[Byte]():      true,
+		reflect.TypeFor[Short]():     true,
+		reflect.TypeFor[Int]():       true,
+		reflect.TypeFor[Long]():      true,
+		reflect.TypeFor[Float]():     true,
+		reflect.TypeFor[Double]():    true,
+		reflect.TypeFor[ByteArray](): true,
+		reflect.TypeFor[String]():    true,
+		reflect.TypeFor[List]():      true,
+		reflect.TypeFor[Compound]():  true,
+		reflect.TypeFor[IntArray]():  true,
+		reflect.TypeFor[LongArray](): true,
+	}
+)
+
+// treeTagOf returns v as a Tag if it holds one of the generic tree types.
+func treeTagOf(v reflect.Value) (Tag, bool) {
+	if !v.IsValid() || !v.CanInterface() {
+		return nil, false
+	}
+	if (v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface) && v.IsNil() {
+		return nil, false
+	}
+
+	switch t := v.Interface().(type) {
+	case Compound:
+		return &t, true
+	case List:
+		return &t, true
+	case *Compound, *List:
+		return t.(Tag), true
+	case Tag:
+		if treeTypes[reflect.TypeOf(t)] {
+			return t, true
+		}
+	}
+	return nil, false
+}
+
+// setTreeTag stores a decoded tag in val, which must either be a Tag interface
+// or one of the concrete tree types.
+func setTreeTag(val reflect.Value, tag Tag) error {
+	tv := reflect.ValueOf(tag)
+	if tv.Type().AssignableTo(val.Type()) {
+		val.Set(tv)
+		return nil
+	}
+	if tv.Kind() == reflect.Pointer && tv.Elem().Type().AssignableTo(val.Type()) {
+		val.Set(tv.Elem())
+		return nil
+	}
+	return fmt.Errorf("can't unmarshal %s into go type %q", TagName(tag.TagType()), val.Type().String())
+}
+
+// readTag reads the payload of a tag of the given type into a generic tree.
+func (d *NBTDecoder) readTag(tagType byte) (Tag, error) {
+	tag, err := d.readTagValue(tagType)
+	if err != nil {
+		return nil, d.wrapError(err, tagType, nil)
+	}
+	return tag, nil
+}
+
+func (d *NBTDecoder) readTagValue(tagType byte) (Tag, error) {
+	switch tagType {
+	case TAG_End:
+		return nil, errors.New("unexpected TAG_End")
+	case TAG_Byte:
+		v, err := d.ReadInt8()
+		return Byte(v), err
+	case TAG_Short:
+		v, err := d.ReadInt16()
+		return Short(v), err
+	case TAG_Int:
+		v, err := d.ReadInt32()
+		return Int(v), err
+	case TAG_Long:
+		v, err := d.ReadInt64()
+		return Long(v), err
+	case TAG_Float:
+		v, err := d.ReadFloat32()
+		return Float(v), err
+	case TAG_Double:
+		v, err := d.ReadFloat64()
+		return Double(v), err
+	case TAG_Byte_Array:
+		var v []byte
+		err := d.unmarshal(reflect.ValueOf(&v).Elem(), tagType)
+		return ByteArray(v), err
+	case TAG_String:
+		v, err := d.ReadString()
+		return String(v), err
+	case TAG_Int_Array:
+		var v []int32
+		err := d.unmarshal(reflect.ValueOf(&v).Elem(), tagType)
+		return IntArray(v), err
+	case TAG_Long_Array:
+		var v []int64
+		err := d.unmarshal(reflect.ValueOf(&v).Elem(), tagType)
+		return LongArray(v), err
+	case TAG_List:
+		if err := d.enter(); err != nil {
+			return nil, err
+		}
+		defer d.leave()
+
+		listType, listLen, err := d.readListHeader()
+		if err != nil {
+			return nil, err
+		}
+		if err := d.alloc(int64(listLen) * int64(tagInterfaceType.Size())); err != nil {
+			return nil, err
+		}
+
+		list := &List{ElemType: listType, Elems: make([]Tag, 0, min(listLen, maxPrealloc/int(tagInterfaceType.Size())))}
+		for i := 0; i < listLen; i++ {
+			d.pushIndex(i)
+			elem, err := d.readTag(listType)
+			if err != nil {
+				return nil, err
+			}
+			list.Elems = append(list.Elems, elem)
+			d.pop()
+		}
+		return list, nil
+	case TAG_Compound:
+		if err := d.enter(); err != nil {
+			return nil, err
+		}
+		defer d.leave()
+
+		compound := &Compound{}
+		for {
+			fieldTagType, fieldTagName, err := d.ReadTagHeader()
+			if err != nil {
+				return nil, err
+			}
+			if fieldTagType == TAG_End {
+				break
+			}
+			d.pushName(fieldTagName)
+			value, err := d.readTag(fieldTagType)
+			if err != nil {
+				return nil, err
+			}
+			compound.Entries = append(compound.Entries, NamedTag{Name: fieldTagName, Value: value})
+			d.pop()
+		}
+		return compound, nil
+	default:
+		return nil, fmt.Errorf("can't unmarshal unknown tag type %#02x", tagType)
+	}
+}
+
+// writeTag writes the payload of a generic tree tag.
+func (e *NBTEncoder) writeTag(tag Tag) error {
+	switch t := tag.(type) {
+	case Byte:
+		return e.WriteInt8(int8(t))
+	case Short:
+		return e.WriteInt16(int16(t))
+	case Int:
+		return e.WriteInt32(int32(t))
+	case Long:
+		return e.WriteInt64(int64(t))
+	case Float:
+		return e.WriteFloat32(float32(t))
+	case Double:
+		return e.WriteFloat64(float64(t))
+	case ByteArray:
+		return e.marshal(reflect.ValueOf([]byte(t)), TAG_Byte_Array)
+	case String:
+		return e.WriteString(string(t))
+	case IntArray:
+		return e.marshal(reflect.ValueOf([]int32(t)), TAG_Int_Array)
+	case LongArray:
+		return e.marshal(reflect.ValueOf([]int64(t)), TAG_Long_Array)
+	case *List:
+		if err := e.WriteInt8(int8(t.ElemType)); err != nil {
+			return err
+		}
+		if err := e.writeArrayLen(reflect.ValueOf(t.Elems)); err != nil {
+			return err
+		}
+		for i, elem := range t.Elems {
+			if elem == nil || elem.TagType() != t.ElemType {
+				return fmt.Errorf("can't marshal index %d into TAG_List of %s", i, TagName(t.ElemType))
+			}
+			if err := e.writeTag(elem); err != nil {
+				return fmt.Errorf("failed to encode index %d in TAG_List: %w", i, err)
+			}
+		}
+	case *Compound:
+		for _, entry := range t.Entries {
+			if entry.Value == nil {
+				return fmt.Errorf("can't marshal nil value for field %q in TAG_Compound", entry.Name)
+			}
+			if err := e.WriteTagHeader(entry.Value.TagType(), entry.Name); err != nil {
+				return err
+			}
+			if err := e.writeTag(entry.Value); err != nil {
+				return fmt.Errorf("failed to encode field %q in TAG_Compound: %w", entry.Name, err)
+			}
+		}
+		return e.WriteInt8(TAG_End)
+	default:
+		return fmt.Errorf("can't marshal go type %T as a tag", tag)
+	}
+	return nil
+}
+
+// ToAny converts a generic tree into the representation produced by decoding
+// into an `any`: map[string]any, []any, int8, []byte and so on.
+func ToAny(tag Tag) any {
+	switch t := tag.(type) {
+	case Byte:
+		return int8(t)
+	case Short:
+		return int16(t)
+	case Int:
+		return int32(t)
+	case Long:
+		return int64(t)
+	case Float:
+		return float32(t)
+	case Double:
+		return float64(t)
+	case ByteArray:
+		return []byte(t)
+	case String:
+		return string(t)
+	case IntArray:
+		return []int32(t)
+	case LongArray:
+		return []int64(t)
+	case *List:
+		out := make([]any, len(t.Elems))
+		for i, elem := range t.Elems {
+			out[i] = ToAny(elem)
+		}
+		return out
+	case *Compound:
+		out := make(map[string]any, len(t.Entries))
+		for _, entry := range t.Entries {
+			out[entry.Name] = ToAny(entry.Value)
+		}
+		return out
+	}
+	return nil
+}
+
+// FromAny converts a value in the representation produced by decoding into an
+// `any` into a generic tree. Compound entries are sorted by name, and empty
+// lists become lists of TAG_End.
+func FromAny(v any) (Tag, error) {
+	switch v := v.(type) {
+	case Tag:
+		if t, ok := treeTagOf(reflect.ValueOf(v)); ok {
+			return t, nil
+		}
+	case int8:
+		return Byte(v), nil
+	case int16:
+		return Short(v), nil
+	case int32:
+		return Int(v), nil
+	case int64:
+		return Long(v), nil
+	case float32:
+		return Float(v), nil
+	case float64:
+		return Double(v), nil
+	case []byte:
+		return ByteArray(v), nil
+	case string:
+		return String(v), nil
+	case []int32:
+		return IntArray(v), nil
+	case []int64:
+		return LongArray(v), nil
+	case []any:
+		list := &List{}
+		for i, elem := range v {
+			t, err := FromAny(elem)
+			if err != nil {
+				return nil, fmt.Errorf("failed to convert index %d in list: %w", i, err)
+			}
+			if err := list.Append(t); err != nil {
+				return nil, err
+			}
+		}
+		return list, nil
+	case map[string]any:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		slices.Sort(keys)
+
+		compound := &Compound{Entries: make([]NamedTag, 0, len(keys))}
+		for _, k := range keys {
+			t, err := FromAny(v[k])
+			if err != nil {
+				return nil, fmt.Errorf("failed to convert field %q: %w", k, err)
+			}
+			compound.Entries = append(compound.Entries, NamedTag{Name: k, Value: t})
+		}
+		return compound, nil
+	}
+	return nil, fmt.Errorf("can't convert go type %T to a tag", v)
+}
+
+// CloneTag returns a deep copy of a generic tree.
+func CloneTag(tag Tag) Tag {
+	switch t := tag.(type) {
+	case ByteArray:
+		return slices.Clone(t)
+	case IntArray:
+		return slices.Clone(t)
+	case LongArray:
+		return slices.Clone(t)
+	case *List:
+		out := &List{ElemType: t.ElemType, Elems: make([]Tag, len(t.Elems))}
+		for i, elem := range t.Elems {
+			out.Elems[i] = CloneTag(elem)
+		}
+		return out
+	case *Compound:
+		out := &Compound{Entries: make([]NamedTag, len(t.Entries))}
+		for i, entry := range t.Entries {
+			out.Entries[i] = NamedTag{Name: entry.Name, Value: CloneTag(entry.Value)}
+		}
+		return out
+	}
+	return tag
+}
@@ -0,0 +1,206 @@
+package nbt
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+// decoding into a Tag and encoding it again gives back the same bytes, which
+// decoding into an any can't do for entry order and empty lists
+func TestTagRoundTrip(t *testing.T) {
+	tests := []string{
+		`{z: 1, a: 2b, m: 3s}`,
+		`{empty: [], strings: [], nested: [[], [1L]]}`,
+		`{b: [B; 1b, -1b], i: [I;], l: [L; -1L], f: 1.5f, d: -0.5d, s: "é"}`,
+		`{list: [{y: 1b, x: "s"}, {}], compound: {q: {r: {}}}}`,
+	}
+	for _, s := range tests {
+		tag, err := ParseSNBTTag(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// an empty list whose element type isn't TAG_End
+		tag.(*Compound).Set("typedEmpty", &List{ElemType: TAG_String})
+
+		var buf bytes.Buffer
+		if err := NewEncoder(&buf).Encode("root", tag); err != nil {
+			t.Fatal(err)
+		}
+		in := bytes.Clone(buf.Bytes())
+
+		var out Tag
+		name, err := NewDecoder(bytes.NewReader(in)).Decode(&out)
+		if err != nil {
+			t.Fatalf("%s: %v", s, err)
+		}
+		if name != "root" {
+			t.Errorf("%s: root name %q, want %q", s, name, "root")
+		}
+		if !tagsEqual(out, tag) {
+			t.Errorf("%s: decoded as %s", s, Diff(tag, out))
+		}
+
+		buf.Reset()
+		if err := NewEncoder(&buf).Encode("root", out); err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(buf.Bytes(), in) {
+			t.Errorf("%s: re-encoded as %x, want %x", s, buf.Bytes(), in)
+		}
+	}
+}
+
+func TestDecodeTagFields(t *testing.T) {
+	var v struct {
+		Any      Tag       `nbt:"any"`
+		Compound *Compound `nbt:"compound"`
+		Value    Compound  `nbt:"value"`
+		List     List      `nbt:"list"`
+		Int      Int       `nbt:"int"`
+		Array    IntArray  `nbt:"array"`
+	}
+	err := decodeSNBT(t, `{any: 1s, compound: {a: 1b}, value: {b: 2b}, list: [], int: 3, array: [I; 4]}`, &v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v.Any != Short(1) {
+		t.Errorf("Tag field = %#v, want %#v", v.Any, Short(1))
+	}
+	if got, _ := v.Compound.Get("a"); got != Byte(1) {
+		t.Errorf("*Compound field has a = %#v", got)
+	}
+	if got, _ := v.Value.Get("b"); got != Byte(2) {
+		t.Errorf("Compound field has b = %#v", got)
+	}
+	if v.List.ElemType != TAG_End || len(v.List.Elems) != 0 {
+		t.Errorf("List field = %#v", v.List)
+	}
+	if v.Int != 3 || !reflect.DeepEqual(v.Array, IntArray{4}) {
+		t.Errorf("Int and IntArray fields = %v, %v", v.Int, v.Array)
+	}
+
+	// a tree type only accepts its own tag type
+	var wrong struct {
+		Int Int `nbt:"int"`
+	}
+	if err := decodeSNBT(t, `{int: 3L}`, &wrong); err == nil {
+		t.Errorf("decoded a TAG_Long into an Int")
+	}
+}
+
+func TestCompound(t *testing.T) {
+	c := &Compound{}
+	c.Set("b", Int(1))
+	c.Set("a", Int(2))
+	c.Set("c", Int(3))
+	c.Set("b", String("replaced"))
+
+	if got := entryNames(c); !reflect.DeepEqual(got, []string{"b", "a", "c"}) {
+		t.Errorf("entries %v, want insertion order", got)
+	}
+	if got, ok := c.Get("b"); !ok || got != String("replaced") {
+		t.Errorf("Get(b) = %v, %v", got, ok)
+	}
+	if got, ok := c.Get("missing"); ok || got != nil {
+		t.Errorf("Get(missing) = %v, %v", got, ok)
+	}
+	if !c.Delete("a") || c.Delete("a") {
+		t.Errorf("Delete(a) didn't report presence")
+	}
+	if got := entryNames(c); !reflect.DeepEqual(got, []string{"b", "c"}) {
+		t.Errorf("entries after delete %v", got)
+	}
+}
+
+func TestListAppend(t *testing.T) {
+	l := &List{ElemType: TAG_Compound}
+	if err := l.Append(Int(1)); err != nil {
+		t.Fatalf("appending to an empty list: %v", err)
+	}
+	if l.ElemType != TAG_Int {
+		t.Errorf("element type %s, want it taken from the first value", TagName(l.ElemType))
+	}
+	if err := l.Append(Int(2)); err != nil {
+		t.Error(err)
+	}
+	if err := l.Append(Long(3)); err == nil {
+		t.Errorf("appended a TAG_Long to a list of TAG_Int")
+	}
+	if len(l.Elems) != 2 {
+		t.Errorf("list has %d elements, want 2", len(l.Elems))
+	}
+}
+
+func TestToAnyFromAny(t *testing.T) {
+	tag, err := ParseSNBTTag(`{b: 1b, s: 2s, i: 3, l: 4L, f: 5f, d: 6d, str: "x",
+		ba: [B; 1b], ia: [I; 2], la: [L; 3L], list: [[1b], []], c: {n: {}}}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]any{
+		"b": int8(1), "s": int16(2), "i": int32(3), "l": int64(4),
+		"f": float32(5), "d": float64(6), "str": "x",
+		"ba": []byte{1}, "ia": []int32{2}, "la": []int64{3},
+		"list": []any{[]any{int8(1)}, []any{}},
+		"c":    map[string]any{"n": map[string]any{}},
+	}
+	got := ToAny(tag)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ToAny = %#v\nwant %#v", got, want)
+	}
+
+	// FromAny sorts compound entries, since maps are unordered
+	back, err := FromAny(got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if names := entryNames(back.(*Compound)); !reflect.DeepEqual(names, []string{"b", "ba", "c", "d", "f", "i", "ia", "l", "la", "list", "s", "str"}) {
+		t.Errorf("FromAny entries %v, want sorted", names)
+	}
+	if !reflect.DeepEqual(ToAny(back), want) {
+		t.Errorf("FromAny(ToAny(tag)) changed the values: %s", Diff(tag, back))
+	}
+
+	// tree values pass through unchanged
+	for _, v := range []Tag{Int(1), &List{ElemType: TAG_String}, &Compound{}} {
+		if got, err := FromAny(v); err != nil || got != v {
+			t.Errorf("FromAny(%#v) = %#v, %v", v, got, err)
+		}
+	}
+
+	for _, v := range []any{nil, 1, uint8(1), []int{1}, []any{int8(1), "a"}, map[string]any{"x": true}} {
+		if got, err := FromAny(v); err == nil {
+			t.Errorf("FromAny(%#v) = %#v, want an error", v, got)
+		}
+	}
+}
+
+func TestCloneTag(t *testing.T) {
+	tag, err := ParseSNBTTag(`{list: [{a: [I; 1]}], ba: [B; 1b], la: [L; 1L], s: "x"}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	clone := CloneTag(tag)
+	if !reflect.DeepEqual(clone, tag) {
+		t.Fatalf("clone differs: %s", Diff(tag, clone))
+	}
+
+	// changing the clone leaves the original alone
+	c := clone.(*Compound)
+	list, _ := c.Get("list")
+	inner := list.(*List).Elems[0].(*Compound)
+	a, _ := inner.Get("a")
+	a.(IntArray)[0] = 2
+	inner.Set("b", Byte(1))
+	ba, _ := c.Get("ba")
+	ba.(ByteArray)[0] = 2
+	la, _ := c.Get("la")
+	la.(LongArray)[0] = 2
+	c.Delete("s")
+
+	want, _ := ParseSNBTTag(`{list: [{a: [I; 1]}], ba: [B; 1b], la: [L; 1L], s: "x"}`)
+	if !reflect.DeepEqual(tag, want) {
+		t.Errorf("changing the clone changed the original: %s", Diff(want, tag))
+	}
+}
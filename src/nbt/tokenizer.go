@@ -0,0 +1,239 @@
+package nbt
+
+import (
+	"errors"
+	"fmt"
+)
+
+// tokenizer.go
+// pull-style access to an NBT stream, without reflection or building a tree
+
+type TokenKind byte
+
+const (
+	// start of a TAG_Compound. It is followed by a token for each entry, then
+	// a TokenEnd.
+	TokenCompound TokenKind = iota + 1
+	// start of a TAG_List. It is followed by a token for each element, then a
+	// TokenEnd.
+	TokenList
+	// end of the most recently started compound or list
+	TokenEnd
+	// any other tag. Its payload can be read with Tokenizer.Value, and is
+	// skipped otherwise.
+	TokenValue
+)
+
+func (k TokenKind) String() string {
+	switch k {
+	case TokenCompound:
+		return "TokenCompound"
+	case TokenList:
+		return "TokenList"
+	case TokenEnd:
+		return "TokenEnd"
+	case TokenValue:
+		return "TokenValue"
+	}
+	return fmt.Sprintf("TokenKind(%d)", byte(k))
+}
+
+type Token struct {
+	Kind TokenKind
+	// type of the tag. For TokenEnd, this is the type of the container being
+	// closed (TAG_Compound or TAG_List).
+	Type byte
+	// name of a compound entry or root tag
+	Name string
+	// index of a list element, or -1 if the tag isn't in a list
+	Index int
+	// element type and length of a TokenList
+	ElemType byte
+	Len      int
+}
+
+// tokenFrame is an open compound or list
+type tokenFrame struct {
+	list     bool
+	elemType byte
+	len      int
+	next     int  // index of the next list element
+	pushed   bool // whether a path segment was pushed for this container
+}
+
+// Tokenizer reads an NBT stream one tag at a time. It uses the variant,
+// limits and other options of the decoder it is created from, and reports
+// errors as *DecodeError.
+//
+// Once the root tag has been fully read, Next moves on to the next root tag
+// in the stream, or returns io.EOF if there isn't one.
+type Tokenizer struct {
+	d       *NBTDecoder
+	stack   []tokenFrame
+	pending byte // type of a TokenValue whose payload hasn't been read
+	popPath bool // the last token's path segment is still on d.path
+}
+
+func NewTokenizer(d *NBTDecoder) *Tokenizer {
+	return &Tokenizer{d: d}
+}
+
+// Depth returns the number of compounds and lists that are currently open.
+func (t *Tokenizer) Depth() int {
+	return len(t.stack)
+}
+
+// Path returns the path of the most recently returned token, in the same
+// syntax as DecodeError.Path.
+func (t *Tokenizer) Path() string {
+	return t.d.pathString()
+}
+
+// Next returns the next token in the stream. If the previous token was a
+// TokenValue whose payload wasn't read with Value, it is discarded first.
+func (t *Tokenizer) Next() (Token, error) {
+	if err := t.finish(); err != nil {
+		return Token{}, err
+	}
+
+	if len(t.stack) == 0 {
+		t.d.allocated = 0
+		t.d.path = t.d.path[:0]
+		tagType, name, err := t.d.readRootHeader()
+		if err != nil {
+			// io.EOF is passed through as-is at the end of the stream
+			return Token{}, err
+		}
+		return t.begin(tagType, name, -1, false)
+	}
+
+	top := &t.stack[len(t.stack)-1]
+	if top.list {
+		if top.next == top.len {
+			return t.end(), nil
+		}
+		i := top.next
+		top.next++
+		t.d.pushIndex(i)
+		return t.begin(top.elemType, "", i, true)
+	}
+
+	tagType, name, err := t.d.ReadTagHeader()
+	if err != nil {
+		return Token{}, t.d.wrapError(err, TAG_Compound, nil)
+	}
+	if tagType == TAG_End {
+		return t.end(), nil
+	}
+	t.d.pushName(name)
+	return t.begin(tagType, name, -1, true)
+}
+
+// Value reads the payload of the TokenValue just returned by Next.
+func (t *Tokenizer) Value() (Tag, error) {
+	if t.pending == TAG_End {
+		return nil, errors.New("nbt: Value called without a pending TokenValue")
+	}
+	tagType := t.pending
+	t.pending = TAG_End
+	return t.d.readTag(tagType)
+}
+
+// Skip discards the rest of the innermost open compound or list, including
+// its TokenEnd. Calling Skip straight after a TokenCompound or TokenList
+// skips that whole tag.
+func (t *Tokenizer) Skip() error {
+	if err := t.finish(); err != nil {
+		return err
+	}
+	if len(t.stack) == 0 {
+		return nil
+	}
+
+	f := &t.stack[len(t.stack)-1]
+	if f.list {
+		for ; f.next < f.len; f.next++ {
+			t.d.pushIndex(f.next)
+			if err := t.d.ReadAndDiscardTag(f.elemType); err != nil {
+				return t.d.wrapError(err, f.elemType, nil)
+			}
+			t.d.pop()
+		}
+	} else {
+		// ReadAndDiscardTag reads the remaining entries up to the TAG_End. It
+		// accounts for the compound's depth itself, so step back out first
+		t.d.leave()
+		err := t.d.ReadAndDiscardTag(TAG_Compound)
+		t.d.depth++
+		if err != nil {
+			return t.d.wrapError(err, TAG_Compound, nil)
+		}
+	}
+
+	t.end()
+	return t.finish()
+}
+
+// begin returns the token for a tag whose header has just been read
+func (t *Tokenizer) begin(tagType byte, name string, index int, pushed bool) (Token, error) {
+	tok := Token{Type: tagType, Name: name, Index: index}
+	switch tagType {
+	case TAG_Compound:
+		if err := t.d.enter(); err != nil {
+			return Token{}, t.d.wrapError(err, tagType, nil)
+		}
+		t.stack = append(t.stack, tokenFrame{pushed: pushed})
+		tok.Kind = TokenCompound
+	case TAG_List:
+		if err := t.d.enter(); err != nil {
+			return Token{}, t.d.wrapError(err, tagType, nil)
+		}
+		elemType, n, err := t.d.readListHeader()
+		if err != nil {
+			t.d.leave()
+			return Token{}, t.d.wrapError(err, tagType, nil)
+		}
+		t.stack = append(t.stack, tokenFrame{list: true, elemType: elemType, len: n, pushed: pushed})
+		tok.Kind, tok.ElemType, tok.Len = TokenList, elemType, n
+	case TAG_Byte, TAG_Short, TAG_Int, TAG_Long, TAG_Float, TAG_Double,
+		TAG_Byte_Array, TAG_String, TAG_Int_Array, TAG_Long_Array:
+		tok.Kind = TokenValue
+		t.pending = tagType
+		t.popPath = pushed
+	default:
+		return Token{}, t.d.wrapError(fmt.Errorf("unknown tag type %#02x", tagType), tagType, nil)
+	}
+	return tok, nil
+}
+
+// end closes the innermost open container. Its path segment is left in place
+// until the next call to finish, so that Path still describes it.
+func (t *Tokenizer) end() Token {
+	f := t.stack[len(t.stack)-1]
+	t.stack = t.stack[:len(t.stack)-1]
+	t.d.leave()
+	t.popPath = f.pushed
+
+	tok := Token{Kind: TokenEnd, Type: TAG_Compound, Index: -1}
+	if f.list {
+		tok.Type = TAG_List
+	}
+	return tok
+}
+
+// finish discards any unread payload of the previous token, and removes its
+// path segment
+func (t *Tokenizer) finish() error {
+	if t.pending != TAG_End {
+		tagType := t.pending
+		t.pending = TAG_End
+		if err := t.d.ReadAndDiscardTag(tagType); err != nil {
+			return t.d.wrapError(err, tagType, nil)
+		}
+	}
+	if t.popPath {
+		t.d.pop()
+		t.popPath = false
+	}
+	return nil
+}
@@ -0,0 +1,165 @@
+package nbt
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+)
+
+// encodeTokens encodes each SNBT value as a root tag called "root".
+func encodeTokens(t *testing.T, snbt ...string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	for _, s := range snbt {
+		tag, err := ParseSNBTTag(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := NewEncoder(&buf).Encode("root", tag); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return buf.Bytes()
+}
+
+func TestTokenizer(t *testing.T) {
+	in := encodeTokens(t, `{a: 1b, l: [{x: "s"}, {}], e: [], n: {}, i: [I; 1, 2]}`)
+	tests := []struct {
+		tok   Token
+		path  string
+		depth int
+		value Tag // read with Value if set, otherwise skipped by Next
+	}{
+		{Token{Kind: TokenCompound, Type: TAG_Compound, Name: "root", Index: -1}, "", 1, nil},
+		{Token{Kind: TokenValue, Type: TAG_Byte, Name: "a", Index: -1}, "a", 1, Byte(1)},
+		{Token{Kind: TokenList, Type: TAG_List, Name: "l", Index: -1, ElemType: TAG_Compound, Len: 2}, "l", 2, nil},
+		{Token{Kind: TokenCompound, Type: TAG_Compound, Index: 0}, "l[0]", 3, nil},
+		{Token{Kind: TokenValue, Type: TAG_String, Name: "x", Index: -1}, "l[0].x", 3, nil},
+		{Token{Kind: TokenEnd, Type: TAG_Compound, Index: -1}, "l[0]", 2, nil},
+		{Token{Kind: TokenCompound, Type: TAG_Compound, Index: 1}, "l[1]", 3, nil},
+		{Token{Kind: TokenEnd, Type: TAG_Compound, Index: -1}, "l[1]", 2, nil},
+		{Token{Kind: TokenEnd, Type: TAG_List, Index: -1}, "l", 1, nil},
+		{Token{Kind: TokenList, Type: TAG_List, Name: "e", Index: -1, ElemType: TAG_End}, "e", 2, nil},
+		{Token{Kind: TokenEnd, Type: TAG_List, Index: -1}, "e", 1, nil},
+		{Token{Kind: TokenCompound, Type: TAG_Compound, Name: "n", Index: -1}, "n", 2, nil},
+		{Token{Kind: TokenEnd, Type: TAG_Compound, Index: -1}, "n", 1, nil},
+		{Token{Kind: TokenValue, Type: TAG_Int_Array, Name: "i", Index: -1}, "i", 1, IntArray{1, 2}},
+		{Token{Kind: TokenEnd, Type: TAG_Compound, Index: -1}, "", 0, nil},
+	}
+
+	tz := NewTokenizer(NewDecoder(bytes.NewReader(in)))
+	for i, tt := range tests {
+		tok, err := tz.Next()
+		if err != nil {
+			t.Fatalf("token %d: %v", i, err)
+		}
+		if tok != tt.tok {
+			t.Errorf("token %d = %+v, want %+v", i, tok, tt.tok)
+		}
+		if tz.Path() != tt.path || tz.Depth() != tt.depth {
+			t.Errorf("token %d: path %q at depth %d, want %q at %d", i, tz.Path(), tz.Depth(), tt.path, tt.depth)
+		}
+		if tt.value != nil {
+			v, err := tz.Value()
+			if err != nil || !tagsEqual(v, tt.value) {
+				t.Errorf("token %d: Value() = %v, %v, want %v", i, v, err, tt.value)
+			}
+		}
+	}
+	if tok, err := tz.Next(); err != io.EOF {
+		t.Errorf("Next at the end of the stream = %+v, %v, want io.EOF", tok, err)
+	}
+}
+
+func TestTokenizerSkip(t *testing.T) {
+	in := encodeTokens(t,
+		`{a: [{x: 1}, {y: [2, 3]}], b: {c: 1b, d: {e: 2b}}, f: "after"}`,
+		`{g: 4L}`,
+	)
+	tz := NewTokenizer(NewDecoder(bytes.NewReader(in)))
+	next := func(want TokenKind, name string) {
+		t.Helper()
+		tok, err := tz.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tok.Kind != want || tok.Name != name {
+			t.Fatalf("got %s %q at %s, want %s %q", tok.Kind, tok.Name, tz.Path(), want, name)
+		}
+	}
+	skip := func(depth int) {
+		t.Helper()
+		if err := tz.Skip(); err != nil {
+			t.Fatal(err)
+		}
+		if tz.Depth() != depth {
+			t.Fatalf("depth %d after Skip, want %d", tz.Depth(), depth)
+		}
+	}
+
+	next(TokenCompound, "root")
+	// skipping straight after a list starts skips the whole list
+	next(TokenList, "a")
+	skip(1)
+	// skipping part way through a compound skips the rest of it
+	next(TokenCompound, "b")
+	next(TokenValue, "c")
+	skip(1)
+	next(TokenValue, "f")
+	if v, err := tz.Value(); err != nil || v != String("after") {
+		t.Errorf("Value() after skipping = %v, %v", v, err)
+	}
+	skip(0)
+
+	// the next root tag follows on
+	next(TokenCompound, "root")
+	next(TokenValue, "g")
+	if tz.Path() != "g" {
+		t.Errorf("path %q in the second root tag, want %q", tz.Path(), "g")
+	}
+	skip(0)
+	if _, err := tz.Next(); err != io.EOF {
+		t.Errorf("Next at the end of the stream: %v, want io.EOF", err)
+	}
+	if err := tz.Skip(); err != nil {
+		t.Errorf("Skip at the end of the stream: %v", err)
+	}
+}
+
+func TestTokenizerErrors(t *testing.T) {
+	in := encodeTokens(t, `{a: [{b: "long string"}]}`)
+
+	tz := NewTokenizer(NewDecoder(bytes.NewReader(in)))
+	if _, err := tz.Value(); err == nil {
+		t.Errorf("Value without a pending TokenValue succeeded")
+	}
+
+	tests := []struct {
+		name   string
+		in     []byte
+		limits Limits
+		path   string
+		err    error
+	}{
+		{"truncated", in[:len(in)-6], DefaultLimits, "a[0].b", io.ErrUnexpectedEOF},
+		{"depth", in, Limits{MaxDepth: 2}, "a[0]", ErrMaxDepth},
+		{"string length", in, Limits{MaxStringLen: 4}, "a[0].b", ErrMaxStringLen},
+	}
+	for _, tt := range tests {
+		d := NewDecoder(bytes.NewReader(tt.in))
+		d.SetLimits(tt.limits)
+		tz := NewTokenizer(d)
+		var err error
+		for err == nil {
+			_, err = tz.Next()
+		}
+
+		var derr *DecodeError
+		if !errors.As(err, &derr) {
+			t.Errorf("%s: got %v, want a *DecodeError", tt.name, err)
+		} else if derr.Path != tt.path || !errors.Is(err, tt.err) {
+			t.Errorf("%s: got %v at %s, want %v at %s", tt.name, derr.Err, derr.Path, tt.err, tt.path)
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package nbt
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+// variant.go
+// support for the different binary layouts of NBT used across editions
+
+// Variant selects the binary layout used to read or write NBT.
+type Variant byte
+
+const (
+	// Java Edition NBT: big-endian, used by level.dat, region files and the
+	// Java protocol. This is the default.
+	JavaEdition Variant = iota
+	// Bedrock Edition NBT: little-endian, used by Bedrock level.dat files and
+	// LevelDB values.
+	BedrockEdition
+	// Bedrock "network" NBT: little-endian, with TAG_Int, TAG_Long, array
+	// lengths and string lengths stored as varints. Used by the Bedrock
+	// protocol.
+	BedrockNetwork
+)
+
+func (v Variant) byteOrder() binary.ByteOrder {
+	if v == JavaEdition {
+		return binary.BigEndian
+	}
+	return binary.LittleEndian
+}
+
+var errVarintOverflow = errors.New("nbt: varint overflows its type")
+
+// readUvarint reads an unsigned LEB128 varint of at most maxBits bits
+func readUvarint(r io.ByteReader, maxBits int) (uint64, error) {
+	var v uint64
+	for shift := 0; shift < maxBits; shift += 7 {
+		b, err := r.ReadByte()
+		if err != nil {
+			if err == io.EOF && shift > 0 {
+				err = io.ErrUnexpectedEOF
+			}
+			return 0, err
+		}
+		v |= uint64(b&0x7f) << shift
+		if b&0x80 == 0 {
+			return v, nil
+		}
+	}
+	return 0, errVarintOverflow
+}
+
+func appendUvarint(buf []byte, v uint64) []byte {
+	for v >= 0x80 {
+		buf = append(buf, byte(v)|0x80)
+		v >>= 7
+	}
+	return append(buf, byte(v))
+}
+
+// zigzag encoding maps signed integers onto unsigned ones so that small
+// negative numbers stay small when written as varints
+func zigzag32(v int32) uint32   { return uint32(v<<1) ^ uint32(v>>31) }
+func unzigzag32(v uint32) int32 { return int32(v>>1) ^ -int32(v&1) }
+func zigzag64(v int64) uint64   { return uint64(v<<1) ^ uint64(v>>63) }
+func unzigzag64(v uint64) int64 { return int64(v>>1) ^ -int64(v&1) }
+
+// BedrockHeader is the 8 byte header that precedes the NBT payload of a
+// Bedrock Edition level.dat file.
+type BedrockHeader struct {
+	StorageVersion int32
+	// length of the NBT payload in bytes
+	Length int32
+}
+
+// ReadBedrockHeader reads the header of a Bedrock Edition level.dat file. The
+// remainder of the file can then be read with a decoder using BedrockEdition.
+func ReadBedrockHeader(r io.Reader) (BedrockHeader, error) {
+	var buf [8]byte
+	if _, err := io.ReadFull(r, buf[:]); err != nil {
+		return BedrockHeader{}, err
+	}
+	return BedrockHeader{
+		StorageVersion: int32(binary.LittleEndian.Uint32(buf[0:4])),
+		Length:         int32(binary.LittleEndian.Uint32(buf[4:8])),
+	}, nil
+}
+
+// WriteBedrockHeader writes the header of a Bedrock Edition level.dat file.
+func WriteBedrockHeader(w io.Writer, h BedrockHeader) error {
+	var buf [8]byte
+	binary.LittleEndian.PutUint32(buf[0:4], uint32(h.StorageVersion))
+	binary.LittleEndian.PutUint32(buf[4:8], uint32(h.Length))
+	_, err := w.Write(buf[:])
+	return err
+}
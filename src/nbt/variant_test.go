@@ -0,0 +1,172 @@
+package nbt
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"reflect"
+	"testing"
+)
+
+func TestVariants(t *testing.T) {
+	type ints struct {
+		I int32 `nbt:"i"`
+	}
+	type str struct {
+		S string `nbt:"s"`
+	}
+	tests := []struct {
+		variant  Variant
+		nameless bool
+		name     string
+		v        any
+		want     []byte
+	}{
+		{JavaEdition, false, "", ints{-2}, []byte{TAG_Compound, 0, 0, TAG_Int, 0, 1, 'i', 0xff, 0xff, 0xff, 0xfe, TAG_End}},
+		{JavaEdition, true, "", ints{-2}, []byte{TAG_Compound, TAG_Int, 0, 1, 'i', 0xff, 0xff, 0xff, 0xfe, TAG_End}},
+		{BedrockEdition, false, "r", ints{-2}, []byte{TAG_Compound, 1, 0, 'r', TAG_Int, 1, 0, 'i', 0xfe, 0xff, 0xff, 0xff, TAG_End}},
+		{BedrockNetwork, false, "r", ints{-2}, []byte{TAG_Compound, 1, 'r', TAG_Int, 1, 'i', 3, TAG_End}},
+		{BedrockNetwork, true, "", ints{-2}, []byte{TAG_Compound, TAG_Int, 1, 'i', 3, TAG_End}},
+
+		// only TAG_Int, TAG_Long and lengths are varints
+		{BedrockEdition, false, "", struct {
+			S int16 `nbt:"s"`
+		}{0x1234}, []byte{TAG_Compound, 0, 0, TAG_Short, 1, 0, 's', 0x34, 0x12, TAG_End}},
+		{BedrockNetwork, false, "", struct {
+			F float32 `nbt:"f"`
+			D float64 `nbt:"d"`
+		}{1, -2}, []byte{
+			TAG_Compound, 0,
+			TAG_Float, 1, 'f', 0, 0, 0x80, 0x3f,
+			TAG_Double, 1, 'd', 0, 0, 0, 0, 0, 0, 0, 0xc0,
+			TAG_End,
+		}},
+		{BedrockNetwork, false, "", struct {
+			L int64 `nbt:"l"`
+		}{300}, []byte{TAG_Compound, 0, TAG_Long, 1, 'l', 0xd8, 0x04, TAG_End}},
+		{BedrockNetwork, false, "", struct {
+			A []int32 `nbt:"a"`
+		}{[]int32{-1, 300}}, []byte{TAG_Compound, 0, TAG_Int_Array, 1, 'a', 4, 1, 0xd8, 0x04, TAG_End}},
+		{BedrockNetwork, false, "", struct {
+			L []int16 `nbt:"l"`
+		}{[]int16{1}}, []byte{TAG_Compound, 0, TAG_List, 1, 'l', TAG_Short, 2, 1, 0, TAG_End}},
+
+		// Java strings are Modified UTF-8, Bedrock strings plain UTF-8
+		{JavaEdition, false, "", str{"😀"}, []byte{TAG_Compound, 0, 0, TAG_String, 0, 1, 's', 0, 6, 0xed, 0xa0, 0xbd, 0xed, 0xb8, 0x80, TAG_End}},
+		{BedrockEdition, false, "", str{"😀"}, []byte{TAG_Compound, 0, 0, TAG_String, 1, 0, 's', 4, 0, 0xf0, 0x9f, 0x98, 0x80, TAG_End}},
+		{BedrockNetwork, false, "", str{"😀"}, []byte{TAG_Compound, 0, TAG_String, 1, 's', 4, 0xf0, 0x9f, 0x98, 0x80, TAG_End}},
+	}
+	for i, tt := range tests {
+		var buf bytes.Buffer
+		e := NewEncoder(&buf)
+		e.SetVariant(tt.variant)
+		e.SetNamelessRoot(tt.nameless)
+		if err := e.Encode(tt.name, tt.v); err != nil {
+			t.Errorf("%d: Encode(%#v): %v", i, tt.v, err)
+		} else if !bytes.Equal(buf.Bytes(), tt.want) {
+			t.Errorf("%d: Encode(%#v) = %x, want %x", i, tt.v, buf.Bytes(), tt.want)
+		}
+
+		d := NewDecoder(bytes.NewReader(tt.want))
+		d.SetVariant(tt.variant)
+		d.SetNamelessRoot(tt.nameless)
+		out := reflect.New(reflect.TypeOf(tt.v))
+		name, err := d.Decode(out.Interface())
+		if err != nil {
+			t.Errorf("%d: Decode(%x): %v", i, tt.want, err)
+			continue
+		}
+		if name != tt.name {
+			t.Errorf("%d: root name %q, want %q", i, name, tt.name)
+		}
+		if !reflect.DeepEqual(out.Elem().Interface(), tt.v) {
+			t.Errorf("%d: Decode(%x) = %#v, want %#v", i, tt.want, out.Elem().Interface(), tt.v)
+		}
+	}
+}
+
+// every variant reads back what it writes, whatever the tree holds
+func TestVariantRoundTrip(t *testing.T) {
+	tag, err := ParseSNBTTag(`{a: -1, b: -5L, c: [I; -1, 300], d: [L; 7L, -9223372036854775808L],
+		f: 1.5f, g: 2.5d, s: "héllo", l: [{x: 1s}], ba: [B; 1b], e: [], n: {m: 2147483647}}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, v := range []Variant{JavaEdition, BedrockEdition, BedrockNetwork} {
+		for _, nameless := range []bool{false, true} {
+			var buf bytes.Buffer
+			e := NewEncoder(&buf)
+			e.SetVariant(v)
+			e.SetNamelessRoot(nameless)
+			if err := e.Encode("", tag); err != nil {
+				t.Fatal(err)
+			}
+			d := NewDecoder(&buf)
+			d.SetVariant(v)
+			d.SetNamelessRoot(nameless)
+			var out Tag
+			if _, err := d.Decode(&out); err != nil {
+				t.Errorf("variant %d, nameless %v: %v", v, nameless, err)
+			} else if !tagsEqual(out, tag) {
+				t.Errorf("variant %d, nameless %v: %s", v, nameless, Diff(tag, out))
+			}
+		}
+	}
+}
+
+func TestVarintErrors(t *testing.T) {
+	tests := []struct {
+		in   []byte
+		want error
+	}{
+		// a TAG_Int varint that's longer than 5 bytes
+		{[]byte{TAG_Compound, 0, TAG_Int, 1, 'i', 0xff, 0xff, 0xff, 0xff, 0xff, 0x01, TAG_End}, errVarintOverflow},
+		// a string length that doesn't fit in an int32
+		{[]byte{TAG_String, 0, 0xff, 0xff, 0xff, 0xff, 0x0f}, errVarintOverflow},
+		{[]byte{TAG_Compound, 0, TAG_Long, 1, 'l', 0x80}, io.ErrUnexpectedEOF},
+	}
+	for _, tt := range tests {
+		d := NewDecoder(bytes.NewReader(tt.in))
+		d.SetVariant(BedrockNetwork)
+		var out Tag
+		if _, err := d.Decode(&out); !errors.Is(err, tt.want) {
+			t.Errorf("Decode(%x) = %v, want %v", tt.in, err, tt.want)
+		}
+	}
+}
+
+func TestBedrockHeader(t *testing.T) {
+	var payload bytes.Buffer
+	e := NewEncoder(&payload)
+	e.SetVariant(BedrockEdition)
+	if err := e.Encode("", map[string]any{"LevelName": "My World"}); err != nil {
+		t.Fatal(err)
+	}
+
+	var file bytes.Buffer
+	h := BedrockHeader{StorageVersion: 10, Length: int32(payload.Len())}
+	if err := WriteBedrockHeader(&file, h); err != nil {
+		t.Fatal(err)
+	}
+	if want := []byte{10, 0, 0, 0, byte(payload.Len()), 0, 0, 0}; !bytes.Equal(file.Bytes(), want) {
+		t.Errorf("header written as %x, want %x", file.Bytes(), want)
+	}
+	file.Write(payload.Bytes())
+
+	got, err := ReadBedrockHeader(&file)
+	if err != nil || got != h {
+		t.Fatalf("ReadBedrockHeader = %+v, %v, want %+v", got, err, h)
+	}
+	d := NewDecoder(&file)
+	d.SetVariant(BedrockEdition)
+	var level struct {
+		LevelName string
+	}
+	if _, err := d.Decode(&level); err != nil || level.LevelName != "My World" {
+		t.Errorf("decoding after the header gave %+v, %v", level, err)
+	}
+
+	if _, err := ReadBedrockHeader(bytes.NewReader([]byte{10, 0, 0})); err != io.ErrUnexpectedEOF {
+		t.Errorf("ReadBedrockHeader of a short header: %v, want %v", err, io.ErrUnexpectedEOF)
+	}
+}
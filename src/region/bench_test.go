@@ -0,0 +1,56 @@
+package region
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"testing"
+)
+
+// benchRegion builds a region file in which all 1024 chunks are present. To
+// keep the file small, every location table entry points at the same chunk,
+// the one the nbt benchmarks decode.
+func benchRegion(b *testing.B) []byte {
+	compressed, err := os.ReadFile("../nbt/testdata/chunk-1.18.1.nbt.zlib")
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	// location and timestamp tables, followed by the chunk in sector 2
+	file := make([]byte, 8192)
+	sectors := (5 + len(compressed) + 4095) / 4096
+	for i := 0; i < 1024; i++ {
+		binary.BigEndian.PutUint32(file[i*4:], 2<<8|uint32(sectors))
+	}
+	file = binary.BigEndian.AppendUint32(file, uint32(len(compressed)+1))
+	file = append(file, 2)
+	file = append(file, compressed...)
+	file = append(file, make([]byte, 8192+sectors*4096-len(file))...)
+	return file
+}
+
+func BenchmarkNewRegion(b *testing.B) {
+	file := benchRegion(b)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := NewRegion(bytes.NewReader(file)); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkReaderReadChunk(b *testing.B) {
+	file := benchRegion(b)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		r, err := NewReader(bytes.NewReader(file))
+		if err != nil {
+			b.Fatal(err)
+		}
+		if _, err := r.ReadChunk(0, 0); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
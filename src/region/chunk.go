@@ -5,22 +5,51 @@ import (
 )
 
 type Chunk struct {
-	Loaded      bool
+	Loaded      bool      `nbt:"-"`
 	DataVersion int       `nbt:"DataVersion"`
 	XPos        int32     `nbt:"xPos"`
 	ZPos        int32     `nbt:"zPos"`
 	YPos        int32     `nbt:"yPos"`
 	Status      string    `nbt:"Status"`
 	LastUpdate  int64     `nbt:"LastUpdate"`
-	Sections    []Section `nbt:"sections"`
+	Sections    []Section `nbt:"sections,alias=Sections"`
+
+	// Before 1.18 (DataVersion 2860), everything but DataVersion was nested in
+	// a Level compound. When a Chunk is read from a region file, the contents
+	// of Level are copied into the fields above.
+	Level *Chunk `nbt:"Level"`
 }
 
 type Section struct {
 	Y           int8                 `nbt:"Y"`
 	BlockStates Palette[PaletteData] `nbt:"block_states"`
-	Biomes      Palette[string]      `nbt:"biomes"`
+	// Biomes are only stored per section since 1.18. Older chunks have a
+	// single array of numeric biome ids for the whole chunk, which isn't read.
+	Biomes Palette[string] `nbt:"biomes"`
 	// BlockLight  [2048]byte   `nbt:"BlockLight"`
 	// SkyLight    [2048]byte   `nbt:"SkyLight"`
+
+	// Before 1.18, the block palette and its indices were stored directly in
+	// the section. When a Chunk is read from a region file, they're copied
+	// into BlockStates.
+	LegacyPalette     []PaletteData `nbt:"Palette"`
+	LegacyBlockStates []int64       `nbt:"BlockStates"`
+}
+
+// upgrade copies the contents of a chunk saved before 1.18 into the fields
+// used since then.
+func (c *Chunk) upgrade() {
+	if l := c.Level; l != nil {
+		c.XPos, c.ZPos, c.YPos = l.XPos, l.ZPos, l.YPos
+		c.Status, c.LastUpdate = l.Status, l.LastUpdate
+		c.Sections = l.Sections
+	}
+	for i := range c.Sections {
+		s := &c.Sections[i]
+		if s.BlockStates.Palette == nil && s.LegacyPalette != nil {
+			s.BlockStates = Palette[PaletteData]{Palette: s.LegacyPalette, Data: s.LegacyBlockStates}
+		}
+	}
 }
 
 type Palette[T any] struct {
@@ -0,0 +1,25 @@
+package region
+
+import "os"
+
+// Compact rewrites the region file at path with its chunks packed together,
+// keeping their timestamps, and returns the number of bytes reclaimed.
+func Compact(path string) (int64, error) {
+	before, err := os.Stat(path)
+	if err != nil {
+		return 0, err
+	}
+	w, err := NewWriter(path)
+	if err != nil {
+		return 0, err
+	}
+	w.Compact()
+	if err := w.Close(); err != nil {
+		return 0, err
+	}
+	after, err := os.Stat(path)
+	if err != nil {
+		return 0, err
+	}
+	return before.Size() - after.Size(), nil
+}
@@ -0,0 +1,81 @@
+package region
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// ExternalChunkOpener opens the data of a chunk that's too large for its
+// region file, and is stored on its own instead. x and z are the chunk's
+// coordinates within the region (0-31).
+type ExternalChunkOpener func(x, z int) (io.ReadCloser, error)
+
+// ErrExternalChunk is returned when reading a chunk stored in an external
+// file, without an ExternalChunkOpener to find it.
+var ErrExternalChunk = errors.New("region: chunk is stored in an external file")
+
+// ExternalChunks returns an opener for the external chunks of the region file
+// at path, which Minecraft stores as c.<x>.<z>.mcc (in world chunk
+// coordinates) in the same directory. path must be named r.<x>.<z>.mca, as
+// region files are, to tell where the region is in the world.
+func ExternalChunks(path string) (ExternalChunkOpener, error) {
+	if _, _, err := parseRegionName(filepath.Base(path)); err != nil {
+		return nil, err
+	}
+	return func(x, z int) (io.ReadCloser, error) {
+		name, err := externalChunkPath(path, x, z)
+		if err != nil {
+			return nil, err
+		}
+		return os.Open(name)
+	}, nil
+}
+
+// externalChunkPath returns the path of the external file for the chunk at
+// x, z within the region file at path.
+func externalChunkPath(path string, x, z int) (string, error) {
+	regionX, regionZ, err := parseRegionName(filepath.Base(path))
+	if err != nil {
+		return "", err
+	}
+	name := fmt.Sprintf("c.%d.%d.mcc", regionX*32+x&31, regionZ*32+z&31)
+	return filepath.Join(filepath.Dir(path), name), nil
+}
+
+// parseRegionName returns the region coordinates in a region file name, eg.
+// r.-1.2.mca.
+func parseRegionName(name string) (x, z int, err error) {
+	var ext string
+	if n, _ := fmt.Sscanf(name, "r.%d.%d.%s", &x, &z, &ext); n != 3 || (ext != "mca" && ext != "mcr") {
+		return 0, 0, fmt.Errorf("region: %q isn't named like a region file (r.<x>.<z>.mca)", name)
+	}
+	return x, z, nil
+}
+
+// externalChunksFor returns an opener for the external chunks of r, if it's a
+// region file with a known path.
+func externalChunksFor(r io.Reader) ExternalChunkOpener {
+	f, ok := r.(interface{ Name() string })
+	if !ok {
+		return nil
+	}
+	external, err := ExternalChunks(f.Name())
+	if err != nil {
+		return nil
+	}
+	return external
+}
+
+// regionOf returns the coordinates of r's region, if it's a region file with a
+// known path.
+func regionOf(r io.Reader) (x, z int, ok bool) {
+	f, ok := r.(interface{ Name() string })
+	if !ok {
+		return 0, 0, false
+	}
+	x, z, err := parseRegionName(filepath.Base(f.Name()))
+	return x, z, err == nil
+}
@@ -0,0 +1,144 @@
+package region
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"iter"
+	"time"
+
+	"github.com/faideww/mc-iso/src/nbt"
+)
+
+// A Reader reads chunks from a region file on demand. Only the location and
+// timestamp tables are read when it's created.
+//
+// Chunk coordinates passed to a Reader's methods may be either within the
+// region (0-31) or in the world. A Reader is not safe for concurrent use.
+type Reader struct {
+	r        io.ReadSeeker
+	external ExternalChunkOpener
+	limits   nbt.Limits
+
+	// location of each chunk in the file
+	locTable [1024]ChunkLocation
+
+	// time of last modification for each chunk
+	timestampTable [1024]uint32
+}
+
+// NewReader reads the header of a region file, starting at the current
+// position of r. Chunks stored in external .mcc files are found as in
+// NewRegion.
+func NewReader(r io.ReadSeeker) (*Reader, error) {
+	return NewReaderExternal(r, externalChunksFor(r))
+}
+
+// NewReaderExternal is like NewReader, but opens the data of chunks stored in
+// external files with external, which may be nil.
+func NewReaderExternal(r io.ReadSeeker, external ExternalChunkOpener) (*Reader, error) {
+	reader := &Reader{r: r, external: external, limits: nbt.DefaultLimits}
+
+	// First 4096 bytes are the location table
+	buf := make([]byte, 4096)
+	n, err := io.ReadFull(r, buf)
+	if err != nil {
+		return nil, err
+	}
+	if n != 4096 {
+		return nil, fmt.Errorf("failed to read location table; only read %d bytes (expected 4096)", n)
+	}
+
+	for i := 0; i < 1024; i++ {
+		offset := i * 4
+		reader.locTable[i].offset = uint32(buf[offset])<<16 | uint32(buf[offset+1])<<8 | uint32(buf[offset+2])
+		reader.locTable[i].size = buf[offset+3]
+	}
+
+	// Next 4096 bytes are the timestamp table
+	if err := binary.Read(r, binary.BigEndian, &reader.timestampTable); err != nil {
+		return nil, err
+	}
+	return reader, nil
+}
+
+// SetLimits replaces the limits chunks are decoded with, which are
+// nbt.DefaultLimits unless set. Consider nbt.UntrustedLimits for worlds from
+// untrusted sources.
+func (r *Reader) SetLimits(l nbt.Limits) {
+	r.limits = l
+}
+
+// chunkIndex returns the index of the chunk at x, z in the region's tables.
+func chunkIndex(x, z int) int {
+	return (z&31)*32 + x&31
+}
+
+// HasChunk reports whether the chunk at x, z has been generated.
+func (r *Reader) HasChunk(x, z int) bool {
+	loc := r.locTable[chunkIndex(x, z)]
+	// if both offset and size are 0, there is no chunk in this location
+	return loc.offset != 0 || loc.size != 0
+}
+
+// ChunkTimestamp returns the time the chunk at x, z was last saved, or the
+// zero time if it hasn't been generated.
+func (r *Reader) ChunkTimestamp(x, z int) time.Time {
+	ts := r.timestampTable[chunkIndex(x, z)]
+	if ts == 0 && !r.HasChunk(x, z) {
+		return time.Time{}
+	}
+	return time.Unix(int64(ts), 0)
+}
+
+// Chunks returns an iterator over the coordinates (within the region) of the
+// chunks that have been generated, in the order they're stored in the
+// location table.
+func (r *Reader) Chunks() iter.Seq2[int, int] {
+	return func(yield func(x, z int) bool) {
+		for i := range r.locTable {
+			x, z := i%32, i/32
+			if r.HasChunk(x, z) && !yield(x, z) {
+				return
+			}
+		}
+	}
+}
+
+// ReadChunk decodes the chunk at x, z. It returns ErrChunkNotFound if the
+// chunk hasn't been generated.
+func (r *Reader) ReadChunk(x, z int) (*Chunk, error) {
+	var c Chunk
+	if err := r.DecodeChunk(x, z, &c); err != nil {
+		return nil, err
+	}
+	c.Loaded = true
+	return &c, nil
+}
+
+// DecodeChunk decodes the chunk at x, z into v, which may be of any type
+// accepted by nbt.NBTDecoder.Decode.
+func (r *Reader) DecodeChunk(x, z int, v any) error {
+	if !r.HasChunk(x, z) {
+		return ErrChunkNotFound
+	}
+	i := chunkIndex(x, z)
+	offset := int64(r.locTable[i].offset) * 4096
+	if err := readChunk(r.r, offset, x&31, z&31, r.external, r.limits, v); err != nil {
+		return newChunkError(r.r, x, z, offset, err)
+	}
+	return nil
+}
+
+// ReadRegion decodes every chunk the Reader has, as NewRegion does.
+func (r *Reader) ReadRegion() (Region, error) {
+	region := Region{locTable: r.locTable, timestampTable: r.timestampTable}
+	for x, z := range r.Chunks() {
+		c, err := r.ReadChunk(x, z)
+		if err != nil {
+			return region, err
+		}
+		region.Chunks[chunkIndex(x, z)] = *c
+	}
+	return region, nil
+}
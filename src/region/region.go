@@ -1,9 +1,8 @@
 package region
 
 import (
-	"compress/gzip"
-	"compress/zlib"
 	"encoding/binary"
+	"errors"
 	"fmt"
 	"io"
 
@@ -28,86 +27,198 @@ type Region struct {
 	Chunks [1024]Chunk
 }
 
+// ChunkError records a failure to read a single chunk from a region file.
+type ChunkError struct {
+	// index of the chunk in the region's location table
+	Index int
+	// coordinates of the chunk within the region (0-31)
+	X, Z int
+	// coordinates of the region in the world, if RegionKnown is set. They're
+	// known when the region file has a Name, such as an *os.File, in the form
+	// r.<x>.<z>.mca.
+	RegionX, RegionZ int
+	RegionKnown      bool
+	// position of the chunk's data in the region file
+	Offset int64
+	Err    error
+}
+
+// newChunkError records a failure to read the chunk at x, z from the region
+// file r.
+func newChunkError(r io.Reader, x, z int, offset int64, err error) *ChunkError {
+	e := &ChunkError{Index: chunkIndex(x, z), X: x & 31, Z: z & 31, Offset: offset, Err: err}
+	e.RegionX, e.RegionZ, e.RegionKnown = regionOf(r)
+	return e
+}
+
+func (e *ChunkError) Error() string {
+	if e.RegionKnown {
+		return fmt.Sprintf("region: failed to read chunk %d (%d, %d) of region (%d, %d) at offset %d: %v",
+			e.Index, e.X, e.Z, e.RegionX, e.RegionZ, e.Offset, e.Err)
+	}
+	return fmt.Sprintf("region: failed to read chunk %d (%d, %d) at offset %d: %v", e.Index, e.X, e.Z, e.Offset, e.Err)
+}
+
+// WorldPos returns the chunk's coordinates in the world, if the region's
+// coordinates are known.
+func (e *ChunkError) WorldPos() (x, z int, ok bool) {
+	return e.RegionX*32 + e.X, e.RegionZ*32 + e.Z, e.RegionKnown
+}
+
+func (e *ChunkError) Unwrap() error {
+	return e.Err
+}
+
+// ErrChunkNotFound is returned by ReadChunk for chunks that haven't been
+// generated.
+var ErrChunkNotFound = errors.New("region: chunk not found")
+
+// NewRegion reads every chunk of a region file. If r is an *os.File (or has a
+// Name method giving the file's path), chunks stored in external .mcc files
+// next to it are read too; otherwise see NewRegionExternal. To decode only the
+// chunks that are needed, use a Reader.
 func NewRegion(r io.ReadSeeker) (Region, error) {
-	var region Region
+	return NewRegionExternal(r, externalChunksFor(r))
+}
 
-	// First 4096 bytes are the location table
-	buf := make([]byte, 4096)
-	n, err := io.ReadFull(r, buf)
+// NewRegionExternal reads every chunk of a region file, opening the data of
+// chunks stored in external files with external, which may be nil. Chunks are
+// decoded with nbt.DefaultLimits; to use other limits, call SetLimits on a
+// Reader and then ReadRegion.
+func NewRegionExternal(r io.ReadSeeker, external ExternalChunkOpener) (Region, error) {
+	reader, err := NewReaderExternal(r, external)
 	if err != nil {
-		return region, err
-	}
-	if n != 4096 {
-		return region, fmt.Errorf("failed to read location table; only read %d bytes (expected 4096)", n)
+		return Region{}, err
 	}
+	return reader.ReadRegion()
+}
 
-	for i := 0; i < 1024; i++ {
-		offset := i * 4
+// ReadChunk decodes a single chunk from a region file into v, without reading
+// the rest of the region. x and z are chunk coordinates, either within the
+// region (0-31) or in the world. External chunks are read as in NewRegion, and
+// the chunk is decoded with nbt.DefaultLimits.
+func ReadChunk(r io.ReadSeeker, x, z int, v any) error {
+	i := chunkIndex(x, z)
 
-		region.locTable[i].offset = uint32(buf[offset])<<16 | uint32(buf[offset+1])<<8 | uint32(buf[offset+2])
-		// binary.BigEndian.Uint32(buf[offset : offset+2])
-		region.locTable[i].size = buf[offset+3]
+	var loc [4]byte
+	if _, err := r.Seek(int64(i)*4, io.SeekStart); err != nil {
+		return err
+	}
+	if _, err := io.ReadFull(r, loc[:]); err != nil {
+		return err
+	}
+	sector := uint32(loc[0])<<16 | uint32(loc[1])<<8 | uint32(loc[2])
+	if sector == 0 && loc[3] == 0 {
+		return ErrChunkNotFound
+	}
 
-		// fmt.Printf("chunk %d is at loc %d (size %d)\n", i, region.locTable[i].offset, region.locTable[i].size)
+	offset := int64(sector) * 4096
+	if err := readChunk(r, offset, x&31, z&31, externalChunksFor(r), nbt.DefaultLimits, v); err != nil {
+		return newChunkError(r, x, z, offset, err)
 	}
+	return nil
+}
 
-	// Next 4096 bytes are the timestamp table
-	err = binary.Read(r, binary.BigEndian, &region.timestampTable)
-	if err != nil {
-		return region, err
+// Compression types used in chunk headers. Other than customCompression, each
+// refers to the nbt.Compressor registered under its name.
+var compressionNames = map[byte]string{
+	1: "gzip",
+	2: "zlib",
+	3: "none",
+	4: "lz4",
+}
+
+// customCompression is followed in the chunk header by the namespaced id of
+// the compression format (a u16 length and the id), as registered with
+// nbt.RegisterCompressor.
+const customCompression = 127
+
+// externalCompression is set on the compression type of chunks whose data is
+// stored in an external file rather than in the region file.
+const externalCompression = 0x80
+
+// readChunk reads the chunk whose data begins at offset, and decodes it into v.
+// x and z are the chunk's coordinates within the region, used to find the
+// chunk's data if it's stored in an external file.
+func readChunk(r io.ReadSeeker, offset int64, x, z int, external ExternalChunkOpener, limits nbt.Limits, v any) error {
+	// seek to the start of the chunk
+	if _, err := r.Seek(offset, io.SeekStart); err != nil {
+		return err
 	}
 
-	// Finally, the chunk payload.
 	// Each chunk begins with a 5-byte header:
-	// - 4 bytes describing the (unpadded) length of the chunk data in bytes
-	// - 1 byte describing the compression type (practically, these are 1=gzip, 2=zlib, 3=uncompressed)
+	// - 4 bytes describing the (unpadded) length of the chunk data in bytes,
+	//   including the compression byte
+	// - 1 byte describing the compression type (see compressionNames), with
+	//   the externalCompression bit set if the data is in a .mcc file
 	// Following the header is an NBT TAG_Compound, compressed as described in the header
-	for i := 0; i < 1024; i++ {
-		if region.locTable[i].offset == 0 && region.locTable[i].size == 0 {
-			// if both offset and size are 0, there is no chunk in this location
-			continue
-		}
-
-		// seek to the start of the chunk
-		r.Seek(int64(region.locTable[i].offset*4096), io.SeekStart)
+	var chunkLen int32
+	var compression byte
 
-		var chunkLen int32
-		var compression byte
+	if err := binary.Read(r, binary.BigEndian, &chunkLen); err != nil {
+		return err
+	}
+	if err := binary.Read(r, binary.BigEndian, &compression); err != nil {
+		return err
+	}
+	if chunkLen < 1 {
+		return fmt.Errorf("invalid chunk length %d", chunkLen)
+	}
+	var data io.Reader = io.LimitReader(r, int64(chunkLen)-1)
 
-		if err := binary.Read(r, binary.BigEndian, &chunkLen); err != nil {
-			return region, err
+	c, err := chunkCompressor(data, compression&^externalCompression)
+	if err != nil {
+		return err
+	}
+	if compression&externalCompression != 0 {
+		if external == nil {
+			return ErrExternalChunk
 		}
-		if err := binary.Read(r, binary.BigEndian, &compression); err != nil {
-			return region, err
+		f, err := external(x, z)
+		if err != nil {
+			return fmt.Errorf("opening external chunk: %w", err)
 		}
+		defer f.Close()
+		data = f
+	}
+	decompressed, err := c.NewReader(data)
+	if err != nil {
+		return err
+	}
 
-		var decompressed io.Reader
-		switch compression {
-		case 0x01:
-			decompressed, err = gzip.NewReader(r)
-			if err != nil {
-				return region, err
-			}
-		case 0x02:
-			decompressed, err = zlib.NewReader(r)
-			if err != nil {
-				return region, err
-			}
-		case 0x03:
-			decompressed = r
-		default:
-			return region, fmt.Errorf("unrecognized compression scheme %#02x", compression)
-		}
+	d := nbt.NewDecoder(decompressed)
+	d.SetLimits(limits)
+	if _, err := d.Decode(v); err != nil {
+		return err
+	}
+	if c, ok := v.(*Chunk); ok {
+		c.upgrade()
+	}
+	return nil
+}
 
-		var c Chunk
-		_, err = nbt.NewDecoder(decompressed).Decode(&c)
-		if err != nil {
-			return region, err
+// chunkCompressor returns the compression format of a chunk, reading the id
+// of a custom format from r.
+func chunkCompressor(r io.Reader, compression byte) (nbt.Compressor, error) {
+	name, ok := compressionNames[compression]
+	if compression == customCompression {
+		var nameLen uint16
+		if err := binary.Read(r, binary.BigEndian, &nameLen); err != nil {
+			return nbt.Compressor{}, err
 		}
+		buf := make([]byte, nameLen)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nbt.Compressor{}, err
+		}
+		name, ok = string(buf), true
+	}
+	if !ok {
+		return nbt.Compressor{}, fmt.Errorf("%w: unrecognized compression scheme %#02x", nbt.ErrUnsupportedCompression, compression)
+	}
 
-		c.Loaded = true
-
-		region.Chunks[i] = c
+	c, ok := nbt.LookupCompressor(name)
+	if !ok {
+		return nbt.Compressor{}, fmt.Errorf("%w: %q", nbt.ErrUnsupportedCompression, name)
 	}
-	return region, nil
+	return c, nil
 }
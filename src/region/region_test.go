@@ -0,0 +1,332 @@
+package region
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/faideww/mc-iso/src/nbt"
+)
+
+// singleChunkRegion builds a region file holding only chunk (0, 0), whose
+// header (after the length) is compression followed by data.
+func singleChunkRegion(compression, data []byte) []byte {
+	file := make([]byte, 8192)
+	n := len(compression) + len(data)
+	sectors := (4 + n + 4095) / 4096
+	binary.BigEndian.PutUint32(file, 2<<8|uint32(sectors))
+	file = binary.BigEndian.AppendUint32(file, uint32(n))
+	file = append(file, compression...)
+	file = append(file, data...)
+	return append(file, make([]byte, 8192+sectors*4096-len(file))...)
+}
+
+func TestChunkCompression(t *testing.T) {
+	// the same chunk, uncompressed and in lz4-java's framing
+	raw, err := os.ReadFile("../nbt/testdata/chunk.nbt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	lz4, err := os.ReadFile("../nbt/testdata/chunk.nbt.lz4")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var want Chunk
+	if _, err := nbt.NewDecoder(bytes.NewReader(raw)).Decode(&want); err != nil {
+		t.Fatal(err)
+	}
+
+	// a custom format that stores the data reversed
+	nbt.RegisterCompressor(nbt.Compressor{
+		Name: "test:reversed",
+		NewReader: func(r io.Reader) (io.Reader, error) {
+			b, err := io.ReadAll(r)
+			for i, j := 0, len(b)-1; i < j; i, j = i+1, j-1 {
+				b[i], b[j] = b[j], b[i]
+			}
+			return bytes.NewReader(b), err
+		},
+	})
+	reversed := bytes.Clone(raw)
+	for i, j := 0, len(reversed)-1; i < j; i, j = i+1, j-1 {
+		reversed[i], reversed[j] = reversed[j], reversed[i]
+	}
+	custom := func(name string) []byte {
+		return append(binary.BigEndian.AppendUint16([]byte{127}, uint16(len(name))), name...)
+	}
+
+	for name, file := range map[string][]byte{
+		"uncompressed": singleChunkRegion([]byte{3}, raw),
+		"lz4":          singleChunkRegion([]byte{4}, lz4),
+		"custom":       singleChunkRegion(custom("test:reversed"), reversed),
+	} {
+		var c Chunk
+		if err := ReadChunk(bytes.NewReader(file), 0, 0, &c); err != nil {
+			t.Errorf("%s: %v", name, err)
+		} else if !reflect.DeepEqual(c, want) {
+			t.Errorf("%s: decoded %+v, want %+v", name, c, want)
+		}
+	}
+
+	for name, file := range map[string][]byte{
+		"unknown type":   singleChunkRegion([]byte{5}, raw),
+		"unknown custom": singleChunkRegion(custom("test:missing"), raw),
+	} {
+		var c Chunk
+		err := ReadChunk(bytes.NewReader(file), 0, 0, &c)
+		if !errors.Is(err, nbt.ErrUnsupportedCompression) {
+			t.Errorf("%s: got error %v, want ErrUnsupportedCompression", name, err)
+		}
+	}
+}
+
+func TestExternalChunk(t *testing.T) {
+	raw, err := os.ReadFile("../nbt/testdata/chunk.nbt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var want Chunk
+	if _, err := nbt.NewDecoder(bytes.NewReader(raw)).Decode(&want); err != nil {
+		t.Fatal(err)
+	}
+
+	// chunk (0, 0) of region (-1, 2) is chunk (-32, 64) in the world
+	dir := t.TempDir()
+	file := singleChunkRegion([]byte{externalCompression | 3}, nil)
+	path := filepath.Join(dir, "r.-1.2.mca")
+	if err := os.WriteFile(path, file, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "c.-32.64.mcc"), raw, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var c Chunk
+	if err := ReadChunk(f, 0, 0, &c); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(c, want) {
+		t.Errorf("decoded %+v, want %+v", c, want)
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		t.Fatal(err)
+	}
+	reg, err := NewRegion(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reg.Chunks[0].Loaded || reg.Chunks[0].DataVersion != want.DataVersion {
+		t.Errorf("NewRegion didn't load the external chunk")
+	}
+
+	// without the file's path, the chunk can't be found
+	if err := ReadChunk(bytes.NewReader(file), 0, 0, &c); !errors.Is(err, ErrExternalChunk) {
+		t.Errorf("got error %v, want ErrExternalChunk", err)
+	}
+}
+
+func TestReader(t *testing.T) {
+	raw, err := os.ReadFile("../nbt/testdata/chunk.nbt")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// chunks (1, 0) and (3, 2) share the same data; (3, 2) was saved at t=1000
+	file := singleChunkRegion([]byte{3}, raw)
+	i := chunkIndex(3, 2)
+	copy(file[i*4:], file[:4])
+	copy(file[4:], file[:4])
+	copy(file[:4], make([]byte, 4))
+	binary.BigEndian.PutUint32(file[4096+i*4:], 1000)
+
+	r, err := NewReader(bytes.NewReader(file))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got [][2]int
+	for x, z := range r.Chunks() {
+		got = append(got, [2]int{x, z})
+	}
+	if want := [][2]int{{1, 0}, {3, 2}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Chunks() yielded %v, want %v", got, want)
+	}
+
+	if !r.HasChunk(3, 2) || !r.HasChunk(-29, 34) || r.HasChunk(0, 0) {
+		t.Errorf("HasChunk is wrong")
+	}
+	if ts := r.ChunkTimestamp(3, 2); !ts.Equal(time.Unix(1000, 0)) {
+		t.Errorf("ChunkTimestamp(3, 2) = %v", ts)
+	}
+	if ts := r.ChunkTimestamp(0, 0); !ts.IsZero() {
+		t.Errorf("ChunkTimestamp(0, 0) = %v, want the zero time", ts)
+	}
+
+	c, err := r.ReadChunk(3, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !c.Loaded || c.DataVersion == 0 {
+		t.Errorf("ReadChunk(3, 2) = %+v", c)
+	}
+	if _, err := r.ReadChunk(0, 0); !errors.Is(err, ErrChunkNotFound) {
+		t.Errorf("ReadChunk(0, 0): got error %v, want ErrChunkNotFound", err)
+	}
+
+	reg, err := NewRegion(bytes.NewReader(file))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reg.Chunks[1].Loaded || !reg.Chunks[i].Loaded || reg.Chunks[0].Loaded {
+		t.Errorf("NewRegion loaded the wrong chunks")
+	}
+}
+
+func TestChunkError(t *testing.T) {
+	tag, err := nbt.ParseSNBTTag(`{DataVersion: 3465, sections: [{Y: 0b}, {Y: 1b, block_states: {palette: [{Name: "minecraft:air"}, {Name: 5}]}}]}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var raw bytes.Buffer
+	if err := nbt.NewEncoder(&raw).Encode("", tag); err != nil {
+		t.Fatal(err)
+	}
+	file := singleChunkRegion([]byte{3}, raw.Bytes())
+
+	r, err := NewReader(bytes.NewReader(file))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, regionErr := NewRegion(bytes.NewReader(file))
+	for name, err := range map[string]error{
+		"ReadChunk":        ReadChunk(bytes.NewReader(file), 32, -32, &Chunk{}),
+		"Reader.ReadChunk": r.DecodeChunk(0, 0, &Chunk{}),
+		"NewRegion":        regionErr,
+	} {
+		var cerr *ChunkError
+		var derr *nbt.DecodeError
+		if !errors.As(err, &cerr) || !errors.As(err, &derr) {
+			t.Errorf("%s: got %v, want a *ChunkError wrapping a *nbt.DecodeError", name, err)
+			continue
+		}
+		if cerr.Index != 0 || cerr.X != 0 || cerr.Z != 0 || cerr.Offset != 8192 {
+			t.Errorf("%s: chunk %d (%d, %d) at offset %d, want chunk 0 (0, 0) at 8192", name, cerr.Index, cerr.X, cerr.Z, cerr.Offset)
+		}
+		if cerr.RegionKnown {
+			t.Errorf("%s: region (%d, %d) of a file with no name", name, cerr.RegionX, cerr.RegionZ)
+		}
+		if want := "sections[1].block_states.palette[1].Name"; derr.Path != want {
+			t.Errorf("%s: path %s, want %s", name, derr.Path, want)
+		}
+	}
+
+	// the region's coordinates come from the file's name
+	path := filepath.Join(t.TempDir(), "r.-1.2.mca")
+	if err := os.WriteFile(path, file, 0644); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	r, err = NewReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var cerr *ChunkError
+	if err := r.DecodeChunk(-32, 64, &Chunk{}); !errors.As(err, &cerr) {
+		t.Fatalf("got %v, want a *ChunkError", err)
+	}
+	if x, z, ok := cerr.WorldPos(); !ok || x != -32 || z != 64 || cerr.RegionX != -1 || cerr.RegionZ != 2 {
+		t.Errorf("chunk (%d, %d) of region (%d, %d), want (-32, 64) of (-1, 2)", x, z, cerr.RegionX, cerr.RegionZ)
+	}
+	if !strings.Contains(cerr.Error(), "region (-1, 2)") {
+		t.Errorf("%q doesn't name the region", cerr)
+	}
+}
+
+func TestReaderLimits(t *testing.T) {
+	raw, err := os.ReadFile("../nbt/testdata/chunk.nbt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	file := singleChunkRegion([]byte{3}, raw)
+
+	r, err := NewReader(bytes.NewReader(file))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.ReadChunk(0, 0); err != nil {
+		t.Fatalf("with the default limits: %v", err)
+	}
+	r.SetLimits(nbt.Limits{MaxDepth: 2})
+	if _, err := r.ReadChunk(0, 0); !errors.Is(err, nbt.ErrMaxDepth) {
+		t.Errorf("ReadChunk: got %v, want nbt.ErrMaxDepth", err)
+	}
+	if _, err := r.ReadRegion(); !errors.Is(err, nbt.ErrMaxDepth) {
+		t.Errorf("ReadRegion: got %v, want nbt.ErrMaxDepth", err)
+	}
+
+	r.SetLimits(nbt.UntrustedLimits)
+	reg, err := r.ReadRegion()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reg.Chunks[0].Loaded || reg.Chunks[1].Loaded {
+		t.Errorf("ReadRegion loaded the wrong chunks")
+	}
+}
+
+// a chunk saved before 1.18 nests its contents in Level, and stores each
+// section's palette directly in the section
+func TestReadChunkPre118(t *testing.T) {
+	raw, err := os.ReadFile("../nbt/testdata/chunk-1.17.nbt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := NewReader(bytes.NewReader(singleChunkRegion([]byte{3}, raw)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := r.ReadChunk(0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.DataVersion != 2730 || c.XPos != -1 || c.ZPos != 2 || c.Status != "full" || c.LastUpdate != 1200 {
+		t.Errorf("ReadChunk = %+v", c)
+	}
+	if len(c.Sections) != 3 {
+		t.Fatalf("%d sections, want 3", len(c.Sections))
+	}
+
+	s := c.Sections[1]
+	var names []string
+	for _, p := range s.BlockStates.Palette {
+		names = append(names, p.Name)
+	}
+	if want := []string{"minecraft:bedrock", "minecraft:stone", "minecraft:oak_log"}; s.Y != 0 || !reflect.DeepEqual(names, want) {
+		t.Errorf("section %d has palette %q, want %q", s.Y, names, want)
+	}
+	if s.BlockStates.Palette[2].Properties["axis"] != "y" || len(s.BlockStates.Data) != 4 {
+		t.Errorf("section %d = %+v", s.Y, s.BlockStates)
+	}
+	// a section with only light data has no palette
+	if s := c.Sections[0]; s.Y != -1 || s.BlockStates.Palette != nil {
+		t.Errorf("section %d = %+v", s.Y, s.BlockStates)
+	}
+	if s := c.Sections[2]; len(s.BlockStates.Palette) != 1 || s.BlockStates.Data != nil {
+		t.Errorf("section %d = %+v", s.Y, s.BlockStates)
+	}
+}
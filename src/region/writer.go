@@ -0,0 +1,503 @@
+package region
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"time"
+
+	"github.com/faideww/mc-iso/src/nbt"
+)
+
+const (
+	sectorSize = 4096
+	// maxChunkSectors is the largest chunk the location table can describe.
+	// Larger chunks are stored in external files.
+	maxChunkSectors = 255
+	// maxSectors is the largest sector offset the location table can describe.
+	maxSectors = 1 << 24
+)
+
+// A Writer replaces, adds and deletes chunks in a region file.
+//
+// Changes are kept in memory until Flush or Close, which write a new copy of
+// the region next to it and rename it over the original, so a crash leaves
+// either the old region or the new one (see Flush for external chunks).
+// Chunks that aren't changed keep their place in the file, and changed chunks
+// are written to the first free space that fits them.
+type Writer struct {
+	path string
+	// current contents of the region, or nil if it doesn't exist yet
+	f    *os.File
+	mode fs.FileMode
+
+	locTable       [1024]ChunkLocation
+	timestampTable [1024]uint32
+
+	pending     [1024]*pendingChunk
+	compression string
+	// whether to move every chunk on the next flush
+	compact bool
+}
+
+// pendingChunk is a change to a chunk that hasn't been written yet.
+type pendingChunk struct {
+	deleted bool
+	// compression type, followed by the id of a custom compression format
+	header []byte
+	// compressed contents of the chunk
+	data      []byte
+	timestamp uint32
+}
+
+// NewWriter opens the region file at path for changes, or creates it when
+// the Writer is flushed if it doesn't exist. Chunks are compressed with zlib
+// unless SetCompression is called.
+func NewWriter(path string) (*Writer, error) {
+	w := &Writer{path: path, compression: "zlib"}
+	if err := w.open(); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// open reads the tables of the region file at w.path, if there is one.
+func (w *Writer) open() error {
+	w.f, w.mode = nil, 0644
+	w.locTable, w.timestampTable = [1024]ChunkLocation{}, [1024]uint32{}
+
+	f, err := os.Open(w.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	w.mode = info.Mode().Perm()
+	if info.Size() == 0 {
+		// the game creates empty region files before saving any chunks
+		w.f = f
+		return nil
+	}
+
+	r, err := NewReaderExternal(f, nil)
+	if err != nil {
+		f.Close()
+		return fmt.Errorf("%s: %w", w.path, err)
+	}
+	w.f, w.locTable, w.timestampTable = f, r.locTable, r.timestampTable
+	return nil
+}
+
+// SetCompression sets the compression format of the chunks written after it,
+// which must be one registered with nbt.RegisterCompressor that can be
+// written.
+func (w *Writer) SetCompression(name string) error {
+	c, ok := nbt.LookupCompressor(name)
+	if !ok {
+		return fmt.Errorf("%w: %q", nbt.ErrUnsupportedCompression, name)
+	}
+	if c.NewWriter == nil {
+		return fmt.Errorf("region: %q compression can only be read", name)
+	}
+	w.compression = name
+	return nil
+}
+
+// compressionHeader returns the compression type of a chunk compressed with
+// the named format, followed by the format's id if it's a custom one.
+func compressionHeader(name string) []byte {
+	for compression, n := range compressionNames {
+		if n == name {
+			return []byte{compression}
+		}
+	}
+	header := binary.BigEndian.AppendUint16([]byte{customCompression}, uint16(len(name)))
+	return append(header, name...)
+}
+
+// WriteChunk encodes v as the chunk at x, z, replacing any chunk already
+// there. x and z may be either within the region (0-31) or in the world.
+func (w *Writer) WriteChunk(x, z int, v any) error {
+	c, ok := nbt.LookupCompressor(w.compression)
+	if !ok {
+		return fmt.Errorf("%w: %q", nbt.ErrUnsupportedCompression, w.compression)
+	}
+	var buf bytes.Buffer
+	cw, err := c.NewWriter(&buf)
+	if err != nil {
+		return err
+	}
+	if err := nbt.NewEncoder(cw).Encode("", v); err != nil {
+		return err
+	}
+	if err := cw.Close(); err != nil {
+		return err
+	}
+
+	w.pending[chunkIndex(x, z)] = &pendingChunk{
+		header:    compressionHeader(w.compression),
+		data:      buf.Bytes(),
+		timestamp: uint32(time.Now().Unix()),
+	}
+	return nil
+}
+
+// DeleteChunk removes the chunk at x, z, if there is one.
+func (w *Writer) DeleteChunk(x, z int) {
+	w.pending[chunkIndex(x, z)] = &pendingChunk{deleted: true}
+}
+
+// Flush writes the pending changes to the region file.
+//
+// External chunk files are put in place before the region, since their names
+// are fixed. If Flush is interrupted between the two, the old region reads a
+// replaced external chunk's new data (which fails if its compression format
+// changed), and ignores new external files, which the next Flush removes
+// along with any other external files the region doesn't refer to.
+func (w *Writer) Flush() error {
+	changed := false
+	for i, p := range w.pending {
+		// compacting a region with no chunks has nothing to pack, so an empty
+		// file is left empty
+		loc := w.locTable[i]
+		changed = changed || p != nil || (w.compact && (loc.offset != 0 || loc.size != 0))
+	}
+	if !changed {
+		w.compact = false
+		return nil
+	}
+
+	locTable, timestampTable := w.locTable, w.timestampTable
+	// chunks that aren't changing, which are copied from the current file
+	var kept []int
+	// sectors used by the header, and by the chunks that aren't moving
+	used := []bool{true, true}
+	for i, loc := range locTable {
+		if w.pending[i] != nil {
+			locTable[i], timestampTable[i] = ChunkLocation{}, 0
+			continue
+		}
+		if loc.offset == 0 && loc.size == 0 {
+			continue
+		}
+		kept = append(kept, i)
+		if w.compact {
+			continue
+		}
+		end := int(loc.offset) + int(loc.size)
+		for len(used) < end {
+			used = append(used, false)
+		}
+		for s := int(loc.offset); s < end; s++ {
+			used[s] = true
+		}
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(w.path), "."+filepath.Base(w.path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	// renamed external chunk files can't be taken back, but are only read
+	// through the region file
+	var externalTmps []string
+	defer func() {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		for _, name := range externalTmps {
+			os.Remove(name)
+		}
+	}()
+
+	if w.compact {
+		// keep the chunks in the order they were in
+		slices.SortFunc(kept, func(a, b int) int {
+			return cmp.Compare(locTable[a].offset, locTable[b].offset)
+		})
+	}
+	for _, i := range kept {
+		loc := locTable[i]
+		src := int64(loc.offset) * sectorSize
+		if w.compact {
+			var offset int
+			n := w.chunkSectors(loc)
+			used, offset = allocateSectors(used, n)
+			loc = ChunkLocation{offset: uint32(offset), size: byte(n)}
+			locTable[i] = loc
+		}
+		dst, n := int64(loc.offset)*sectorSize, int64(loc.size)*sectorSize
+		if _, err := io.Copy(io.NewOffsetWriter(tmp, dst), io.NewSectionReader(w.f, src, n)); err != nil {
+			return fmt.Errorf("copying chunk %d: %w", i, err)
+		}
+	}
+
+	// which chunks the new region stores in external files
+	var external [1024]bool
+	for _, i := range kept {
+		external[i] = w.isExternal(i)
+	}
+	var externalRenames [][2]string
+	for i, p := range w.pending {
+		if p == nil || p.deleted {
+			continue
+		}
+		x, z := i%32, i/32
+
+		chunk := binary.BigEndian.AppendUint32(nil, uint32(len(p.header)+len(p.data)))
+		chunk = append(chunk, p.header...)
+		chunk = append(chunk, p.data...)
+		if sectorsFor(len(chunk)) > maxChunkSectors {
+			// too large for the region - store the data in its own file, and
+			// only the header in the region
+			path, err := externalChunkPath(w.path, x, z)
+			if err != nil {
+				return fmt.Errorf("chunk (%d, %d) needs to be stored in an external file: %w", x, z, err)
+			}
+			name, err := writeTemp(path, p.data)
+			if err != nil {
+				return err
+			}
+			externalTmps = append(externalTmps, name)
+			externalRenames = append(externalRenames, [2]string{name, path})
+			external[i] = true
+
+			chunk = binary.BigEndian.AppendUint32(nil, uint32(len(p.header)))
+			chunk = append(chunk, p.header[0]|externalCompression)
+			chunk = append(chunk, p.header[1:]...)
+		}
+
+		var offset int
+		n := sectorsFor(len(chunk))
+		used, offset = allocateSectors(used, n)
+		if offset+n > maxSectors {
+			return fmt.Errorf("region: no space for chunk (%d, %d)", x, z)
+		}
+		if _, err := tmp.WriteAt(chunk, int64(offset)*sectorSize); err != nil {
+			return err
+		}
+		locTable[i] = ChunkLocation{offset: uint32(offset), size: byte(n)}
+		timestampTable[i] = p.timestamp
+	}
+
+	if err := writeTables(tmp, &locTable, &timestampTable); err != nil {
+		return err
+	}
+	// chunks are padded to a whole number of sectors
+	if err := tmp.Truncate(int64(len(used)) * sectorSize); err != nil {
+		return err
+	}
+	if err := tmp.Chmod(w.mode); err != nil {
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	// the region is renamed last, so that it never refers to external files
+	// that aren't there
+	dir := filepath.Dir(w.path)
+	for _, r := range externalRenames {
+		if err := os.Rename(r[0], r[1]); err != nil {
+			return err
+		}
+	}
+	if len(externalRenames) > 0 {
+		if err := syncDir(dir); err != nil {
+			return err
+		}
+	}
+	if w.f != nil {
+		w.f.Close()
+	}
+	if err := os.Rename(tmp.Name(), w.path); err != nil {
+		w.open()
+		return err
+	}
+	if err := syncDir(dir); err != nil {
+		w.open()
+		return err
+	}
+	if err := removeStaleExternal(w.path, &external); err != nil {
+		w.open()
+		return err
+	}
+
+	w.pending = [1024]*pendingChunk{}
+	w.compact = false
+	return w.open()
+}
+
+// isExternal reports whether the current region stores chunk i in an external
+// file.
+func (w *Writer) isExternal(i int) bool {
+	var compression [1]byte
+	_, err := w.f.ReadAt(compression[:], int64(w.locTable[i].offset)*sectorSize+4)
+	return err == nil && compression[0]&externalCompression != 0
+}
+
+// removeStaleExternal removes the external files of the region at path that
+// belong to chunks the region doesn't store externally, such as chunks that
+// have shrunk, or been written by a flush that didn't finish.
+func removeStaleExternal(path string, external *[1024]bool) error {
+	regionX, regionZ, err := parseRegionName(filepath.Base(path))
+	if err != nil {
+		// the region can't have external chunks
+		return nil
+	}
+	entries, err := os.ReadDir(filepath.Dir(path))
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		var x, z int
+		if n, _ := fmt.Sscanf(e.Name(), "c.%d.%d.mcc", &x, &z); n != 2 || e.Name() != fmt.Sprintf("c.%d.%d.mcc", x, z) {
+			continue
+		}
+		if x>>5 != regionX || z>>5 != regionZ || external[chunkIndex(x, z)] {
+			continue
+		}
+		if err := os.Remove(filepath.Join(filepath.Dir(path), e.Name())); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+	}
+	return nil
+}
+
+// syncDir commits renames in dir to disk.
+func syncDir(dir string) error {
+	if runtime.GOOS == "windows" {
+		// directories can't be opened for syncing
+		return nil
+	}
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	err = d.Sync()
+	if cerr := d.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// Compact makes the next Flush pack every chunk together at the start of the
+// file, in the order they're stored, reclaiming the space left unused as
+// chunks grow and move. Chunks that use fewer sectors than they were given
+// are also shrunk.
+func (w *Writer) Compact() {
+	w.compact = true
+}
+
+// chunkSectors returns the number of sectors the chunk at loc needs, from the
+// length in its header.
+func (w *Writer) chunkSectors(loc ChunkLocation) int {
+	var length [4]byte
+	if _, err := w.f.ReadAt(length[:], int64(loc.offset)*sectorSize); err != nil {
+		return int(loc.size)
+	}
+	n := sectorsFor(4 + int(binary.BigEndian.Uint32(length[:])))
+	if n == 0 || n > int(loc.size) {
+		// a corrupt length; keep what's there
+		return int(loc.size)
+	}
+	return n
+}
+
+// Close flushes the pending changes and closes the region file.
+func (w *Writer) Close() error {
+	err := w.Flush()
+	if w.f != nil {
+		if cerr := w.f.Close(); err == nil {
+			err = cerr
+		}
+		w.f = nil
+	}
+	return err
+}
+
+// writeTables writes the location and timestamp tables at the start of f.
+func writeTables(f io.WriterAt, locTable *[1024]ChunkLocation, timestampTable *[1024]uint32) error {
+	buf := make([]byte, 2*sectorSize)
+	for i, loc := range locTable {
+		buf[i*4] = byte(loc.offset >> 16)
+		buf[i*4+1] = byte(loc.offset >> 8)
+		buf[i*4+2] = byte(loc.offset)
+		buf[i*4+3] = loc.size
+		binary.BigEndian.PutUint32(buf[sectorSize+i*4:], timestampTable[i])
+	}
+	_, err := f.WriteAt(buf, 0)
+	return err
+}
+
+// writeTemp writes data to a temporary file next to path, returning its name.
+func writeTemp(path string, data []byte) (string, error) {
+	f, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp*")
+	if err != nil {
+		return "", err
+	}
+	_, err = f.Write(data)
+	if err == nil {
+		err = f.Chmod(0644)
+	}
+	if err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return "", err
+	}
+	return f.Name(), nil
+}
+
+// sectorsFor returns the number of sectors needed to store n bytes.
+func sectorsFor(n int) int {
+	return (n + sectorSize - 1) / sectorSize
+}
+
+// allocateSectors marks the first run of n free sectors as used, extending
+// the file if there's no such run, and returns its offset.
+func allocateSectors(used []bool, n int) ([]bool, int) {
+	run := 0
+	for i, u := range used {
+		if u {
+			run = 0
+			continue
+		}
+		run++
+		if run == n {
+			start := i - n + 1
+			for s := start; s <= i; s++ {
+				used[s] = true
+			}
+			return used, start
+		}
+	}
+	// a free run at the end of the file can be extended
+	start := len(used) - run
+	for len(used) < start+n {
+		used = append(used, false)
+	}
+	for s := start; s < start+n; s++ {
+		used[s] = true
+	}
+	return used, start
+}
@@ -0,0 +1,270 @@
+package region
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func readTestChunk(t *testing.T, path string, x, z int) map[string]any {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var c map[string]any
+	if err := ReadChunk(f, x, z, &c); err != nil {
+		t.Fatalf("reading chunk (%d, %d): %v", x, z, err)
+	}
+	return c
+}
+
+func TestWriter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "r.1.-1.mca")
+	w, err := NewWriter(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// chunk (0, 0) takes 2 sectors, and the others 1
+	big := map[string]any{"Data": bytes.Repeat([]byte{1}, 5000)}
+	if err := w.SetCompression("none"); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.WriteChunk(0, 0, big); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.SetCompression("gzip"); err != nil {
+		t.Fatal(err)
+	}
+	for _, x := range []int{1, 2} {
+		if err := w.WriteChunk(x, 0, map[string]any{"x": int32(x)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if got := readTestChunk(t, path, 2, 0); !reflect.DeepEqual(got, map[string]any{"x": int32(2)}) {
+		t.Errorf("chunk (2, 0) = %v", got)
+	}
+
+	// deleting (0, 0) frees 2 sectors at the start of the file, which the
+	// chunk written in its place fits in
+	w, err = NewWriter(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	locs := w.locTable
+	w.DeleteChunk(0, 0)
+	if err := w.WriteChunk(32+5, -32+5, map[string]any{"x": int32(5)}); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	r, err := NewReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.HasChunk(0, 0) {
+		t.Errorf("chunk (0, 0) wasn't deleted")
+	}
+	if got := r.locTable[chunkIndex(5, 5)].offset; got != locs[0].offset {
+		t.Errorf("chunk (5, 5) was written at sector %d, want %d", got, locs[0].offset)
+	}
+	if r.locTable[1] != locs[1] || r.timestampTable[1] != w.timestampTable[1] {
+		t.Errorf("unchanged chunk (1, 0) moved")
+	}
+	if info, _ := f.Stat(); info.Size() != 6*sectorSize {
+		t.Errorf("region is %d bytes, want %d", info.Size(), 6*sectorSize)
+	}
+	var c map[string]any
+	if err := r.DecodeChunk(5, 5, &c); err != nil || c["x"] != int32(5) {
+		t.Errorf("chunk (5, 5) = %v, %v", c, err)
+	}
+}
+
+func TestWriterExternalChunk(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "r.-1.0.mca")
+	external := filepath.Join(dir, "c.-31.2.mcc")
+
+	w, err := NewWriter(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.SetCompression("none"); err != nil {
+		t.Fatal(err)
+	}
+	huge := map[string]any{"Data": bytes.Repeat([]byte{7}, maxChunkSectors*sectorSize)}
+	if err := w.WriteChunk(1, 2, huge); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(external); err != nil {
+		t.Fatalf("chunk wasn't stored externally: %v", err)
+	}
+	if info, _ := os.Stat(path); info.Size() != 3*sectorSize {
+		t.Errorf("region is %d bytes, want %d", info.Size(), 3*sectorSize)
+	}
+	if got := readTestChunk(t, path, 1, 2); !reflect.DeepEqual(got, huge) {
+		t.Errorf("external chunk didn't round trip")
+	}
+
+	// external files of unchanged chunks are kept, and any others of the
+	// region (such as one left by an interrupted flush) are removed
+	orphan := filepath.Join(dir, "c.-30.2.mcc")
+	otherRegion := filepath.Join(dir, "c.1.2.mcc")
+	for _, name := range []string{orphan, otherRegion} {
+		if err := os.WriteFile(name, []byte{1}, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.WriteChunk(3, 3, map[string]any{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(external); err != nil {
+		t.Errorf("external file of an unchanged chunk was removed: %v", err)
+	}
+	if _, err := os.Stat(orphan); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("orphaned external file wasn't removed: %v", err)
+	}
+	if err := os.Remove(otherRegion); err != nil {
+		t.Errorf("another region's external file was removed: %v", err)
+	}
+
+	// once the chunk is small enough, the external file is removed
+	if err := w.WriteChunk(1, 2, map[string]any{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(external); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("external file wasn't removed: %v", err)
+	}
+	if got := readTestChunk(t, path, 1, 2); len(got) != 0 {
+		t.Errorf("chunk (1, 2) = %v", got)
+	}
+
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 1 {
+		t.Errorf("temporary files were left behind: %v", entries)
+	}
+}
+
+func TestWriterCompression(t *testing.T) {
+	w, err := NewWriter(filepath.Join(t.TempDir(), "r.0.0.mca"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.SetCompression("lz4"); err == nil {
+		t.Errorf("lz4, which can only be read, was accepted")
+	}
+	if err := w.SetCompression("test:missing"); err == nil {
+		t.Errorf("an unregistered format was accepted")
+	}
+}
+
+func TestCompact(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "r.0.0.mca")
+	w, err := NewWriter(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.SetCompression("none"); err != nil {
+		t.Fatal(err)
+	}
+	for x := range 4 {
+		data := map[string]any{"Data": bytes.Repeat([]byte{byte(x)}, 5000)}
+		if err := w.WriteChunk(x, 3, data); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	// leave a gap of two sectors between (0, 3) and (2, 3), and set an old
+	// timestamp to check it's kept
+	w.DeleteChunk(1, 3)
+	if err := w.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	w.timestampTable[chunkIndex(2, 3)] = 1000
+	f, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = writeTables(f, &w.locTable, &w.timestampTable)
+	f.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	reclaimed, err := Compact(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reclaimed != 2*sectorSize {
+		t.Errorf("reclaimed %d bytes, want %d", reclaimed, 2*sectorSize)
+	}
+
+	w, err = NewWriter(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	for j, x := range []int{0, 2, 3} {
+		i := chunkIndex(x, 3)
+		if want := (ChunkLocation{offset: uint32(2 + 2*j), size: 2}); w.locTable[i] != want {
+			t.Errorf("chunk (%d, 3) is at %+v, want %+v", x, w.locTable[i], want)
+		}
+		if got := readTestChunk(t, path, x, 3); !bytes.Equal(got["Data"].([]byte), bytes.Repeat([]byte{byte(x)}, 5000)) {
+			t.Errorf("chunk (%d, 3) changed", x)
+		}
+	}
+	if ts := w.timestampTable[chunkIndex(2, 3)]; ts != 1000 {
+		t.Errorf("timestamp of chunk (2, 3) is %d, want 1000", ts)
+	}
+}
+
+// the game creates empty region files before it saves any chunks, and
+// compacting one leaves it alone
+func TestCompactEmpty(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "r.0.0.mca")
+	if err := os.WriteFile(path, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	reclaimed, err := Compact(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reclaimed != 0 {
+		t.Errorf("reclaimed %d bytes, want 0", reclaimed)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Size() != 0 {
+		t.Errorf("compacted file is %d bytes, want empty", info.Size())
+	}
+}